@@ -0,0 +1,153 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/kdar/factorlog"
+)
+
+// Status describes the running targets and collectors.
+type Status struct {
+	Targets    []TargetStatus  `json:"targets"`
+	Collectors []string        `json:"collectors"`
+	PauseMap   map[string]bool `json:"pause_map"`
+}
+
+// TargetStatus describes a target, the fields the target does not support are omitted.
+type TargetStatus struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Queue          int    `json:"queue"`
+	QueueCapacity  int    `json:"queue_capacity"`
+	Workers        *int   `json:"workers,omitempty"`
+	Alive          *bool  `json:"alive,omitempty"`
+	DatabaseExists *bool  `json:"database_exists,omitempty"`
+	Paused         *bool  `json:"paused,omitempty"`
+}
+
+// Result is returned for every action.
+type Result struct {
+	Target string `json:"target"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// The actions which can be triggered for a target.
+const (
+	ActionPause         = "pause"
+	ActionResume        = "resume"
+	ActionFlush         = "flush"
+	ActionReplay        = "replay"
+	ActionAddWorker     = "workers/add"
+	ActionRemoveWorker  = "workers/remove"
+	statusPath          = "/api/status"
+	targetActionPattern = "/api/targets/{type}/{name}/{action...}"
+)
+
+var (
+	// ErrUnknownTarget is returned if the target is not running.
+	ErrUnknownTarget = errors.New("unknown target")
+	// ErrUnknownAction is returned if the action is not one of the Action constants.
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrNotSupported is returned if the target does not support the action.
+	ErrNotSupported = errors.New("the target does not support this action")
+	// ErrConflict is returned if the action is still running from an earlier request or the config is reloaded.
+	ErrConflict = errors.New("the action is still running")
+)
+
+// Controller gives the API access to the running targets and collectors.
+type Controller interface {
+	Status() Status
+	Do(target data.Target, action string) error
+}
+
+// Handler serves the admin API.
+//
+//	GET  /api/status                         targets, collectors and the pause map
+//	POST /api/targets/{type}/{name}/{action} pause, resume, flush, replay, workers/add or workers/remove
+type Handler struct {
+	controller Controller
+	tokens     []string
+	mux        *http.ServeMux
+	log        *factorlog.FactorLog
+}
+
+// NewHandler creates the handler, every request has to send one of the tokens as bearer token.
+// Leave them empty to disable authentication.
+func NewHandler(controller Controller, tokens []string) *Handler {
+	h := &Handler{
+		controller: controller,
+		tokens:     tokens,
+		mux:        http.NewServeMux(),
+		log:        logging.GetLogger(),
+	}
+	h.mux.HandleFunc("GET "+statusPath, h.serveStatus)
+	h.mux.HandleFunc("POST "+targetActionPattern, h.serveAction)
+	return h
+}
+
+// ServeHTTP checks the token and passes the request on to the status or action handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.isAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveStatus(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.controller.Status())
+}
+
+func (h *Handler) serveAction(w http.ResponseWriter, r *http.Request) {
+	target := data.Target{Name: r.PathValue("name"), Datatype: data.Datatype(r.PathValue("type"))}
+	result := Result{Target: target.String(), Action: r.PathValue("action")}
+	status := http.StatusOK
+	if err := h.controller.Do(target, result.Action); err != nil {
+		result.Error = err.Error()
+		switch {
+		case errors.Is(err, ErrUnknownTarget), errors.Is(err, fs.ErrNotExist):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrConflict):
+			status = http.StatusConflict
+		case errors.Is(err, ErrUnknownAction), errors.Is(err, ErrNotSupported):
+			status = http.StatusBadRequest
+		default:
+			status = http.StatusInternalServerError
+		}
+	}
+	h.log.Infof("Admin API %s %s from %s: %d", result.Action, result.Target, r.RemoteAddr, status)
+	h.writeJSON(w, status, result)
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.log.Warn(err)
+	}
+}
+
+func (h *Handler) isAuthorized(r *http.Request) bool {
+	if len(h.tokens) == 0 {
+		return true
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+	for _, t := range h.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeController struct {
+	actions []string
+}
+
+func (f *fakeController) Status() Status {
+	workers := 2
+	return Status{
+		Targets:    []TargetStatus{{Name: "a", Type: "influx", Queue: 1, QueueCapacity: 10, Workers: &workers}},
+		Collectors: []string{"Livestatus"},
+		PauseMap:   map[string]bool{"a-influx": false},
+	}
+}
+
+func (f *fakeController) Do(target data.Target, action string) error {
+	if target.Name != "a" || target.Datatype != data.InfluxDB {
+		return ErrUnknownTarget
+	}
+	if action == ActionReplay {
+		return ErrNotSupported
+	}
+	f.actions = append(f.actions, action)
+	return nil
+}
+
+func request(handler http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestStatus(t *testing.T) {
+	logging.InitTestLogger()
+	handler := NewHandler(&fakeController{}, nil)
+
+	w := request(handler, http.MethodGet, "/api/status", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status Status
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	assert.Len(t, status.Targets, 1)
+	assert.Equal(t, 2, *status.Targets[0].Workers)
+	assert.Nil(t, status.Targets[0].Alive)
+	assert.Equal(t, []string{"Livestatus"}, status.Collectors)
+
+	w = request(handler, http.MethodPost, "/api/status", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestActions(t *testing.T) {
+	logging.InitTestLogger()
+	controller := &fakeController{}
+	handler := NewHandler(controller, nil)
+
+	assert.Equal(t, http.StatusOK, request(handler, http.MethodPost, "/api/targets/influx/a/pause", "").Code)
+	assert.Equal(t, http.StatusOK, request(handler, http.MethodPost, "/api/targets/influx/a/workers/add", "").Code)
+	assert.Equal(t, []string{ActionPause, ActionAddWorker}, controller.actions)
+
+	w := request(handler, http.MethodPost, "/api/targets/influx/b/pause", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var result Result
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Equal(t, Result{Target: "b-influx", Action: ActionPause, Error: ErrUnknownTarget.Error()}, result)
+
+	assert.Equal(t, http.StatusBadRequest, request(handler, http.MethodPost, "/api/targets/influx/a/replay", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, request(handler, http.MethodGet, "/api/targets/influx/a/pause", "").Code)
+}
+
+func TestAuthorization(t *testing.T) {
+	logging.InitTestLogger()
+	handler := NewHandler(&fakeController{}, []string{"secret"})
+
+	assert.Equal(t, http.StatusUnauthorized, request(handler, http.MethodGet, "/api/status", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(handler, http.MethodGet, "/api/status", "wrong").Code)
+	assert.Equal(t, http.StatusOK, request(handler, http.MethodGet, "/api/status", "secret").Code)
+}
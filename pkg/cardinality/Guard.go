@@ -0,0 +1,220 @@
+package cardinality
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/kdar/factorlog"
+)
+
+// The actions for new series above a limit.
+const (
+	ActionDrop     = "drop"
+	ActionOverflow = "overflow"
+	ActionLog      = "log"
+)
+
+// OverflowLabel replaces the performance label of new series above a limit, if the action is overflow.
+const OverflowLabel = "_overflow"
+
+// The limits which can be exceeded, used as scope label of the metrics.
+const (
+	scopeGlobal  = "global"
+	scopeHost    = "host"
+	scopeCommand = "command"
+)
+
+const (
+	defaultWindow       = time.Hour
+	defaultTopOffenders = 10
+	// the top offenders are exported at most this often
+	publishInterval = 10 * time.Second
+)
+
+// Verdict tells what to do with the perfdata of a series.
+type Verdict int
+
+const (
+	// Accept sends the perfdata unchanged.
+	Accept Verdict = iota
+	// Drop drops the perfdata.
+	Drop
+	// Overflow sends the perfdata with the OverflowLabel.
+	Overflow
+)
+
+// Limits configure a Guard.
+type Limits struct {
+	Window       time.Duration
+	PerHost      int
+	PerCommand   int
+	Global       int
+	Action       string
+	TopOffenders int
+}
+
+// LimitsFromConfig returns the limits of the Cardinality section, ok is false if the guard is disabled.
+func LimitsFromConfig(cfg config.Config) (limits Limits, ok bool, err error) {
+	section := cfg.Cardinality
+	if !section.Enabled {
+		return limits, false, nil
+	}
+	limits = Limits{
+		Window: time.Duration(section.Window) * time.Second, PerHost: section.MaxSeriesPerHost,
+		PerCommand: section.MaxSeriesPerCommand, Global: section.MaxSeries,
+		Action: section.Action, TopOffenders: section.TopOffenders,
+	}
+	if limits.Window <= 0 {
+		limits.Window = defaultWindow
+	}
+	if limits.TopOffenders <= 0 {
+		limits.TopOffenders = defaultTopOffenders
+	}
+	switch limits.Action {
+	case "":
+		limits.Action = ActionLog
+	case ActionDrop, ActionOverflow, ActionLog:
+	default:
+		return limits, true, fmt.Errorf("the action %q is not supported, use drop, overflow or log", limits.Action)
+	}
+	return limits, true, nil
+}
+
+// Guard counts the unique host/service/label series per window and limits new ones.
+type Guard struct {
+	limits      Limits
+	mutex       sync.Mutex
+	windowStart time.Time
+	lastPublish time.Time
+	series      map[string]struct{}
+	perHost     map[string]int
+	perCommand  map[string]int
+	// the hosts and commands which were already logged in this window
+	logged     map[string]struct{}
+	log        *factorlog.FactorLog
+	promServer statistics.PrometheusServer
+}
+
+// NewGuard creates a guard, the first window starts with the first series.
+func NewGuard(limits Limits) *Guard {
+	return &Guard{
+		limits:     limits,
+		log:        logging.GetLogger(),
+		promServer: statistics.GetPrometheusServer(),
+	}
+}
+
+// Check counts the series and returns what to do with its perfdata. Known series of the window are always accepted.
+func (g *Guard) Check(host, service, command, label string, now time.Time) Verdict {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.series == nil || now.Sub(g.windowStart) >= g.limits.Window {
+		g.series = map[string]struct{}{}
+		g.perHost = map[string]int{}
+		g.perCommand = map[string]int{}
+		g.logged = map[string]struct{}{}
+		g.windowStart = now
+	}
+	defer g.publish(now)
+
+	key := host + "\t" + service + "\t" + label
+	if _, known := g.series[key]; known {
+		return Accept
+	}
+	scope, name := g.exceededLimit(host, command)
+	if scope == "" {
+		g.add(key, host, command)
+		return Accept
+	}
+	if g.promServer.CardinalityLimited != nil {
+		g.promServer.CardinalityLimited.WithLabelValues(scope, g.limits.Action).Inc()
+	}
+	if _, logged := g.logged[scope+"\t"+name]; !logged {
+		g.logged[scope+"\t"+name] = struct{}{}
+		g.log.Warnf("Cardinality limit of the %s %q is exceeded, new series are handled by the action %s. Host: %v, Service: %v, Label: %v", scope, name, g.limits.Action, host, service, label)
+	}
+	switch g.limits.Action {
+	case ActionDrop:
+		return Drop
+	case ActionOverflow:
+		return Overflow
+	}
+	g.add(key, host, command)
+	return Accept
+}
+
+// Returns the scope and the name of the first exceeded limit, the scope is empty if none is exceeded.
+func (g *Guard) exceededLimit(host, command string) (scope, name string) {
+	switch {
+	case g.limits.Global > 0 && len(g.series) >= g.limits.Global:
+		return scopeGlobal, scopeGlobal
+	case g.limits.PerHost > 0 && g.perHost[host] >= g.limits.PerHost:
+		return scopeHost, host
+	case g.limits.PerCommand > 0 && g.perCommand[command] >= g.limits.PerCommand:
+		return scopeCommand, command
+	}
+	return "", ""
+}
+
+func (g *Guard) add(key, host, command string) {
+	g.series[key] = struct{}{}
+	g.perHost[host]++
+	g.perCommand[command]++
+}
+
+// Exports the amount of series and the top offenders, at most every publishInterval.
+func (g *Guard) publish(now time.Time) {
+	if g.promServer.CardinalityTopSeries == nil || now.Sub(g.lastPublish) < publishInterval {
+		return
+	}
+	g.lastPublish = now
+	g.promServer.CardinalitySeries.Set(float64(len(g.series)))
+	g.promServer.CardinalityTopSeries.Reset()
+	for scope, counts := range map[string]map[string]int{scopeHost: g.perHost, scopeCommand: g.perCommand} {
+		for _, name := range topOffenders(counts, g.limits.TopOffenders) {
+			g.promServer.CardinalityTopSeries.WithLabelValues(scope, name).Set(float64(counts[name]))
+		}
+	}
+}
+
+// Returns the names with the highest counts, sorted by count and name.
+func topOffenders(counts map[string]int, amount int) []string {
+	names := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	return names[:min(amount, len(names))]
+}
+
+// The guard of the current config, it's created again if the Cardinality section changes.
+var current struct {
+	mutex  sync.Mutex
+	limits Limits
+	guard  *Guard
+}
+
+// Current returns the guard of the Cardinality section, or nil if it's disabled or invalid.
+func Current(cfg config.Config) *Guard {
+	current.mutex.Lock()
+	defer current.mutex.Unlock()
+	limits, enabled, err := LimitsFromConfig(cfg)
+	switch {
+	case !enabled:
+		current.guard = nil
+	case err != nil:
+		if current.guard != nil || current.limits != limits {
+			logging.GetLogger().Errorf("Cardinality guard is disabled: %s", err)
+		}
+		current.guard = nil
+	case current.guard == nil || current.limits != limits:
+		current.guard = NewGuard(limits)
+	}
+	current.limits = limits
+	return current.guard
+}
@@ -0,0 +1,75 @@
+package cardinality
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gcfg.v1"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitTestLogger()
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+func TestGuardLimits(t *testing.T) {
+	now := time.Unix(1000, 0)
+	g := NewGuard(Limits{Window: time.Minute, PerHost: 2, PerCommand: 3, Action: ActionDrop, TopOffenders: 1})
+
+	assert.Equal(t, Accept, g.Check("a", "s", "check_a", "1", now))
+	assert.Equal(t, Accept, g.Check("a", "s", "check_a", "2", now))
+	assert.Equal(t, Drop, g.Check("a", "s", "check_a", "3", now), "two series per host")
+	assert.Equal(t, Accept, g.Check("a", "s", "check_a", "1", now), "known series are accepted")
+	assert.Equal(t, Accept, g.Check("b", "s", "check_a", "1", now))
+	assert.Equal(t, Drop, g.Check("b", "s", "check_a", "2", now), "three series per command")
+	assert.Equal(t, Accept, g.Check("b", "s", "check_b", "2", now))
+
+	g.publish(now.Add(publishInterval))
+	assert.InDelta(t, 4, testutil.ToFloat64(g.promServer.CardinalitySeries), 0)
+	assert.InDelta(t, 3, testutil.ToFloat64(g.promServer.CardinalityTopSeries.WithLabelValues(scopeCommand, "check_a")), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(g.promServer.CardinalityLimited.WithLabelValues(scopeHost, ActionDrop)), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(g.promServer.CardinalityLimited.WithLabelValues(scopeCommand, ActionDrop)), 0)
+
+	assert.Equal(t, Accept, g.Check("a", "s", "check_a", "3", now.Add(time.Minute)), "the series are counted again in the next window")
+
+	g = NewGuard(Limits{Window: time.Minute, Global: 1, Action: ActionOverflow})
+	assert.Equal(t, Accept, g.Check("a", "s", "check_a", "1", now))
+	assert.Equal(t, Overflow, g.Check("b", "s", "check_b", "1", now))
+
+	g = NewGuard(Limits{Window: time.Minute, Global: 1, Action: ActionLog})
+	assert.Equal(t, Accept, g.Check("a", "s", "check_a", "1", now))
+	assert.Equal(t, Accept, g.Check("b", "s", "check_b", "1", now))
+}
+
+func TestTopOffenders(t *testing.T) {
+	counts := map[string]int{"a": 1, "b": 5, "c": 5, "d": 2}
+	assert.Equal(t, []string{"b", "c", "d"}, topOffenders(counts, 3))
+	assert.Equal(t, []string{"b", "c", "d", "a"}, topOffenders(counts, 10))
+}
+
+func TestCurrent(t *testing.T) {
+	var cfg config.Config
+	assert.Nil(t, Current(cfg))
+
+	require.NoError(t, gcfg.ReadStringInto(&cfg, "[Cardinality]\nEnabled = true\nMaxSeriesPerHost = 5\n"))
+	guard := Current(cfg)
+	require.NotNil(t, guard)
+	assert.Equal(t, Limits{Window: defaultWindow, PerHost: 5, Action: ActionLog, TopOffenders: defaultTopOffenders}, guard.limits)
+	assert.Same(t, guard, Current(cfg), "the guard is kept while the config does not change")
+
+	cfg.Cardinality.MaxSeriesPerHost = 6
+	assert.NotSame(t, guard, Current(cfg))
+
+	cfg.Cardinality.Action = "block"
+	assert.Nil(t, Current(cfg))
+	_, _, err := LimitsFromConfig(cfg)
+	assert.EqualError(t, err, `the action "block" is not supported, use drop, overflow or log`)
+}
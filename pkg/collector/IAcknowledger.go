@@ -0,0 +1,44 @@
+package collector
+
+// Acknowledger is implemented by Printables which have to be confirmed by the target once they are delivered.
+type Acknowledger interface {
+	// Ack marks the Printable as delivered.
+	Ack()
+	// Nack hands the Printable back to its source for a later redelivery.
+	Nack()
+}
+
+// AckAll acknowledges every Printable which supports it.
+func AckAll(printables []Printable) {
+	for _, p := range printables {
+		if a, ok := p.(Acknowledger); ok {
+			a.Ack()
+		}
+	}
+}
+
+// NackAll hands every Printable which supports it back to its source. The rendered lines of the remaining Printables are returned,
+// lines has to contain the rendered Printables in the same order.
+func NackAll(printables []Printable, lines []string) []string {
+	var remaining []string
+	for i, p := range printables {
+		if a, ok := p.(Acknowledger); ok {
+			a.Nack()
+		} else {
+			remaining = append(remaining, lines[i])
+		}
+	}
+	return remaining
+}
+
+// WithoutAcknowledger returns the rendered lines of the Printables which do not support acknowledgements,
+// lines has to contain the rendered Printables in the same order.
+func WithoutAcknowledger(printables []Printable, lines []string) []string {
+	var remaining []string
+	for i, p := range printables {
+		if _, ok := p.(Acknowledger); !ok {
+			remaining = append(remaining, lines[i])
+		}
+	}
+	return remaining
+}
@@ -1,8 +1,11 @@
 package collector
 
+import "github.com/ConSol-Monitoring/nagflux/pkg/helper"
+
 // Printable this interface should be used to push data into the queue.
 type Printable interface {
 	PrintForInfluxDB(version string) string
-	PrintForElasticsearch(version, index string) string
+	PrintForElasticsearch(bulk helper.ElasticsearchBulk) string
+	PrintForJSON() []JSONEvent
 	TestTargetFilter(string) bool
 }
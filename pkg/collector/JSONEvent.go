@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JSONSchemaVersion is the version of the JSONEvent format, it's increased on every incompatible change.
+const JSONSchemaVersion = 1
+
+// The types of a JSONEvent.
+const (
+	JSONTypeMetric       = "metric"
+	JSONTypeNotification = "notification"
+	JSONTypeComment      = "comment"
+	JSONTypeDowntime     = "downtime"
+)
+
+// JSONEvent is a single object of the JSON export, the fields of the other types are omitted.
+type JSONEvent struct {
+	SchemaVersion int    `json:"schema_version"`
+	Type          string `json:"type"`
+	// Time in milliseconds since epoch
+	Timestamp int64  `json:"timestamp"`
+	Host      string `json:"host,omitempty"`
+	Service   string `json:"service,omitempty"`
+
+	// metric
+	Table            string            `json:"table,omitempty"`
+	Command          string            `json:"command,omitempty"`
+	PerformanceLabel string            `json:"performance_label,omitempty"`
+	Unit             string            `json:"unit,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	Fields           map[string]any    `json:"fields,omitempty"`
+
+	// notification, comment and downtime
+	Kind    string `json:"kind,omitempty"`
+	Level   string `json:"level,omitempty"`
+	Author  string `json:"author,omitempty"`
+	Message string `json:"message,omitempty"`
+	// End of a downtime in milliseconds since epoch
+	EndTimestamp int64 `json:"end_timestamp,omitempty"`
+}
+
+// NewJSONEvent creates an event of the current schema version.
+func NewJSONEvent(typ string, timestamp int64) JSONEvent {
+	return JSONEvent{SchemaVersion: JSONSchemaVersion, Type: typ, Timestamp: timestamp}
+}
+
+// JSONFields converts the values to numbers or unquoted strings, all other values are kept as strings.
+// Integers of the Influx line protocol like 5i are converted as well, NaN and Inf stay strings.
+func JSONFields(fields map[string]string) map[string]any {
+	if len(fields) == 0 {
+		return nil
+	}
+	result := make(map[string]any, len(fields))
+	for key, value := range fields {
+		result[key] = JSONValue(value)
+	}
+	return result
+}
+
+// JSONValue converts a single field value, see JSONFields.
+func JSONValue(value string) any {
+	if number, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+		return number
+	}
+	if number, err := strconv.ParseInt(strings.TrimSuffix(value, "i"), 10, 64); err == nil && strings.HasSuffix(value, "i") {
+		return number
+	}
+	if len(value) > 1 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+	}
+	return value
+}
+
+// ParseTimestamp parses a timestamp given in seconds or milliseconds, values below 1e11 are taken as seconds.
+func ParseTimestamp(timestamp string) int64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(timestamp), 64)
+	if err != nil {
+		return 0
+	}
+	if value < 1e11 {
+		value *= 1000
+	}
+	return int64(value)
+}
@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONValue(t *testing.T) {
+	t.Parallel()
+	for input, expected := range map[string]any{
+		"1.5":         1.5,
+		"-3":          -3.0,
+		"5i":          int64(5),
+		"true":        "true",
+		"F":           "F",
+		"NaN":         "NaN",
+		"-Inf":        "-Inf",
+		"Infinity":    "Infinity",
+		`"a \"b\" c"`: `a "b" c`,
+		"U":           "U",
+		"":            "",
+	} {
+		assert.Equal(t, expected, JSONValue(input), input)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, int64(1458988932000), ParseTimestamp("1458988932"))
+	assert.Equal(t, int64(1458988932123), ParseTimestamp("1458988932123"))
+	assert.Equal(t, int64(0), ParseTimestamp("now"))
+}
@@ -1,5 +1,49 @@
 package collector
 
-import "github.com/ConSol-Monitoring/nagflux/pkg/data"
+import (
+	"sync"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+)
 
 type ResultQueues map[data.Target]chan Printable
+
+// guards every ResultQueues against targets which are added or removed while the collectors are running
+var resultQueuesMutex = &sync.RWMutex{}
+
+// RLock has to be held while iterating over the queues, if targets can be added or removed meanwhile.
+func (r ResultQueues) RLock() {
+	resultQueuesMutex.RLock()
+}
+
+// RUnlock releases the lock taken by RLock.
+func (r ResultQueues) RUnlock() {
+	resultQueuesMutex.RUnlock()
+}
+
+// Add adds the queue of a target, it waits until no collector is iterating over the queues.
+func (r ResultQueues) Add(target data.Target, queue chan Printable) {
+	resultQueuesMutex.Lock()
+	r[target] = queue
+	resultQueuesMutex.Unlock()
+}
+
+// Remove removes the queue of a target and returns it, it waits until no collector is iterating over the queues.
+func (r ResultQueues) Remove(target data.Target) chan Printable {
+	resultQueuesMutex.Lock()
+	queue := r[target]
+	delete(r, target)
+	resultQueuesMutex.Unlock()
+	return queue
+}
+
+// Copy returns a snapshot of the queues.
+func (r ResultQueues) Copy() ResultQueues {
+	resultQueuesMutex.RLock()
+	defer resultQueuesMutex.RUnlock()
+	result := make(ResultQueues, len(r))
+	for target, queue := range r {
+		result[target] = queue
+	}
+	return result
+}
@@ -1,6 +1,14 @@
 package collector
 
-import "github.com/ConSol-Monitoring/nagflux/pkg/data"
+import (
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+)
+
+// Rendered is implemented by Printables which carry text already rendered for a target, like the records of a dumpfile or write-ahead log.
+type Rendered interface {
+	RenderedText(datatype data.Datatype) (string, bool)
+}
 
 // SimplePrintable can be used to send strings as printable
 type SimplePrintable struct {
@@ -19,9 +27,19 @@ func (p *SimplePrintable) PrintForInfluxDB(_ string) string {
 }
 
 // PrintForElasticsearch generates an String for Elasticsearch
-func (p *SimplePrintable) PrintForElasticsearch(_, _ string) string {
+func (p *SimplePrintable) PrintForElasticsearch(_ helper.ElasticsearchBulk) string {
 	if p.Datatype == data.Elasticsearch {
 		return p.Text
 	}
 	return ""
 }
+
+// PrintForJSON returns nothing, the text is already in the format of its target
+func (p *SimplePrintable) PrintForJSON() []JSONEvent {
+	return nil
+}
+
+// RenderedText returns the text if it was rendered for the given datatype.
+func (p *SimplePrintable) RenderedText(datatype data.Datatype) (string, bool) {
+	return p.Text, p.Datatype == datatype
+}
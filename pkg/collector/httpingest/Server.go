@@ -0,0 +1,337 @@
+package httpingest
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/filter"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/kdar/factorlog"
+)
+
+// Server accepts perfdata via HTTP POST and adds it to the queue.
+//
+//	/write/nagios  tab separated Nagios perfdata lines, like the spoolfiles
+//	/write/nagflux nagflux CSV, like the files in the NagfluxSpoolfileFolder
+//	/write/influx  Influx line protocol, which is only sent to InfluxDB targets
+//
+// A line is queued for every target or, if a queue stays full, for none of them.
+type Server struct {
+	results               collector.ResultQueues
+	tokens                []string
+	maxBodySize           int64
+	fieldSeparator        rune
+	nagiosSpoolfileWorker *spoolfile.NagiosSpoolfileWorker
+	filterProcessor       filter.Processor
+	server                *http.Server
+	log                   *factorlog.FactorLog
+}
+
+// Response is returned for every write request, the accepted lines are queued even if other lines failed.
+type Response struct {
+	Accepted int         `json:"accepted"`
+	Errors   []LineError `json:"errors,omitempty"`
+}
+
+// LineError describes why a line was rejected, lines start at 1.
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// DefaultMaxBodySize is used if no size is configured.
+const DefaultMaxBodySize = 10 * 1024 * 1024
+
+var (
+	// time to wait for a full queue, before the line is rejected
+	queueTimeout = time.Duration(10) * time.Second
+	// how often a full queue is checked for room
+	queuePollInterval = time.Duration(10) * time.Millisecond
+)
+
+var errorQueueFull = errors.New("the buffer is full, try again later")
+
+// NewHTTPIngestServer creates the server and starts listening on the given address.
+// Every request has to send one of the tokens as bearer token, leave them empty to disable authentication.
+// livestatusCacheBuilder can be nil, which disables the downtime tag of the perfdata.
+func NewHTTPIngestServer(address string, tokens []string, maxBodySize int64, fieldSeparator rune,
+	results collector.ResultQueues, livestatusCacheBuilder *livestatus.CacheBuilder,
+) *Server {
+	s := NewHandler(tokens, maxBodySize, fieldSeparator, results, livestatusCacheBuilder)
+	if len(tokens) == 0 {
+		s.log.Warnf("HTTP ingest on %s accepts data without authentication", address)
+	}
+	s.server = &http.Server{Addr: address, Handler: s, ReadHeaderTimeout: time.Duration(10) * time.Second}
+	go func() {
+		s.log.Infof("HTTP ingest listening on %s", address)
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Critical(err)
+		}
+	}()
+	return s
+}
+
+// NewHandler creates the server without listening, it can be used as http.Handler.
+func NewHandler(tokens []string, maxBodySize int64, fieldSeparator rune,
+	results collector.ResultQueues, livestatusCacheBuilder *livestatus.CacheBuilder,
+) *Server {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	return &Server{
+		results:        results,
+		tokens:         tokens,
+		maxBodySize:    maxBodySize,
+		fieldSeparator: fieldSeparator,
+		nagiosSpoolfileWorker: spoolfile.NewNagiosSpoolfileWorker(
+			-1, make(chan string), make(collector.ResultQueues), livestatusCacheBuilder, 4096, collector.AllFilterable, spoolfile.PerfdataLabelMaxLengthDefault, spoolfile.PerfdataUOMMaxLengthDefault, spoolfile.PerfdataNumericValuesMaxLengthDefault, spoolfile.PerfdataThresholdsMaxLengthDefault),
+		filterProcessor: filter.NewFilter(config.GetConfig().Filter.SpoolFileLineTerms),
+		log:             logging.GetLogger(),
+	}
+}
+
+// Stop stops the server, running requests are finished.
+func (s *Server) Stop() {
+	if s.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(5)*time.Second)
+		defer cancel()
+		if err := s.server.Shutdown(ctx); err != nil {
+			s.log.Warn(err)
+		}
+	}
+	s.log.Debug("HTTPIngestServer stopped")
+}
+
+// ServeHTTP handles the write requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var parse func(io.Reader) (Response, error)
+	switch r.URL.Path {
+	case "/write/nagios":
+		parse = s.parseNagios
+	case "/write/nagflux":
+		parse = s.parseNagflux
+	case "/write/influx":
+		parse = s.parseInflux
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.isAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := parse(http.MaxBytesReader(w, r.Body, s.maxBodySize))
+	status := http.StatusOK
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		status = http.StatusRequestEntityTooLarge
+		response.Errors = append(response.Errors, LineError{Error: err.Error()})
+	} else if err != nil {
+		status = http.StatusBadRequest
+		response.Errors = append(response.Errors, LineError{Error: err.Error()})
+	} else if len(response.Errors) > 0 {
+		status = http.StatusBadRequest
+	}
+	if len(response.Errors) > 0 {
+		s.log.Debugf("HTTP ingest %s from %s: accepted %d, rejected %d", r.URL.Path, r.RemoteAddr, response.Accepted, len(response.Errors))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.log.Warn(err)
+	}
+}
+
+func (s *Server) isAuthorized(r *http.Request) bool {
+	if len(s.tokens) == 0 {
+		return true
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses tab separated Nagios perfdata lines, like DATATYPE::SERVICEPERFDATA\tTIMET::...
+func (s *Server) parseNagios(body io.Reader) (Response, error) {
+	response := Response{}
+	err := forEachLine(body, func(lineNumber int, line string) {
+		if !s.filterProcessor.FilterNagiosSpoolFileLine([]byte(line)) {
+			s.log.Debugf("skipping line %s", line)
+			return
+		}
+		input := helper.StringToMap(line, "\t", "::")
+		if datatype := input["DATATYPE"]; datatype != "HOSTPERFDATA" && datatype != "SERVICEPERFDATA" {
+			response.reject(lineNumber, fmt.Errorf("DATATYPE has to be HOSTPERFDATA or SERVICEPERFDATA, got %q", datatype))
+			return
+		}
+		var printables []collector.Printable
+		for perf := range s.nagiosSpoolfileWorker.PerformanceDataIterator(input) {
+			printables = append(printables, perf)
+		}
+		if len(printables) == 0 {
+			response.reject(lineNumber, errors.New("no valid perfdata found"))
+			return
+		}
+		response.add(lineNumber, s.addToQueues(printables))
+	})
+	return response, err
+}
+
+// Parses the nagflux CSV format, the first line is the header.
+func (s *Server) parseNagflux(body io.Reader) (Response, error) {
+	response := Response{}
+	printables, err := nagflux.ParseCSV(body, s.fieldSeparator)
+	if err != nil {
+		return response, err
+	}
+	for i := range printables {
+		// the header is the first line
+		lineNumber := i + 2
+		if err := printables[i].Validate(); err != nil {
+			response.reject(lineNumber, err)
+			continue
+		}
+		response.add(lineNumber, s.addToQueues([]collector.Printable{&printables[i]}))
+	}
+	return response, nil
+}
+
+// Parses Influx line protocol, empty lines and comments are skipped.
+func (s *Server) parseInflux(body io.Reader) (Response, error) {
+	response := Response{}
+	err := forEachLine(body, func(lineNumber int, line string) {
+		if strings.HasPrefix(line, "#") {
+			return
+		}
+		if err := validateInfluxLine(line); err != nil {
+			response.reject(lineNumber, err)
+			return
+		}
+		printable := &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: line, Datatype: data.InfluxDB}
+		response.add(lineNumber, s.addToQueues([]collector.Printable{printable}, data.InfluxDB))
+	})
+	return response, err
+}
+
+// Calls the function for every line which is not empty.
+func forEachLine(body io.Reader, f func(lineNumber int, line string)) error {
+	reader := bufio.NewReader(body)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			f(lineNumber, line)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Checks if the line contains a measurement and a field set, quoted and escaped chars are respected.
+func validateInfluxLine(line string) error {
+	sections := []string{""}
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			sections[len(sections)-1] += line[i : i+2]
+			i++
+			continue
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ' ' && !inQuotes:
+			sections = append(sections, "")
+			continue
+		}
+		sections[len(sections)-1] += string(c)
+	}
+	if inQuotes {
+		return errors.New("unterminated string field")
+	}
+	if len(sections) < 2 || len(sections) > 3 || sections[0] == "" || strings.HasPrefix(sections[0], ",") {
+		return errors.New("expected: measurement[,tags] fields [timestamp]")
+	}
+	if !strings.Contains(sections[1], "=") {
+		return errors.New("the field set is missing")
+	}
+	if _, err := strconv.ParseInt(sections[len(sections)-1], 10, 64); len(sections) == 3 && err != nil {
+		return fmt.Errorf("invalid timestamp %q", sections[2])
+	}
+	return nil
+}
+
+// Adds the printables to the queues of the given datatypes, to every queue if none is given.
+// Nothing is added until every queue has room for all printables, so a retry of a rejected line doesn't duplicate data.
+// Returns an error if a queue stays full.
+func (s *Server) addToQueues(printables []collector.Printable, datatypes ...data.Datatype) error {
+	s.results.RLock()
+	defer s.results.RUnlock()
+	queues := make([]chan collector.Printable, 0, len(s.results))
+	deadline := time.Now().Add(queueTimeout)
+	for target, r := range s.results {
+		if len(datatypes) > 0 && !slices.Contains(datatypes, target.Datatype) {
+			continue
+		}
+		// a line with more printables than the buffer size can't be added at once
+		for cap(r)-len(r) < min(len(printables), cap(r)) {
+			if time.Now().After(deadline) {
+				s.log.Warnf("HTTPIngestServer: Could not write to buffer of %s", target.Name)
+				return errorQueueFull
+			}
+			time.Sleep(queuePollInterval)
+		}
+		queues = append(queues, r)
+	}
+	for _, printable := range printables {
+		for _, r := range queues {
+			r <- printable
+		}
+	}
+	return nil
+}
+
+func (r *Response) add(lineNumber int, err error) {
+	if err != nil {
+		r.reject(lineNumber, err)
+		return
+	}
+	r.Accepted++
+}
+
+func (r *Response) reject(lineNumber int, err error) {
+	r.Errors = append(r.Errors, LineError{Line: lineNumber, Error: err.Error()})
+}
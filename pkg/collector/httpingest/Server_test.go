@@ -0,0 +1,151 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(tokens []string) (*Server, chan collector.Printable) {
+	logging.InitTestLogger()
+	queue := make(chan collector.Printable, 100)
+	results := collector.ResultQueues{data.Target{Name: "test", Datatype: data.InfluxDB}: queue}
+	return NewHandler(tokens, 0, '&', results, nil), queue
+}
+
+func post(s *Server, path, token, body string) (*httptest.ResponseRecorder, Response) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	response := Response{}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder, response
+}
+
+func TestAuthorization(t *testing.T) {
+	s, _ := newTestServer([]string{"secret"})
+	recorder, _ := post(s, "/write/influx", "", "m v=1")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	recorder, _ = post(s, "/write/influx", "wrong", "m v=1")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	recorder, _ = post(s, "/write/influx", "secret", "m v=1")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder, _ = post(s, "/write/unknown", "secret", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	req := httptest.NewRequest(http.MethodGet, "/write/influx", nil)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestWriteNagios(t *testing.T) {
+	s, queue := newTestServer(nil)
+	body := "DATATYPE::SERVICEPERFDATA\tTIMET::1441791000\tHOSTNAME::xxx\tSERVICEDESC::range\tSERVICEPERFDATA::a=1 b=2\tSERVICECHECKCOMMAND::check_range\n" +
+		"\n" +
+		"DATATYPE::SOMETHING\tHOSTNAME::xxx\n" +
+		"DATATYPE::HOSTPERFDATA\tTIMET::1441791000\tHOSTNAME::xxx\tHOSTPERFDATA::\tHOSTCHECKCOMMAND::check_host\n"
+	recorder, response := post(s, "/write/nagios", "", body)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, 1, response.Accepted)
+	assert.Len(t, response.Errors, 2)
+	assert.Equal(t, 3, response.Errors[0].Line)
+	assert.Equal(t, 4, response.Errors[1].Line)
+	assert.Len(t, queue, 2)
+}
+
+func TestWriteNagflux(t *testing.T) {
+	s, queue := newTestServer(nil)
+	body := "table&time&f_value&t_host\n" +
+		"test&1489474756000&1.0&foo\n" +
+		"&1489474756000&1.0&foo\n"
+	recorder, response := post(s, "/write/nagflux", "", body)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, Response{Accepted: 1, Errors: []LineError{{Line: 3, Error: "table is missing"}}}, response)
+	assert.Equal(t, "test,host=foo value=1.0 1489474756000", (<-queue).PrintForInfluxDB("1.0"))
+
+	recorder, response = post(s, "/write/nagflux", "", "foo&bar\n1&2\n")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, 0, response.Accepted)
+}
+
+func TestWriteInflux(t *testing.T) {
+	s, queue := newTestServer(nil)
+	body := "# comment\n" +
+		"cpu,host=a value=1 1441791000000\n" +
+		`msg,host=a message="hello world" 1441791000000` + "\n" +
+		"broken\n" +
+		"cpu value=1 now\n"
+	recorder, response := post(s, "/write/influx", "", body)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, 2, response.Accepted)
+	assert.Equal(t, []int{4, 5}, []int{response.Errors[0].Line, response.Errors[1].Line})
+	assert.Equal(t, "cpu,host=a value=1 1441791000000", (<-queue).PrintForInfluxDB("1.0"))
+	assert.Equal(t, "", (<-queue).PrintForElasticsearch(helper.ElasticsearchBulk{Version: "2.0", Index: "index"}))
+}
+
+func TestValidateInfluxLine(t *testing.T) {
+	valid := []string{`m v=1`, `m,t=a v=1 123`, `m\ x,t=a\ b v="a b" 1`}
+	invalid := []string{`m`, `m v`, `,t=a v=1`, `m v="open`, `m v=1 1.5`, `m v=1 1 2`}
+	for _, line := range valid {
+		assert.NoError(t, validateInfluxLine(line), line)
+	}
+	for _, line := range invalid {
+		assert.Error(t, validateInfluxLine(line), line)
+	}
+}
+
+func TestWriteInfluxOnlyToInfluxDB(t *testing.T) {
+	logging.InitTestLogger()
+	influx := make(chan collector.Printable, 10)
+	elastic := make(chan collector.Printable, 10)
+	results := collector.ResultQueues{
+		data.Target{Name: "influx", Datatype: data.InfluxDB}:       influx,
+		data.Target{Name: "elastic", Datatype: data.Elasticsearch}: elastic,
+	}
+	s := NewHandler(nil, 0, '&', results, nil)
+	recorder, response := post(s, "/write/influx", "", "cpu value=1 1441791000000\n")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1, response.Accepted)
+	assert.Len(t, influx, 1)
+	assert.Empty(t, elastic)
+}
+
+func TestFullQueueRejectsLineCompletely(t *testing.T) {
+	logging.InitTestLogger()
+	previous := queueTimeout
+	queueTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { queueTimeout = previous })
+
+	free := make(chan collector.Printable, 10)
+	full := make(chan collector.Printable, 1)
+	full <- &collector.SimplePrintable{}
+	results := collector.ResultQueues{
+		data.Target{Name: "free", Datatype: data.InfluxDB}: free,
+		data.Target{Name: "full", Datatype: data.InfluxDB}: full,
+	}
+	s := NewHandler(nil, 0, '&', results, nil)
+	recorder, response := post(s, "/write/influx", "", "cpu value=1 1441791000000\n")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, 0, response.Accepted)
+	assert.Empty(t, free, "a rejected line must not be queued for any target")
+
+	<-full
+	recorder, response = post(s, "/write/influx", "", "cpu value=1 1441791000000\n")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1, response.Accepted)
+	assert.Len(t, free, 1)
+	assert.Len(t, full, 1)
+}
@@ -0,0 +1,191 @@
+package icinga2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/kdar/factorlog"
+)
+
+// Collector subscribes to the Icinga2 event stream and adds the perfdata and events to the queue.
+type Collector struct {
+	cancel                context.CancelFunc
+	done                  chan bool
+	results               collector.ResultQueues
+	nagiosSpoolfileWorker *spoolfile.NagiosSpoolfileWorker
+	httpClient            http.Client
+	log                   *factorlog.FactorLog
+	name                  string
+	address               string
+	user                  string
+	password              string
+	queue                 string
+}
+
+const (
+	// DefaultQueue is used if no queue name is configured.
+	DefaultQueue = "nagflux"
+	eventsPath   = "/v1/events"
+	retryDelay   = time.Duration(10) * time.Second
+)
+
+// The stream is reconnected if no line was received within this time, as a half-open connection never returns.
+var idleTimeout = time.Duration(5) * time.Minute
+
+var errIdleTimeout = errors.New("no event within the idle timeout")
+
+// NewIcinga2Collector creates a collector for the Icinga2 API at the given address, like https://localhost:5665, and starts it.
+// livestatusCacheBuilder can be nil, which disables the downtime tag of the perfdata.
+func NewIcinga2Collector(name, address, user, password, queue string, tlsConfig *tls.Config,
+	results collector.ResultQueues, livestatusCacheBuilder *livestatus.CacheBuilder,
+) *Collector {
+	if queue == "" {
+		queue = DefaultQueue
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Collector{
+		cancel:  cancel,
+		done:    make(chan bool),
+		results: results,
+		nagiosSpoolfileWorker: spoolfile.NewNagiosSpoolfileWorker(
+			-1, make(chan string), make(collector.ResultQueues), livestatusCacheBuilder, 4096, collector.AllFilterable, spoolfile.PerfdataLabelMaxLengthDefault, spoolfile.PerfdataUOMMaxLengthDefault, spoolfile.PerfdataNumericValuesMaxLengthDefault, spoolfile.PerfdataThresholdsMaxLengthDefault),
+		httpClient: http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			TLSClientConfig:       tlsConfig,
+			ResponseHeaderTimeout: time.Duration(30) * time.Second,
+		}},
+		log:      logging.GetLogger(),
+		name:     name,
+		address:  strings.TrimSuffix(address, "/"),
+		user:     user,
+		password: password,
+		queue:    queue,
+	}
+	go c.run(ctx)
+	return c
+}
+
+// Stop stops the collector
+func (c *Collector) Stop() {
+	c.cancel()
+	<-c.done
+	c.log.Debug("Icinga2Collector(" + c.name + ") stopped")
+}
+
+// Reconnects to the event stream until the collector is stopped.
+func (c *Collector) run(ctx context.Context) {
+	defer close(c.done)
+	for {
+		err := c.subscribe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errIdleTimeout) {
+			c.log.Warnf("Icinga2(%s) event stream is idle since %s, reconnecting", c.name, idleTimeout)
+			continue
+		}
+		c.log.Warnf("Icinga2(%s) event stream closed, reconnecting in %s: %v", c.name, retryDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// Opens the event stream and handles the events until the stream breaks.
+func (c *Collector) subscribe(ctx context.Context) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	idle := time.AfterFunc(idleTimeout, func() { cancel(errIdleTimeout) })
+	defer idle.Stop()
+	body, err := json.Marshal(map[string]any{"queue": c.queue, "types": EventTypes})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.address+eventsPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Nagflux")
+	req.SetBasicAuth(c.user, c.password)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status: %s - %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	c.log.Infof("Icinga2(%s) subscribed to %s", c.name, c.address+eventsPath)
+
+	// every event is a single line, which can be quite long for plugins with a lot of output
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		// the time to hand the event to the queues doesn't count
+		idle.Stop()
+		if errors.Is(context.Cause(ctx), errIdleTimeout) {
+			return errIdleTimeout
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.handleLine(line)
+		}
+		if errors.Is(err, io.EOF) {
+			return errors.New("server closed the connection")
+		} else if err != nil {
+			return err
+		}
+		idle.Reset(idleTimeout)
+	}
+}
+
+func (c *Collector) handleLine(line []byte) {
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		c.log.Warnf("Icinga2(%s) could not parse event: %s. Data: %s", c.name, err, string(line))
+		return
+	}
+	c.log.Debug("[Icinga2] ", string(line))
+
+	if perfdata := event.Perfdata(); perfdata != nil {
+		for singlePerfdata := range c.nagiosSpoolfileWorker.PerformanceDataIterator(perfdata) {
+			c.addToQueues(singlePerfdata)
+		}
+	}
+	printables, err := event.Printables()
+	if err != nil {
+		c.log.Warnf("Icinga2(%s) %s", c.name, err)
+		return
+	}
+	for _, printable := range printables {
+		c.addToQueues(printable)
+	}
+}
+
+func (c *Collector) addToQueues(printable collector.Printable) {
+	c.results.RLock()
+	defer c.results.RUnlock()
+	for _, r := range c.results {
+		select {
+		case r <- printable:
+		case <-time.After(time.Duration(1) * time.Minute):
+			c.log.Warn("Icinga2Collector: Could not write to buffer")
+		}
+	}
+}
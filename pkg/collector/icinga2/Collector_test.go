@@ -0,0 +1,34 @@
+package icinga2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdleStreamReconnects(t *testing.T) {
+	logging.InitTestLogger()
+	idleTimeout = 50 * time.Millisecond
+	defer func() { idleTimeout = time.Duration(5) * time.Minute }()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// like a half-open connection, the stream never sends anything
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := NewIcinga2Collector("test", server.URL, "user", "password", "", nil, collector.ResultQueues{}, nil)
+	defer c.Stop()
+	assert.Eventually(t, func() bool { return requests.Load() >= 2 }, 5*time.Second, 10*time.Millisecond,
+		"the idle stream has to be reconnected without the retry delay")
+}
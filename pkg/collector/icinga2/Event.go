@@ -0,0 +1,218 @@
+package icinga2
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+)
+
+// EventTypes are the types nagflux subscribes to.
+var EventTypes = []string{"CheckResult", "DowntimeStarted", "DowntimeRemoved", "AcknowledgementSet", "Notification"}
+
+// the livestatus entry_type of acknowledgements
+const acknowledgementEntryType = "4"
+
+// Event is a single message of the /v1/events stream, only the used attributes are decoded.
+type Event struct {
+	Type             string       `json:"type"`
+	Timestamp        float64      `json:"timestamp"`
+	Host             string       `json:"host"`
+	Service          string       `json:"service"`
+	Author           string       `json:"author"`
+	Comment          string       `json:"comment"`
+	Text             string       `json:"text"`
+	Users            []string     `json:"users"`
+	NotificationType string       `json:"notification_type"`
+	CheckResult      *CheckResult `json:"check_result"`
+	Downtime         *Downtime    `json:"downtime"`
+}
+
+// CheckResult is the result of a host or service check.
+type CheckResult struct {
+	Command         json.RawMessage   `json:"command"`
+	Output          string            `json:"output"`
+	State           float64           `json:"state"`
+	ExecutionEnd    float64           `json:"execution_end"`
+	PerformanceData []json.RawMessage `json:"performance_data"`
+}
+
+// Downtime is a scheduled downtime.
+type Downtime struct {
+	HostName    string  `json:"host_name"`
+	ServiceName string  `json:"service_name"`
+	Author      string  `json:"author"`
+	Comment     string  `json:"comment"`
+	StartTime   float64 `json:"start_time"`
+	EndTime     float64 `json:"end_time"`
+}
+
+// PerfdataValue is the structured form of a single perfdata entry.
+type PerfdataValue struct {
+	Label string   `json:"label"`
+	Value float64  `json:"value"`
+	Unit  string   `json:"unit"`
+	Warn  *float64 `json:"warn"`
+	Crit  *float64 `json:"crit"`
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+}
+
+// String renders the value in the plugin perfdata format.
+func (p PerfdataValue) String() string {
+	label := p.Label
+	if strings.ContainsAny(label, " ='") {
+		label = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	result := label + "=" + formatFloat(&p.Value) + p.Unit
+	for _, threshold := range []*float64{p.Warn, p.Crit, p.Min, p.Max} {
+		result += ";" + formatFloat(threshold)
+	}
+	return strings.TrimRight(result, ";")
+}
+
+// PerfdataString joins the performance_data array, which may contain plain strings or PerfdataValue objects.
+func (c *CheckResult) PerfdataString() string {
+	result := make([]string, 0, len(c.PerformanceData))
+	for _, raw := range c.PerformanceData {
+		var plain string
+		if err := json.Unmarshal(raw, &plain); err == nil {
+			result = append(result, plain)
+			continue
+		}
+		var value PerfdataValue
+		if err := json.Unmarshal(raw, &value); err == nil && value.Label != "" {
+			result = append(result, value.String())
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+// CommandName returns the name of the executed plugin, the command is either an argument list or a single string.
+func (c *CheckResult) CommandName() string {
+	var command string
+	var arguments []string
+	if err := json.Unmarshal(c.Command, &arguments); err == nil && len(arguments) > 0 {
+		command = arguments[0]
+	} else if err := json.Unmarshal(c.Command, &command); err == nil {
+		if fields := strings.Fields(command); len(fields) > 0 {
+			command = fields[0]
+		}
+	}
+	if command == "" {
+		return ""
+	}
+	return path.Base(command)
+}
+
+// Perfdata returns the check result in the format of a Nagios perfdata line, so it can be parsed like a spoolfile entry.
+// Returns nil if the event does not contain perfdata.
+func (e *Event) Perfdata() map[string]string {
+	if e.CheckResult == nil {
+		return nil
+	}
+	perfdata := e.CheckResult.PerfdataString()
+	if perfdata == "" {
+		return nil
+	}
+	timestamp := e.CheckResult.ExecutionEnd
+	if timestamp == 0 {
+		timestamp = e.Timestamp
+	}
+	result := map[string]string{
+		"HOSTNAME": e.Host,
+		"TIMET":    formatSeconds(timestamp),
+	}
+	if e.Service == "" {
+		result["DATATYPE"] = "HOSTPERFDATA"
+		result["HOSTPERFDATA"] = perfdata
+		result["HOSTCHECKCOMMAND"] = e.CheckResult.CommandName()
+	} else {
+		result["DATATYPE"] = "SERVICEPERFDATA"
+		result["SERVICEDESC"] = e.Service
+		result["SERVICEPERFDATA"] = perfdata
+		result["SERVICECHECKCOMMAND"] = e.CheckResult.CommandName()
+	}
+	return result
+}
+
+// Printables converts every event except of CheckResult, which are handled by Perfdata.
+func (e *Event) Printables() ([]collector.Printable, error) {
+	switch e.Type {
+	case "CheckResult":
+		return nil, nil
+	case "DowntimeStarted":
+		if e.Downtime == nil {
+			return nil, fmt.Errorf("%s event without downtime", e.Type)
+		}
+		d := e.Downtime
+		return []collector.Printable{livestatus.NewDowntimeData(
+			d.HostName, d.ServiceName, d.Comment, formatSeconds(d.StartTime), d.Author, formatSeconds(d.EndTime),
+		)}, nil
+	case "DowntimeRemoved":
+		if e.Downtime == nil {
+			return nil, fmt.Errorf("%s event without downtime", e.Type)
+		}
+		// a downtime can be removed before it ends
+		d := e.Downtime
+		end := d.EndTime
+		if e.Timestamp > 0 && e.Timestamp < end {
+			end = e.Timestamp
+		}
+		return []collector.Printable{livestatus.NewDowntimeData(
+			d.HostName, d.ServiceName, d.Comment, formatSeconds(d.StartTime), d.Author, formatSeconds(end),
+		)}, nil
+	case "AcknowledgementSet":
+		return []collector.Printable{livestatus.NewCommentData(
+			e.Host, e.Service, e.Comment, formatSeconds(e.Timestamp), e.Author, acknowledgementEntryType,
+		)}, nil
+	case "Notification":
+		notificationType := "SERVICE NOTIFICATION"
+		if e.Service == "" {
+			notificationType = "HOST NOTIFICATION"
+		}
+		comment := e.Text
+		if comment == "" && e.CheckResult != nil {
+			comment = e.CheckResult.Output
+		}
+		author := e.Author
+		if len(e.Users) > 0 {
+			author = strings.Join(e.Users, ",")
+		}
+		return []collector.Printable{livestatus.NewNotificationData(
+			e.Host, e.Service, comment, formatSeconds(e.Timestamp), author, notificationType, e.notificationLevel(),
+		)}, nil
+	}
+	return nil, fmt.Errorf("unsupported event type %q", e.Type)
+}
+
+// Returns the state of the check result as text, like the livestatus log does.
+func (e *Event) notificationLevel() string {
+	if e.CheckResult == nil {
+		return e.NotificationType
+	}
+	states := []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+	if e.Service == "" {
+		states = []string{"UP", "DOWN"}
+	}
+	state := int(e.CheckResult.State)
+	if state < 0 || state >= len(states) {
+		return e.NotificationType
+	}
+	return states[state]
+}
+
+func formatSeconds(timestamp float64) string {
+	return strconv.FormatInt(int64(timestamp), 10)
+}
+
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
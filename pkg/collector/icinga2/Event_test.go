@@ -0,0 +1,113 @@
+package icinga2
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckResultPerfdata(t *testing.T) {
+	line := `{"type":"CheckResult","host":"web01","service":"ping4","timestamp":1700000010.5,
+		"check_result":{"command":["/usr/lib/nagios/plugins/check_ping","-H","127.0.0.1"],"execution_end":1700000009.9,"state":0,
+		"performance_data":["rta=0.05ms;100;200;0",{"type":"PerfdataValue","label":"packet loss","value":0,"unit":"%","warn":80,"crit":100,"min":null,"max":null}]}}`
+	var event Event
+	assert.NoError(t, json.Unmarshal([]byte(line), &event))
+
+	assert.Equal(t, map[string]string{
+		"DATATYPE":            "SERVICEPERFDATA",
+		"HOSTNAME":            "web01",
+		"SERVICEDESC":         "ping4",
+		"SERVICECHECKCOMMAND": "check_ping",
+		"SERVICEPERFDATA":     "rta=0.05ms;100;200;0 'packet loss'=0%;80;100",
+		"TIMET":               "1700000009",
+	}, event.Perfdata())
+
+	printables, err := event.Printables()
+	assert.NoError(t, err)
+	assert.Empty(t, printables)
+}
+
+func TestHostCheckResultPerfdata(t *testing.T) {
+	event := Event{Type: "CheckResult", Host: "web01", Timestamp: 1700000010, CheckResult: &CheckResult{
+		Command:         json.RawMessage(`"/usr/bin/check_host -H 127.0.0.1"`),
+		PerformanceData: []json.RawMessage{json.RawMessage(`"pl=0%"`)},
+	}}
+	perfdata := event.Perfdata()
+	assert.Equal(t, "HOSTPERFDATA", perfdata["DATATYPE"])
+	assert.Equal(t, "check_host", perfdata["HOSTCHECKCOMMAND"])
+	assert.Equal(t, "1700000010", perfdata["TIMET"])
+
+	event.CheckResult.PerformanceData = nil
+	assert.Nil(t, event.Perfdata())
+}
+
+func TestEventPrintables(t *testing.T) {
+	downtime := &Downtime{HostName: "web01", ServiceName: "ping4", Author: "admin", Comment: "patching", StartTime: 100, EndTime: 500}
+	testCases := []struct {
+		event    Event
+		expected []livestatus.Event
+	}{
+		{
+			event: Event{Type: "DowntimeStarted", Downtime: downtime},
+			expected: []livestatus.Event{
+				{Host: "web01", Service: "ping4", Author: "admin", Type: "downtime", Message: "Downtime start: patching", Time: "100"},
+				{Host: "web01", Service: "ping4", Author: "admin", Type: "downtime", Message: "Downtime end: patching", Time: "500"},
+			},
+		},
+		{
+			event: Event{Type: "DowntimeRemoved", Timestamp: 200, Downtime: downtime},
+			expected: []livestatus.Event{
+				{Host: "web01", Service: "ping4", Author: "admin", Type: "downtime", Message: "Downtime start: patching", Time: "100"},
+				{Host: "web01", Service: "ping4", Author: "admin", Type: "downtime", Message: "Downtime end: patching", Time: "200"},
+			},
+		},
+		{
+			event: Event{Type: "AcknowledgementSet", Host: "web01", Author: "admin", Comment: "on it", Timestamp: 300},
+			expected: []livestatus.Event{
+				{Host: "web01", Author: "admin", Type: "acknowledgement", Message: "on it", Time: "300"},
+			},
+		},
+		{
+			event: Event{
+				Type: "Notification", Host: "web01", Service: "ping4", Users: []string{"alice", "bob"}, Timestamp: 400,
+				CheckResult: &CheckResult{State: 2, Output: "PING CRITICAL"},
+			},
+			expected: []livestatus.Event{
+				{Host: "web01", Service: "ping4", Author: "alice,bob", Type: "service_notification", Level: "CRITICAL", Message: "PING CRITICAL", Time: "400"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		printables, err := testCase.event.Printables()
+		assert.NoError(t, err, testCase.event.Type)
+		events := []livestatus.Event{}
+		for _, printable := range printables {
+			events = append(events, printable.(interface{ Events() []livestatus.Event }).Events()...)
+		}
+		assert.Equal(t, testCase.expected, events, testCase.event.Type)
+	}
+
+	_, err := (&Event{Type: "DowntimeStarted"}).Printables()
+	assert.Error(t, err)
+	_, err = (&Event{Type: "ObjectCreated"}).Printables()
+	assert.Error(t, err)
+}
+
+func TestPerformanceDataIterator(t *testing.T) {
+	event := Event{Type: "CheckResult", Host: "web01", Service: "ping4", Timestamp: 10, CheckResult: &CheckResult{
+		PerformanceData: []json.RawMessage{json.RawMessage(`"rta=0.05ms;100;200;0"`)},
+	}}
+	worker := spoolfile.NewNagiosSpoolfileWorker(-1, make(chan string), nil, nil, 4096, collector.AllFilterable,
+		spoolfile.PerfdataLabelMaxLengthDefault, spoolfile.PerfdataUOMMaxLengthDefault, spoolfile.PerfdataNumericValuesMaxLengthDefault, spoolfile.PerfdataThresholdsMaxLengthDefault)
+	result := []*spoolfile.PerformanceData{}
+	for perf := range worker.PerformanceDataIterator(event.Perfdata()) {
+		result = append(result, perf)
+	}
+	assert.Len(t, result, 1)
+	assert.Equal(t, "rta", result[0].PerformanceLabel)
+	assert.Equal(t, "10000", result[0].Time)
+}
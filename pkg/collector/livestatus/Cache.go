@@ -7,6 +7,21 @@ import (
 // Cache contains stored data
 type Cache struct {
 	downtime map[string]map[string]string
+	// host -> service -> tag -> value, the host tags are stored with the service ""
+	tags map[string]map[string]map[string]string
+}
+
+func (cache *Cache) addTags(host, service string, tags map[string]string) {
+	if len(tags) == 0 {
+		return
+	}
+	if cache.tags == nil {
+		cache.tags = map[string]map[string]map[string]string{}
+	}
+	if _, hostExists := cache.tags[host]; !hostExists {
+		cache.tags[host] = map[string]map[string]string{}
+	}
+	cache.tags[host][service] = tags
 }
 
 func (cache *Cache) addDowntime(host, service, start string) {
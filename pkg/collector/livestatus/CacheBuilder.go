@@ -1,6 +1,7 @@
 package livestatus
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -44,13 +45,27 @@ Columns: id start_time entry_time
 OutputFormat: csv
 
 `
+	// QueryForHostTags livestatus query for the Livestatus.HostColumns, %s are the columns
+	QueryForHostTags = `GET hosts
+Columns: name %s
+OutputFormat: csv
+
+`
+	// QueryForServiceTags livestatus query for the Livestatus.ServiceColumns, %s are the columns
+	QueryForServiceTags = `GET services
+Columns: host_name description %s
+OutputFormat: csv
+
+`
+	// the column whose variables become one tag each
+	customVariablesColumn = "custom_variables"
 )
 
 var intervalToCheckLivestatusCache = defaultIntervalToCheckLivestatusCache
 
 // NewLivestatusCacheBuilder constructor, which also starts it immediately.
 func NewLivestatusCacheBuilder(livestatusConnector *Connector) *CacheBuilder {
-	cache := &CacheBuilder{livestatusConnector, make(chan bool, 2), logging.GetLogger(), Cache{make(map[string]map[string]string)}, &sync.Mutex{}}
+	cache := &CacheBuilder{livestatusConnector, make(chan bool, 2), logging.GetLogger(), Cache{downtime: make(map[string]map[string]string)}, &sync.Mutex{}}
 	go cache.run(intervalToCheckLivestatusCache)
 	return cache
 }
@@ -64,7 +79,7 @@ func (builder *CacheBuilder) Stop() {
 
 // Loop which caches livestatus downtimes and waits to quit.
 func (builder *CacheBuilder) run(checkInterval time.Duration) {
-	newCache := builder.createLivestatusCache()
+	newCache := builder.createCache()
 	builder.mutex.Lock()
 	builder.downtimeCache = newCache
 	builder.mutex.Unlock()
@@ -74,7 +89,7 @@ func (builder *CacheBuilder) run(checkInterval time.Duration) {
 			builder.quit <- true
 			return
 		case <-time.After(checkInterval):
-			newCache = builder.createLivestatusCache()
+			newCache = builder.createCache()
 			builder.mutex.Lock()
 			builder.downtimeCache = newCache
 			builder.mutex.Unlock()
@@ -82,6 +97,13 @@ func (builder *CacheBuilder) run(checkInterval time.Duration) {
 	}
 }
 
+// Builds the downtimes and the tags of the hosts and services.
+func (builder *CacheBuilder) createCache() Cache {
+	result := builder.createLivestatusCache()
+	builder.addTagsToCache(&result)
+	return result
+}
+
 // Builds host/service map which are in downtime
 func (builder *CacheBuilder) createLivestatusCache() Cache {
 	result := Cache{downtime: make(map[string]map[string]string)}
@@ -162,3 +184,95 @@ func (builder *CacheBuilder) IsServiceInDowntime(host, service, time string) boo
 	builder.mutex.Unlock()
 	return result
 }
+
+// Queries the Livestatus.HostColumns and Livestatus.ServiceColumns and stores them as tags.
+func (builder *CacheBuilder) addTagsToCache(cache *Cache) {
+	cfg := config.GetConfig()
+	if hostColumns := TagColumns(cfg.Livestatus.HostColumns); len(hostColumns) > 0 {
+		query := builder.livestatusConnector.buildQuery(fmt.Sprintf(QueryForHostTags, strings.Join(hostColumns, " ")), cfg.Filter.LivestatusHostsFilter)
+		for _, line := range builder.queryLines(query) {
+			if len(line) != len(hostColumns)+1 {
+				builder.log.Debugf("host tags line does not fit the columns: %#v", line)
+				continue
+			}
+			cache.addTags(line[0], "", columnsToTags("host_", hostColumns, line[1:]))
+		}
+	}
+	if serviceColumns := TagColumns(cfg.Livestatus.ServiceColumns); len(serviceColumns) > 0 {
+		query := builder.livestatusConnector.buildQuery(fmt.Sprintf(QueryForServiceTags, strings.Join(serviceColumns, " ")), cfg.Filter.LivestatusServicesFilter)
+		for _, line := range builder.queryLines(query) {
+			if len(line) != len(serviceColumns)+2 {
+				builder.log.Debugf("service tags line does not fit the columns: %#v", line)
+				continue
+			}
+			cache.addTags(line[0], line[1], columnsToTags("service_", serviceColumns, line[2:]))
+		}
+	}
+}
+
+// Returns every line of the query, or the lines received until the query timed out.
+func (builder *CacheBuilder) queryLines(query string) [][]string {
+	lines := make(chan []string)
+	finished := make(chan bool)
+	go builder.livestatusConnector.connectToLivestatus(query, lines, finished)
+	var result [][]string
+	for {
+		select {
+		case line := <-lines:
+			result = append(result, line)
+		case <-finished:
+			return result
+		case <-time.After(intervalToCheckLivestatusCache / 3):
+			builder.log.Info("Livestatus timed out...(tags)")
+			return result
+		}
+	}
+}
+
+// TagColumns returns the column names of Livestatus.HostColumns or Livestatus.ServiceColumns,
+// each entry may contain several columns separated by commas or spaces.
+func TagColumns(entries []string) []string {
+	var columns []string
+	for _, entry := range entries {
+		columns = append(columns, strings.FieldsFunc(entry, func(r rune) bool { return r == ',' || r == ' ' })...)
+	}
+	return columns
+}
+
+// Turns the values of a livestatus line into tags named prefix+column, every custom variable
+// becomes a tag named prefix+"var_"+name. Empty values are skipped.
+func columnsToTags(prefix string, columns, values []string) map[string]string {
+	tags := map[string]string{}
+	for i, column := range columns {
+		if column != customVariablesColumn {
+			if values[i] != "" {
+				tags[prefix+column] = values[i]
+			}
+			continue
+		}
+		// the variables are printed as NAME|value,NAME|value
+		for variable := range strings.SplitSeq(values[i], ",") {
+			name, value, found := strings.Cut(variable, "|")
+			if found && name != "" && value != "" {
+				tags[prefix+"var_"+strings.ToLower(name)] = value
+			}
+		}
+	}
+	return tags
+}
+
+// Tags returns the tags of the host and, if service is not empty, the tags of the service.
+func (builder *CacheBuilder) Tags(host, service string) map[string]string {
+	builder.mutex.Lock()
+	defer builder.mutex.Unlock()
+	tags := map[string]string{}
+	for key, value := range builder.downtimeCache.tags[host][""] {
+		tags[key] = value
+	}
+	if service != "" {
+		for key, value := range builder.downtimeCache.tags[host][service] {
+			tags[key] = value
+		}
+	}
+	return tags
+}
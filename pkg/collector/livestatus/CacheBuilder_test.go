@@ -1,9 +1,12 @@
 package livestatus
 
 import (
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,7 +18,7 @@ func init() {
 
 func TestNewCacheBuilder(t *testing.T) {
 	logging.InitTestLogger()
-	connector := &Connector{logging.GetLogger(), "localhost:6558", "tcp"}
+	connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: "localhost:6558", ConnectionType: "tcp"}
 	builder := NewLivestatusCacheBuilder(connector)
 	require.NotNilf(t, builder, "Constructor returned pointer")
 }
@@ -28,7 +31,7 @@ func TestDisabledServiceInDowntime(t *testing.T) {
 	queries[QueryForDowntimeid] = "1;0;1\n2;2;3\n3;0;1\n4;1;2\n5;2;1\n"
 	livestatus := &MockLivestatus{"localhost:6558", "tcp", queries, true}
 	go livestatus.StartMockLivestatus()
-	connector := &Connector{logging.GetLogger(), livestatus.LivestatusAddress, livestatus.ConnectionType}
+	connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: livestatus.LivestatusAddress, ConnectionType: livestatus.ConnectionType}
 
 	cacheBuilder := NewLivestatusCacheBuilder(connector)
 
@@ -55,3 +58,33 @@ func TestDisabledServiceInDowntime(t *testing.T) {
 	assert.Falsef(t, cacheBuilder.IsServiceInDowntime("host1", "", "0"), `"host1","","0" should not be in downtime`)
 	assert.Truef(t, cacheBuilder.IsServiceInDowntime("host1", "", "2"), `"host1","","2" should not be in downtime`)
 }
+
+func TestCacheBuilderTags(t *testing.T) {
+	logging.InitTestLogger()
+	previous := config.GetConfig()
+	t.Cleanup(func() { config.SetConfig(previous) })
+	config.InitConfigFromString(`
+[Livestatus]
+    HostColumns = "groups, address"
+    HostColumns = "custom_variables"
+    ServiceColumns = "groups"
+`)
+	queries := map[string]string{
+		"GET hosts\nColumns: name groups address custom_variables\nOutputFormat: csv\n\n": "host1;linux,web;10.0.0.1;CUSTOMER|acme,EMPTY|\nhost2;;;\n",
+		"GET services\nColumns: host_name description groups\nOutputFormat: csv\n\n":      "host1;http;http-checks\n",
+	}
+	livestatus := &MockLivestatus{"localhost:6561", "tcp", queries, true}
+	go livestatus.StartMockLivestatus()
+	connector := &Connector{Log: logging.GetLogger(), LivestatusAddress: livestatus.LivestatusAddress, ConnectionType: livestatus.ConnectionType}
+	require.NoError(t, helper.WaitForPort("tcp", livestatus.LivestatusAddress, 2*time.Second))
+
+	builder := &CacheBuilder{connector, make(chan bool, 2), logging.GetLogger(), Cache{}, &sync.Mutex{}}
+	builder.downtimeCache = builder.createCache()
+
+	hostTags := map[string]string{"host_groups": "linux,web", "host_address": "10.0.0.1", "host_var_customer": "acme"}
+	assert.Equal(t, hostTags, builder.Tags("host1", ""))
+	hostTags["service_groups"] = "http-checks"
+	assert.Equal(t, hostTags, builder.Tags("host1", "http"))
+	assert.Empty(t, builder.Tags("host2", ""), "empty values are not added")
+	assert.Empty(t, builder.Tags("unknown", "http"))
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func TestAddDowntime(t *testing.T) {
-	cache := Cache{make(map[string]map[string]string)}
+	cache := Cache{downtime: make(map[string]map[string]string)}
 	if !reflect.DeepEqual(cache.downtime, make(map[string]map[string]string)) {
 		t.Error("Cache should be empty at the beginning.")
 	}
@@ -163,9 +163,11 @@ func (live *Collector) queryData() {
 	for jobsFinished < 3 {
 		select {
 		case job := <-printables:
+			live.jobs.RLock()
 			for _, j := range live.jobs {
 				j <- job
 			}
+			live.jobs.RUnlock()
 		case <-finished:
 			jobsFinished++
 		case <-time.After(intervalToCheckLivestatus):
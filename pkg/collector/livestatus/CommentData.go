@@ -14,6 +14,11 @@ type CommentData struct {
 	entryType string
 }
 
+// NewCommentData creates a comment, the entryType is the livestatus entry_type and entryTime is in seconds.
+func NewCommentData(hostName, serviceDisplayName, comment, entryTime, author, entryType string) *CommentData {
+	return &CommentData{collector.AllFilterable, Data{hostName, serviceDisplayName, comment, entryTime, author}, entryType}
+}
+
 func (comment *CommentData) sanitizeValues() {
 	comment.Data.sanitizeValues()
 	comment.entryType = helper.SanitizeInfluxInput(comment.entryType)
@@ -34,15 +39,27 @@ func (comment *CommentData) PrintForInfluxDB(version string) string {
 }
 
 // PrintForElasticsearch prints in the elasticsearch json format
-func (comment *CommentData) PrintForElasticsearch(version, index string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("2.0") {
+func (comment *CommentData) PrintForElasticsearch(bulk helper.ElasticsearchBulk) string {
+	if bulk.IsSupported() {
 		typ := commentIDToText(comment.entryType)
-		return comment.genElasticLineWithValue(index, typ, comment.comment, comment.entryTime)
+		return comment.genElasticLineWithValue(bulk, typ, comment.comment, comment.entryTime)
 	}
-	logging.GetLogger().Criticalf("This influxversion [%s] given in the config is not supported", version)
+	logging.GetLogger().Criticalf("This elasticsearchversion [%s] given in the config is not supported", bulk.Version)
 	panic("")
 }
 
+// Events returns the comment as event
+func (comment *CommentData) Events() []Event {
+	return []Event{comment.genEvent(commentIDToText(comment.entryType), "", comment.comment, comment.entryTime)}
+}
+
+// PrintForJSON returns the comment as comment event, the kind is the type of the comment
+func (comment *CommentData) PrintForJSON() []collector.JSONEvent {
+	return []collector.JSONEvent{comment.genJSONEvent(
+		collector.JSONTypeComment, commentIDToText(comment.entryType), "", comment.comment, comment.entryTime,
+	)}
+}
+
 func commentIDToText(id string) string {
 	switch id {
 	case "1":
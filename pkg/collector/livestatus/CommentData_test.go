@@ -1,9 +1,12 @@
 package livestatus
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
 	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 )
 
@@ -77,13 +80,25 @@ func TestPrintElasticsearchComment(t *testing.T) {
 	logging.InitTestLogger()
 	config.InitConfigFromString(Config)
 	comment := CommentData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "hallo world", entryTime: "1458988932000"}, entryType: "1"}
-	if !didThatPanic(comment.PrintForElasticsearch, "1.0", "index") {
+	if !didThatPanic(comment.PrintForElasticsearch, helper.ElasticsearchBulk{Version: "1.0", Index: "index"}) {
 		t.Error("This should panic, due to unsuported elasticsearch version")
 	}
 	for _, data := range PrintCommentData {
-		actual := data.input.PrintForElasticsearch("2.0", "index")
+		actual := data.input.PrintForElasticsearch(helper.ElasticsearchBulk{Version: "2.0", Index: "index"})
 		if actual != data.outputElastic {
 			t.Errorf("Print(%s): expected: %s, actual: %s", data.input, data.outputElastic, actual)
 		}
 	}
 }
+
+func TestPrintJSONComment(t *testing.T) {
+	logging.InitTestLogger()
+	comment := CommentData{Data: Data{hostName: "host 1", author: "philip", comment: "hallo world", entryTime: "1458988932"}, entryType: "4"}
+	expected := []collector.JSONEvent{{
+		SchemaVersion: collector.JSONSchemaVersion, Type: collector.JSONTypeComment, Timestamp: 1458988932000,
+		Host: "host 1", Author: "philip", Kind: "acknowledgement", Message: "hallo world",
+	}}
+	if actual := comment.PrintForJSON(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("PrintForJSON: expected: %v, actual: %v", expected, actual)
+	}
+}
@@ -2,10 +2,13 @@ package livestatus
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"net"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/kdar/factorlog"
@@ -15,7 +18,8 @@ import (
 type Connector struct {
 	Log               *factorlog.FactorLog
 	LivestatusAddress string
-	ConnectionType    string
+	ConnectionType    string // tcp, tls or file
+	TLSConfig         *tls.Config
 }
 
 // Queries livestatus and returns an list of list outer list are lines inner elements within the line.
@@ -24,10 +28,17 @@ func (connector *Connector) connectToLivestatus(query string, result chan []stri
 	switch connector.ConnectionType {
 	case "tcp":
 		conn, _ = net.Dial("tcp", connector.LivestatusAddress)
+	case "tls":
+		tlsConn, err := tls.Dial("tcp", connector.LivestatusAddress, connector.TLSConfig)
+		if err != nil {
+			connector.Log.Warnf("could not connect to livestatus via TLS: %s", err)
+		} else {
+			conn = tlsConn
+		}
 	case "file":
 		conn, _ = net.Dial("unix", connector.LivestatusAddress)
 	default:
-		connector.Log.Critical("Connection type is unknown, options are: tcp, file. Input:" + connector.ConnectionType)
+		connector.Log.Critical("Connection type is unknown, options are: tcp, tls, file. Input:" + connector.ConnectionType)
 		outerFinish <- false
 		return
 	}
@@ -84,3 +95,42 @@ func (connector *Connector) buildQuery(baseQuery string, filter []string) string
 
 	return strings.TrimSpace(baseQuery) + "\n" + strings.TrimSpace(filterStr.String()) + "\n\n"
 }
+
+// the operators of a Filter header
+var filterOperators = []string{"=", "!=", "~", "!~", "~~", "!~~", "=~", "!=~", "<", ">", "<=", ">="}
+
+// ValidateFilter checks the filter lines which are appended to a query by buildQuery, it returns an error for every invalid line.
+func ValidateFilter(filter []string) []error {
+	var errs []error
+	for _, str := range filter {
+		str = strings.ReplaceAll(strings.TrimSpace(str), `\\n`, "\n")
+		for line := range strings.SplitSeq(str, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			header, arguments, found := strings.Cut(line, ":")
+			arguments = strings.TrimSpace(arguments)
+			switch {
+			case !found:
+				errs = append(errs, fmt.Errorf("%q is not a livestatus header", line))
+			case header == "Filter":
+				fields := strings.Fields(arguments)
+				if len(fields) < 2 || !slices.Contains(filterOperators, fields[1]) {
+					errs = append(errs, fmt.Errorf("%q: expected Filter: <column> <operator> <value>", line))
+				}
+			case header == "And", header == "Or":
+				if amount, err := strconv.Atoi(arguments); err != nil || amount < 1 {
+					errs = append(errs, fmt.Errorf("%q: expected the amount of filters to combine", line))
+				}
+			case header == "Negate":
+				if arguments != "" {
+					errs = append(errs, fmt.Errorf("%q: Negate has no arguments", line))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("%q: only Filter, And, Or and Negate can be appended to a query", line))
+			}
+		}
+	}
+	return errs
+}
@@ -2,8 +2,13 @@ package livestatus
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"sync"
 	"testing"
@@ -82,7 +87,7 @@ func TestConnectToLivestatus(t *testing.T) {
 	livestatus := MockLivestatus{"localhost:6560", "tcp", map[string]string{"test\n\n": "foo;bar\n"}, true}
 
 	go livestatus.StartMockLivestatus()
-	connector := Connector{logging.GetLogger(), livestatus.LivestatusAddress, livestatus.ConnectionType}
+	connector := Connector{Log: logging.GetLogger(), LivestatusAddress: livestatus.LivestatusAddress, ConnectionType: livestatus.ConnectionType}
 	if err := helper.WaitForPort("tcp", "localhost:6560", time.Duration(2)*time.Second); err != nil {
 		panic(err)
 	}
@@ -110,7 +115,7 @@ func TestConnectToLivestatus(t *testing.T) {
 	}
 	livestatus.StopMockLivestatus()
 
-	connector2 := Connector{logging.GetLogger(), "/live", "file"}
+	connector2 := Connector{Log: logging.GetLogger(), LivestatusAddress: "/live", ConnectionType: "file"}
 	csv2 := make(chan []string)
 	finished2 := make(chan bool)
 	go connector2.connectToLivestatus("test\n\n", csv2, finished2)
@@ -141,3 +146,70 @@ Filter: name ~ ^web
 	result = connector.buildQuery(query, filter)
 	assert.Equalf(t, expected, result, "query builder returns expected query when adding filtering")
 }
+
+func TestValidateFilter(t *testing.T) {
+	assert.Empty(t, ValidateFilter([]string{
+		`Filter: state = 0`,
+		"Filter: host_custom_variables = PERF_ENABLED 1\nFilter: service_custom_variables = PERF_ENABLED 1\nOr: 2\n",
+		`Filter: name ~ ^web\\nNegate:`,
+	}))
+
+	errs := ValidateFilter([]string{
+		`Filter: state`,
+		"Filter: state == 0\nOr: x",
+		`Columns: name`,
+		`state = 0`,
+	})
+	assert.Len(t, errs, 5)
+}
+
+func TestConnectToLivestatusTLS(t *testing.T) {
+	// the certificate of the test server is valid for 127.0.0.1
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// the query ends with an empty line
+			reader := bufio.NewReader(conn)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || line == "\n" {
+					break
+				}
+			}
+			fmt.Fprint(conn, "foo;bar\n")
+			conn.Close()
+		}
+	}()
+
+	query := func(tlsConfig *tls.Config) ([]string, bool) {
+		connector := Connector{Log: logging.GetLogger(), LivestatusAddress: listener.Addr().String(), ConnectionType: "tls", TLSConfig: tlsConfig}
+		lines := make(chan []string, 1)
+		finished := make(chan bool)
+		go connector.connectToLivestatus("test\n\n", lines, finished)
+		result := <-finished
+		select {
+		case line := <-lines:
+			return line, result
+		default:
+			return nil, result
+		}
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	line, ok := query(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12})
+	assert.True(t, ok)
+	assert.Equal(t, []string{"foo", "bar"}, line)
+
+	_, ok = query(&tls.Config{MinVersion: tls.VersionTLS12})
+	assert.False(t, ok, "the certificate is not trusted")
+}
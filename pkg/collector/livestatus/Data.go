@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
 	"github.com/ConSol-Monitoring/nagflux/pkg/config"
 	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 )
@@ -44,14 +45,46 @@ func (live *Data) genInfluxLineWithValue(tags, text string) string {
 	return fmt.Sprintf("%s%s message=\"%s\" %s", live.getTablename(), tags, text, helper.CastStringTimeFromSToMs(live.entryTime))
 }
 
-func (live *Data) genElasticLineWithValue(index, typ, value, timestamp string) string {
+func (live *Data) genElasticLineWithValue(bulk helper.ElasticsearchBulk, typ, value, timestamp string) string {
 	value = strings.ReplaceAll(value, `"`, `\"`)
 	if live.serviceDisplayName == "" {
 		live.serviceDisplayName = config.GetConfig().ElasticsearchGlobal.HostcheckAlias
 	}
-	head := fmt.Sprintf(`{"index":{"_index":"%s","_type":"messages"}}`, helper.GenIndex(index, timestamp)) + "\n"
-	data := fmt.Sprintf(`{"timestamp":%s,"message":"%s","author":"%s","host":"%s","service":"%s","type":"%s"}`+"\n",
-		helper.CastStringTimeFromSToMs(timestamp), value, live.author, live.hostName, live.serviceDisplayName, typ,
+	head := bulk.Header("messages", timestamp)
+	data := fmt.Sprintf(`{"%s":%s,"message":"%s","author":"%s","host":"%s","service":"%s","type":"%s"}`+"\n",
+		bulk.TimestampField(), helper.CastStringTimeFromSToMs(timestamp), value, live.author, live.hostName, live.serviceDisplayName, typ,
 	)
 	return head + data
 }
+
+// Event is a single message of the livestatus data, independent of any target format.
+type Event struct {
+	Host    string
+	Service string
+	Author  string
+	Type    string
+	Level   string
+	Message string
+	// Time in seconds since epoch
+	Time string
+}
+
+// Generates a JSON event with the given type, kind and message.
+func (live *Data) genJSONEvent(typ, kind, level, message, timestamp string) collector.JSONEvent {
+	event := collector.NewJSONEvent(typ, collector.ParseTimestamp(timestamp))
+	event.Host = live.hostName
+	event.Service = live.serviceDisplayName
+	event.Author = live.author
+	event.Kind = kind
+	event.Level = level
+	event.Message = message
+	return event
+}
+
+// Generates an event with the given type and message.
+func (live *Data) genEvent(typ, level, message, timestamp string) Event {
+	return Event{
+		Host: live.hostName, Service: live.serviceDisplayName, Author: live.author,
+		Type: typ, Level: level, Message: message, Time: timestamp,
+	}
+}
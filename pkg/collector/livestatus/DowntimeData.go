@@ -17,6 +17,11 @@ type DowntimeData struct {
 	endTime string
 }
 
+// NewDowntimeData creates a downtime, entryTime and endTime are in seconds.
+func NewDowntimeData(hostName, serviceDisplayName, comment, entryTime, author, endTime string) *DowntimeData {
+	return &DowntimeData{collector.AllFilterable, Data{hostName, serviceDisplayName, comment, entryTime, author}, endTime}
+}
+
 func (downtime *DowntimeData) sanitizeValues() {
 	downtime.Data.sanitizeValues()
 	downtime.endTime = helper.SanitizeInfluxInput(downtime.endTime)
@@ -36,13 +41,28 @@ func (downtime *DowntimeData) PrintForInfluxDB(version string) string {
 }
 
 // PrintForElasticsearch prints in the elasticsearch json format
-func (downtime *DowntimeData) PrintForElasticsearch(version, index string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("2.0") {
+func (downtime *DowntimeData) PrintForElasticsearch(bulk helper.ElasticsearchBulk) string {
+	if bulk.IsSupported() {
 		typ := `downtime`
-		start := downtime.genElasticLineWithValue(index, typ, strings.TrimSpace("Downtime start: <br>"+downtime.comment), downtime.entryTime)
-		end := downtime.genElasticLineWithValue(index, typ, strings.TrimSpace("Downtime end: <br>"+downtime.comment), downtime.endTime)
+		start := downtime.genElasticLineWithValue(bulk, typ, strings.TrimSpace("Downtime start: <br>"+downtime.comment), downtime.entryTime)
+		end := downtime.genElasticLineWithValue(bulk, typ, strings.TrimSpace("Downtime end: <br>"+downtime.comment), downtime.endTime)
 		return start + "\n" + end
 	}
-	logging.GetLogger().Criticalf("This elasticsearchversion [%s] given in the config is not supported", version)
+	logging.GetLogger().Criticalf("This elasticsearchversion [%s] given in the config is not supported", bulk.Version)
 	panic("elasticsearch version not supported")
 }
+
+// Events returns the start and the end of the downtime as events
+func (downtime *DowntimeData) Events() []Event {
+	return []Event{
+		downtime.genEvent("downtime", "", strings.TrimSpace("Downtime start: "+downtime.comment), downtime.entryTime),
+		downtime.genEvent("downtime", "", strings.TrimSpace("Downtime end: "+downtime.comment), downtime.endTime),
+	}
+}
+
+// PrintForJSON returns the downtime as a single downtime event with its start and end
+func (downtime *DowntimeData) PrintForJSON() []collector.JSONEvent {
+	event := downtime.genJSONEvent(collector.JSONTypeDowntime, "", "", downtime.comment, downtime.entryTime)
+	event.EndTimestamp = collector.ParseTimestamp(downtime.endTime)
+	return []collector.JSONEvent{event}
+}
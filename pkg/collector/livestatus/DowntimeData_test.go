@@ -3,7 +3,9 @@ package livestatus
 import (
 	"testing"
 
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
 	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 	"github.com/stretchr/testify/assert"
 )
@@ -32,11 +34,11 @@ func TestPrintElasticsearchDowntime(t *testing.T) {
 	logging.InitTestLogger()
 	config.InitConfigFromString(Config)
 	down := &DowntimeData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932000"}, endTime: "123"}
-	if !didThatPanic(down.PrintForElasticsearch, "1.0", "index") {
+	if !didThatPanic(down.PrintForElasticsearch, helper.ElasticsearchBulk{Version: "1.0", Index: "index"}) {
 		t.Errorf("This should panic, due to unsuported elasticsearch version")
 	}
 
-	result := down.PrintForElasticsearch("2.0", "index")
+	result := down.PrintForElasticsearch(helper.ElasticsearchBulk{Version: "2.0", Index: "index"})
 	expected := `{"index":{"_index":"index-2016.03","_type":"messages"}}
 {"timestamp":1458988932000000,"message":"Downtime start: <br>","author":"philip","host":"host 1","service":"service 1","type":"downtime"}
 
@@ -45,3 +47,21 @@ func TestPrintElasticsearchDowntime(t *testing.T) {
 `
 	assert.Equalf(t, expected, result, "The result did not match the expected")
 }
+
+func TestEventsDowntime(t *testing.T) {
+	logging.InitTestLogger()
+	down := &DowntimeData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "update", entryTime: "100"}, endTime: "123"}
+	assert.Equal(t, []Event{
+		{Host: "host 1", Service: "service 1", Author: "philip", Type: "downtime", Message: "Downtime start: update", Time: "100"},
+		{Host: "host 1", Service: "service 1", Author: "philip", Type: "downtime", Message: "Downtime end: update", Time: "123"},
+	}, down.Events())
+}
+
+func TestPrintJSONDowntime(t *testing.T) {
+	logging.InitTestLogger()
+	down := &DowntimeData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "update", entryTime: "100"}, endTime: "123"}
+	assert.Equal(t, []collector.JSONEvent{{
+		SchemaVersion: collector.JSONSchemaVersion, Type: collector.JSONTypeDowntime, Timestamp: 100000, EndTimestamp: 123000,
+		Host: "host 1", Service: "service 1", Author: "philip", Message: "update",
+	}}, down.PrintForJSON())
+}
@@ -18,6 +18,11 @@ type NotificationData struct {
 	notificationLevel string
 }
 
+// NewNotificationData creates a notification, the notificationType is HOST NOTIFICATION or SERVICE NOTIFICATION and entryTime is in seconds.
+func NewNotificationData(hostName, serviceDisplayName, comment, entryTime, author, notificationType, notificationLevel string) *NotificationData {
+	return &NotificationData{collector.AllFilterable, Data{hostName, serviceDisplayName, comment, entryTime, author}, notificationType, notificationLevel}
+}
+
 func (notification *NotificationData) sanitizeValues() {
 	notification.Data.sanitizeValues()
 	notification.notificationType = helper.SanitizeInfluxInput(notification.notificationType)
@@ -40,16 +45,32 @@ func (notification *NotificationData) PrintForInfluxDB(version string) string {
 }
 
 // PrintForElasticsearch prints in the elasticsearch json format
-func (notification *NotificationData) PrintForElasticsearch(version, index string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("2.0") {
+func (notification *NotificationData) PrintForElasticsearch(bulk helper.ElasticsearchBulk) string {
+	if bulk.IsSupported() {
 		text := notificationToText(notification.notificationType)
 		value := fmt.Sprintf("%s:<br> %s", strings.TrimSpace(notification.notificationLevel), notification.comment)
-		return notification.genElasticLineWithValue(index, text, value, notification.entryTime)
+		return notification.genElasticLineWithValue(bulk, text, value, notification.entryTime)
 	}
-	logging.GetLogger().Criticalf("This elasticsearchversion [%s] given in the config is not supported", version)
+	logging.GetLogger().Criticalf("This elasticsearchversion [%s] given in the config is not supported", bulk.Version)
 	panic("")
 }
 
+// Events returns the notification as event
+func (notification *NotificationData) Events() []Event {
+	return []Event{notification.genEvent(
+		notificationToText(notification.notificationType), strings.TrimSpace(notification.notificationLevel),
+		notification.comment, notification.entryTime,
+	)}
+}
+
+// PrintForJSON returns the notification as notification event, the kind is host_notification or service_notification
+func (notification *NotificationData) PrintForJSON() []collector.JSONEvent {
+	return []collector.JSONEvent{notification.genJSONEvent(
+		collector.JSONTypeNotification, notificationToText(notification.notificationType),
+		strings.TrimSpace(notification.notificationLevel), notification.comment, notification.entryTime,
+	)}
+}
+
 func notificationToText(input string) string {
 	switch input {
 	case `HOST NOTIFICATION`:
@@ -1,9 +1,12 @@
 package livestatus
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
 	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 )
 
@@ -116,11 +119,11 @@ func TestPrintForElasticsearchNotification(t *testing.T) {
 	logging.InitTestLogger()
 	config.InitConfigFromString(Config)
 	notification := NotificationData{Data: Data{hostName: "host 1", author: "philip", entryTime: "1458988932000"}, notificationType: "HOST NOTIFICATION", notificationLevel: "WARN"}
-	if !didThatPanic(notification.PrintForElasticsearch, "1.0", "index") {
+	if !didThatPanic(notification.PrintForElasticsearch, helper.ElasticsearchBulk{Version: "1.0", Index: "index"}) {
 		t.Error("Printed for unsuported elasticsearch version but got a response")
 	}
 
-	result := notification.PrintForElasticsearch("2.0", "index")
+	result := notification.PrintForElasticsearch(helper.ElasticsearchBulk{Version: "2.0", Index: "index"})
 	expected := `{"index":{"_index":"index-2016.03","_type":"messages"}}
 {"timestamp":1458988932000000,"message":"WARN:<br> ","author":"philip","host":"host 1","service":"hostcheck","type":"host_notification"}
 `
@@ -129,7 +132,7 @@ func TestPrintForElasticsearchNotification(t *testing.T) {
 	}
 
 	notification2 := NotificationData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932000"}, notificationType: "SERVICE NOTIFICATION", notificationLevel: "WARN"}
-	result2 := notification2.PrintForElasticsearch("2.0", "index")
+	result2 := notification2.PrintForElasticsearch(helper.ElasticsearchBulk{Version: "2.0", Index: "index"})
 	expected2 := `{"index":{"_index":"index-2016.03","_type":"messages"}}
 {"timestamp":1458988932000000,"message":"WARN:<br> ","author":"philip","host":"host 1","service":"service 1","type":"service_notification"}
 `
@@ -138,7 +141,7 @@ func TestPrintForElasticsearchNotification(t *testing.T) {
 	}
 
 	notification3 := NotificationData{Data: Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", entryTime: "1458988932000"}, notificationType: "NULL NOTIFICATION", notificationLevel: "WARN"}
-	result3 := notification3.PrintForElasticsearch("2.0", "index")
+	result3 := notification3.PrintForElasticsearch(helper.ElasticsearchBulk{Version: "2.0", Index: "index"})
 	expected3 := `{"index":{"_index":"index-2016.03","_type":"messages"}}
 {"timestamp":1458988932000000,"message":"WARN:<br> ","author":"philip","host":"host 1","service":"service 1","type":""}
 `
@@ -157,12 +160,27 @@ func didThisPanic(f func(string) string, arg string) (result bool) {
 	return false
 }
 
-func didThatPanic(f func(string, string) string, arg1, arg2 string) (result bool) {
+func didThatPanic(f func(helper.ElasticsearchBulk) string, bulk helper.ElasticsearchBulk) (result bool) {
 	defer func() {
 		if rec := recover(); rec != nil {
 			result = true
 		}
 	}()
-	f(arg1, arg2)
+	f(bulk)
 	return false
 }
+
+func TestPrintJSONNotification(t *testing.T) {
+	logging.InitTestLogger()
+	notification := NotificationData{
+		Data:             Data{hostName: "host 1", serviceDisplayName: "service 1", author: "philip", comment: "down", entryTime: "1458988932"},
+		notificationType: "SERVICE NOTIFICATION", notificationLevel: "CRITICAL ",
+	}
+	expected := []collector.JSONEvent{{
+		SchemaVersion: collector.JSONSchemaVersion, Type: collector.JSONTypeNotification, Timestamp: 1458988932000,
+		Host: "host 1", Service: "service 1", Author: "philip", Kind: "service_notification", Level: "CRITICAL", Message: "down",
+	}}
+	if actual := notification.PrintForJSON(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("PrintForJSON: expected: %v, actual: %v", expected, actual)
+	}
+}
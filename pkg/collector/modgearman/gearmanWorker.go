@@ -20,7 +20,6 @@ import (
 type GearmanWorker struct {
 	runQuit               chan bool
 	loadQuit              chan bool
-	pauseQuit             chan bool
 	results               collector.ResultQueues
 	nagiosSpoolfileWorker *spoolfile.NagiosSpoolfileWorker
 	aesECBDecrypter       *cryptohelper.AESECBDecrypter
@@ -47,10 +46,9 @@ func NewGearmanWorker(address, queue, key string, results collector.ResultQueues
 		}
 	}
 	worker := &GearmanWorker{
-		runQuit:   make(chan bool, 1),
-		loadQuit:  make(chan bool, 1),
-		pauseQuit: make(chan bool, 1),
-		results:   results,
+		runQuit:  make(chan bool, 1),
+		loadQuit: make(chan bool, 1),
+		results:  results,
 		nagiosSpoolfileWorker: spoolfile.NewNagiosSpoolfileWorker(
 			-1, make(chan string), make(collector.ResultQueues), livestatusCacheBuilder, 4096, collector.AllFilterable, spoolfile.PerfdataLabelMaxLengthDefault, spoolfile.PerfdataUOMMaxLengthDefault, spoolfile.PerfdataNumericValuesMaxLengthDefault, spoolfile.PerfdataThresholdsMaxLengthDefault),
 		aesECBDecrypter: decrypter,
@@ -62,7 +60,6 @@ func NewGearmanWorker(address, queue, key string, results collector.ResultQueues
 	}
 	go worker.run()
 	go worker.handleLoad()
-	go worker.handlePause()
 
 	return worker
 }
@@ -110,7 +107,6 @@ func (g *GearmanWorker) Stop() {
 	g.shutdownGearmanWorker()
 	g.runQuit <- true
 	g.loadQuit <- true
-	g.pauseQuit <- true
 	logging.GetLogger().Debug("GearmanWorker stopped")
 }
 
@@ -137,7 +133,7 @@ func (g *GearmanWorker) run() {
 func (g *GearmanWorker) handleLoad() {
 	bufferLimit := int(float32(config.GetConfig().Main.BufferSize) * 0.90)
 	for {
-		for _, r := range g.results {
+		for _, r := range g.results.Copy() {
 			if len(r) > bufferLimit && g.worker != nil {
 				g.worker.Lock()
 				for len(r) > bufferLimit {
@@ -154,26 +150,6 @@ func (g *GearmanWorker) handleLoad() {
 	}
 }
 
-func (g *GearmanWorker) handlePause() {
-	pause := false
-	for {
-		select {
-		case <-g.pauseQuit:
-			return
-		case <-time.After(time.Duration(1) * time.Second):
-			globalPause := config.IsAnyTargetOnPause()
-			if pause != globalPause && g.worker != nil {
-				if globalPause {
-					g.worker.Lock()
-				} else {
-					g.worker.Unlock()
-				}
-				pause = globalPause
-			}
-		}
-	}
-}
-
 func (g *GearmanWorker) handleJob(job libworker.Job) ([]byte, error) {
 	secret := job.Data()
 	if g.aesECBDecrypter != nil {
@@ -196,6 +172,7 @@ func (g *GearmanWorker) handleJob(job libworker.Job) ([]byte, error) {
 	}
 
 	for singlePerfdata := range g.nagiosSpoolfileWorker.PerformanceDataIterator(splittedPerformanceData) {
+		g.results.RLock()
 		for _, r := range g.results {
 			select {
 			case r <- singlePerfdata:
@@ -203,6 +180,7 @@ func (g *GearmanWorker) handleJob(job libworker.Job) ([]byte, error) {
 				logging.GetLogger().Warn("GearmanWorker: Could not write to buffer")
 			}
 		}
+		g.results.RUnlock()
 	}
 	return job.Data(), nil
 }
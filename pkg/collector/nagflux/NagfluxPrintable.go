@@ -1,6 +1,7 @@
 package nagflux
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
@@ -17,6 +18,20 @@ type Printable struct {
 	fields    map[string]string
 }
 
+// Validate returns an error if the table, the time or the fields are missing.
+func (p *Printable) Validate() error {
+	if p.Table == "" {
+		return errors.New("table is missing")
+	}
+	if p.Timestamp == "" {
+		return errors.New("time is missing")
+	}
+	if len(p.fields) == 0 {
+		return errors.New("at least one field is required")
+	}
+	return nil
+}
+
 // PrintForInfluxDB prints the data in influxdb lineformat
 func (p *Printable) PrintForInfluxDB(version string) string {
 	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("0.9") {
@@ -34,10 +49,10 @@ func (p *Printable) PrintForInfluxDB(version string) string {
 }
 
 // PrintForElasticsearch prints in the elasticsearch json format
-func (p *Printable) PrintForElasticsearch(version, index string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("2.0") {
-		head := fmt.Sprintf(`{"index":{"_index":"%s","_type":"%s"}}`, helper.GenIndex(index, p.Timestamp), p.Table) + "\n"
-		data := `{"timestamp":` + p.Timestamp
+func (p *Printable) PrintForElasticsearch(bulk helper.ElasticsearchBulk) string {
+	if bulk.IsSupported() {
+		head := bulk.Header(p.Table, p.Timestamp)
+		data := fmt.Sprintf(`{"%s":%s`, bulk.TimestampField(), p.Timestamp)
 		data += helper.CreateJSONFromStringMap(p.tags)
 		data += helper.CreateJSONFromStringMap(p.fields)
 		data += "}\n"
@@ -45,3 +60,14 @@ func (p *Printable) PrintForElasticsearch(version, index string) string {
 	}
 	return ""
 }
+
+// PrintForJSON returns the record as metric event of its table
+func (p *Printable) PrintForJSON() []collector.JSONEvent {
+	event := collector.NewJSONEvent(collector.JSONTypeMetric, collector.ParseTimestamp(p.Timestamp))
+	event.Table = p.Table
+	if len(p.tags) > 0 {
+		event.Tags = p.tags
+	}
+	event.Fields = collector.JSONFields(p.fields)
+	return []collector.JSONEvent{event}
+}
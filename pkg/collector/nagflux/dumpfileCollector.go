@@ -6,7 +6,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"time"
+	"strings"
+	"sync"
 
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
 	"github.com/ConSol-Monitoring/nagflux/pkg/data"
@@ -17,6 +18,7 @@ import (
 // DumpfileCollector collects queries from old runs, which could not been completed.
 type DumpfileCollector struct {
 	quit           chan bool
+	done           chan bool
 	jobs           chan collector.Printable
 	dumpFile       string
 	log            *factorlog.FactorLog
@@ -30,10 +32,68 @@ func GenDumpfileName(filename string, ending data.Target) string {
 	return fmt.Sprintf("%s-%s.%s", filename, ending.Name, ending.Datatype)
 }
 
+// ReplaySuffix is appended to the name of the dumpfile while the DumpfileCollector reads it, so lines which are
+// dumped meanwhile go to a new dumpfile.
+const ReplaySuffix = "-replay"
+
+var dumpfileMutex = &sync.Mutex{}
+
+// AppendDumpfile appends the lines to the dumpfile, which is replayed by the DumpfileCollector on the next start.
+// Every writer of a dumpfile has to use it, so no line is appended while the DumpfileCollector takes the file.
+func AppendDumpfile(filename string, lines []string) error {
+	dumpfileMutex.Lock()
+	defer dumpfileMutex.Unlock()
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err = f.WriteString(line); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Moves the dumpfile to the replay file and returns its name. A replay file which is left from an interrupted
+// replay is read again, the dumpfile is appended to it.
+func (dump *DumpfileCollector) takeDumpfile() (string, error) {
+	dumpfileMutex.Lock()
+	defer dumpfileMutex.Unlock()
+	replayFile := dump.dumpFile + ReplaySuffix
+	if _, err := os.Stat(replayFile); err != nil {
+		return replayFile, os.Rename(dump.dumpFile, replayFile)
+	}
+	content, err := os.ReadFile(dump.dumpFile)
+	if os.IsNotExist(err) {
+		return replayFile, nil
+	} else if err != nil {
+		return replayFile, err
+	}
+	f, err := os.OpenFile(replayFile, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return replayFile, err
+	}
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		return replayFile, err
+	}
+	if err = f.Close(); err != nil {
+		return replayFile, err
+	}
+	return replayFile, os.Remove(dump.dumpFile)
+}
+
 // NewDumpfileCollector constructor, which also starts the collector
 func NewDumpfileCollector(jobs chan collector.Printable, dumpFile string, target data.Target, fileBufferSize int) *DumpfileCollector {
 	s := &DumpfileCollector{
-		quit:           make(chan bool, 2),
+		quit:           make(chan bool, 1),
+		done:           make(chan bool),
 		jobs:           jobs,
 		dumpFile:       GenDumpfileName(dumpFile, target),
 		log:            logging.GetLogger(),
@@ -45,22 +105,26 @@ func NewDumpfileCollector(jobs chan collector.Printable, dumpFile string, target
 	return s
 }
 
-// Stop stops the Collector.
+// Stop stops the Collector and waits until it's done.
 func (dump *DumpfileCollector) Stop() {
-	if dump.IsRunning {
-		dump.quit <- true
-		<-dump.quit
-		dump.IsRunning = false
-		dump.log.Debug("DumpfileCollector stopped")
+	select {
+	case <-dump.done:
+	case dump.quit <- true:
+		<-dump.done
 	}
 }
 
 // Searches for old file and parses it.
 func (dump *DumpfileCollector) run() {
-	if _, err := os.Stat(dump.dumpFile); os.IsNotExist(err) {
+	defer close(dump.done)
+	replayFile, err := dump.takeDumpfile()
+	if os.IsNotExist(err) {
 		dump.log.Debugf("Dumpfile: %s not found, skipping... (Everything is fine)", dump.dumpFile)
 	} else {
-		if filehandle, err := os.Open(dump.dumpFile); err != nil {
+		if err != nil {
+			dump.log.Warn(err)
+		}
+		if filehandle, err := os.Open(replayFile); err != nil {
 			dump.log.Warn(err)
 		} else {
 			dump.log.Infof("Loding dumpfile: %s", dump.dumpFile)
@@ -68,17 +132,18 @@ func (dump *DumpfileCollector) run() {
 				reader := bufio.NewReaderSize(filehandle, dump.fileBufferSize)
 				line, isPrefix, err := reader.ReadLine()
 				for err == nil && !isPrefix {
+					text := string(line)
+					// waits for the queue, as the lines are gone once the file is removed
 					select {
 					case <-dump.quit:
-						dump.quit <- true
+						dump.keepRest(replayFile, io.MultiReader(strings.NewReader(text+"\n"), reader))
+						filehandle.Close()
 						return
 					case dump.jobs <- &collector.SimplePrintable{
 						Filterable: collector.AllFilterable,
-						Text:       string(line),
+						Text:       text,
 						Datatype:   dump.target.Datatype,
 					}:
-					case <-time.After(time.Duration(20) * time.Second):
-						logging.GetLogger().Warn("DumpfileCollector: Could not write to buffer")
 					}
 					line, isPrefix, err = reader.ReadLine()
 				}
@@ -89,7 +154,7 @@ func (dump *DumpfileCollector) run() {
 				if isPrefix {
 					logging.GetLogger().Warn("NagfluxDumpfileCollector: filebuffer is too small")
 				} else {
-					err = os.Remove(dump.dumpFile)
+					err = os.Remove(replayFile)
 					if err != nil {
 						dump.log.Error(err)
 					}
@@ -101,18 +166,16 @@ func (dump *DumpfileCollector) run() {
 					dump.log.Error(err)
 				}
 				filehandle.Close()
+				// the replay file is kept, if the collector is stopped before the queue took it
 				select {
 				case <-dump.quit:
-					dump.quit <- true
 					return
 				case dump.jobs <- &collector.SimplePrintable{
 					Filterable: collector.AllFilterable,
 					Text:       buffer.String(),
 					Datatype:   dump.target.Datatype,
 				}:
-					os.Remove(dump.dumpFile)
-				case <-time.After(time.Duration(10) * time.Second):
-					dump.log.Debugf("Timeout: %s", dump.dumpFile)
+					os.Remove(replayFile)
 				}
 			}
 		}
@@ -120,3 +183,25 @@ func (dump *DumpfileCollector) run() {
 	dump.IsRunning = false
 	dump.log.Debug("DumpfileCollector stopped")
 }
+
+// Replaces the replay file by the lines which were not queued yet, they are replayed on the next start.
+func (dump *DumpfileCollector) keepRest(replayFile string, rest io.Reader) {
+	tmpFile := replayFile + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		dump.log.Warn(err)
+		return
+	}
+	_, err = io.Copy(f, rest)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile, replayFile)
+	}
+	if err != nil {
+		// the whole replay file is sent again then
+		dump.log.Warn(err)
+		os.Remove(tmpFile)
+	}
+}
@@ -0,0 +1,62 @@
+package nagflux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitTestLogger()
+	os.Exit(m.Run())
+}
+
+func receiveLine(t *testing.T, jobs chan collector.Printable) string {
+	t.Helper()
+	select {
+	case job := <-jobs:
+		return job.PrintForInfluxDB("1.0")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the dumpfile was not replayed")
+		return ""
+	}
+}
+
+func TestDumpfileCollectorKeepsUnsentLines(t *testing.T) {
+	target := data.Target{Name: "test", Datatype: data.InfluxDB}
+	dumpFile := filepath.Join(t.TempDir(), "nagflux.dump")
+	fileName := GenDumpfileName(dumpFile, target)
+	require.NoError(t, AppendDumpfile(fileName, []string{"m v=1 1\n", "m v=2 2\n", "m v=3 3\n"}))
+
+	// the collector waits for the full queue instead of skipping the line
+	jobs := make(chan collector.Printable, 1)
+	dump := NewDumpfileCollector(jobs, dumpFile, target, 1024)
+	assert.Equal(t, "m v=1 1", receiveLine(t, jobs))
+	assert.Eventually(t, func() bool { return len(jobs) == 1 }, 5*time.Second, 10*time.Millisecond)
+	dump.Stop()
+
+	// the line in the queue was sent, the others are replayed on the next start
+	assert.Equal(t, "m v=2 2", receiveLine(t, jobs))
+	content, err := os.ReadFile(fileName + ReplaySuffix)
+	require.NoError(t, err)
+	assert.Equal(t, "m v=3 3\n", string(content))
+	assert.NoFileExists(t, fileName)
+
+	require.NoError(t, AppendDumpfile(fileName, []string{"m v=4 4\n"}))
+	dump = NewDumpfileCollector(jobs, dumpFile, target, 1024)
+	assert.Equal(t, "m v=3 3", receiveLine(t, jobs))
+	assert.Equal(t, "m v=4 4", receiveLine(t, jobs))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(fileName + ReplaySuffix)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+	dump.Stop()
+	assert.NoFileExists(t, fileName)
+}
@@ -2,12 +2,14 @@ package nagflux
 
 import (
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"time"
 
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
-	"github.com/ConSol-Monitoring/nagflux/pkg/config"
 	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 	"github.com/kdar/factorlog"
@@ -60,18 +62,15 @@ func (nfc *FileCollector) run() {
 			nfc.quit <- true
 			return
 		case <-time.After(spoolfile.IntervalToCheckDirectory):
-			pause := config.IsAnyTargetOnPause()
-			if pause {
-				logging.GetLogger().Debugln("NagfluxFileCollector in pause")
-				continue
-			}
 			oldFiles, _ := spoolfile.FilesInDirectoryOlderThanX(nfc.folder, spoolfile.MinFileAge)
 			for _, currentFile := range oldFiles {
 				logging.GetLogger().Debug("Reading file: ", currentFile)
 				for _, p := range nfc.parseFile(currentFile) {
+					nfc.results.RLock()
 					for _, r := range nfc.results {
 						select {
 						case <-nfc.quit:
+							nfc.results.RUnlock()
 							nfc.quit <- true
 							return
 						case r <- &p:
@@ -79,6 +78,7 @@ func (nfc *FileCollector) run() {
 							nfc.log.Warn("NagfluxFileCollector: Could not write to buffer")
 						}
 					}
+					nfc.results.RUnlock()
 				}
 				err := os.Remove(currentFile)
 				if err != nil {
@@ -90,23 +90,33 @@ func (nfc *FileCollector) run() {
 }
 
 func (nfc *FileCollector) parseFile(filename string) []Printable {
-	result := []Printable{}
 	csvfile, err := os.Open(filename)
 	if err != nil {
 		nfc.log.Warn(err)
-		return result
+		return []Printable{}
 	}
 	defer csvfile.Close()
-	reader := csv.NewReader(csvfile)
-	reader.Comma = nfc.fieldSeparator
+	result, err := ParseCSV(csvfile, nfc.fieldSeparator)
+	if err != nil {
+		nfc.log.Warnf("The file %s could not be parsed: %s", filename, err)
+	}
+	return result
+}
+
+// ParseCSV parses the nagflux format, the first record is the header which names the columns.
+func ParseCSV(input io.Reader, fieldSeparator rune) ([]Printable, error) {
+	result := []Printable{}
+	reader := csv.NewReader(input)
+	reader.Comma = fieldSeparator
 	records, err := reader.ReadAll()
 	if err != nil {
-		nfc.log.Warn(err)
-		return result
+		return result, err
+	}
+	if len(records) == 0 {
+		return result, errors.New("no header found")
 	}
 	if !helper.Contains(records[0], requiredFields) {
-		nfc.log.Warnf("The file %s doesn't contain all of these fields: %s", filename, requiredFields)
-		return result
+		return result, fmt.Errorf("the header doesn't contain all of these fields: %s", requiredFields)
 	}
 
 	tagIndices := map[int]string{}
@@ -122,7 +132,7 @@ func (nfc *FileCollector) parseFile(filename string) []Printable {
 		} else if helper.Contains(optionalFields, []string{v}) {
 			continue
 		} else {
-			nfc.log.Warnf("This column does not fit the requirements: %s. Tags should start with t_, fields with f_", v)
+			logging.GetLogger().Warnf("This column does not fit the requirements: %s. Tags should start with t_, fields with f_", v)
 		}
 	}
 
@@ -144,7 +154,7 @@ func (nfc *FileCollector) parseFile(filename string) []Printable {
 				} else if val, ok := fieldIndices[i]; ok {
 					currentPrintable.fields[val] = v
 				} else {
-					nfc.log.Warnf("This should not happen: %s->%s", records[0][i], v)
+					logging.GetLogger().Warnf("This should not happen: %s->%s", records[0][i], v)
 				}
 			}
 		}
@@ -155,5 +165,5 @@ func (nfc *FileCollector) parseFile(filename string) []Printable {
 
 		result = append(result, currentPrintable)
 	}
-	return result
+	return result, nil
 }
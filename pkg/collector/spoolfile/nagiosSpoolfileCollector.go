@@ -2,6 +2,7 @@ package spoolfile
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path"
 	"time"
@@ -70,48 +71,9 @@ func NagiosSpoolfileCollectorFactory(cfg config.Config, results collector.Result
 	}
 	workerAmount := *(workerAmountPtr)
 
-	perfdataLabelMaxLength := PerfdataLabelMaxLengthDefault
-	search, found = helper.GetPreferredConfigValue(cfg, "NagiosSpoolfile.PerfdataLabelMaxLength", []string{})
-	if found {
-		perfdataLabelMaxLengthPtr, ok := search.(*int)
-		if ok {
-			perfdataLabelMaxLength = *(perfdataLabelMaxLengthPtr)
-		} else {
-			return nil, errors.New("expected a *int value out of the config value for Nagios Spoolfile Perfdata Label Max Length")
-		}
-	}
-
-	perfdataUOMMaxLength := PerfdataUOMMaxLengthDefault
-	search, found = helper.GetPreferredConfigValue(cfg, "NagiosSpoolfile.PerfdataUOMMaxLength", []string{})
-	if found {
-		perfdataUOMMaxLengthPtr, ok := search.(*int)
-		if ok {
-			perfdataUOMMaxLength = *(perfdataUOMMaxLengthPtr)
-		} else {
-			return nil, errors.New("expected a *int value out of the config value for Nagios Spoolfile Perfdata UOM Max Length")
-		}
-	}
-
-	perfdataNumericValuesMaxLength := PerfdataNumericValuesMaxLengthDefault
-	search, found = helper.GetPreferredConfigValue(cfg, "NagiosSpoolfile.PerfdataNumericValuesMaxLength", []string{})
-	if found {
-		perfdataNumericValuesMaxLengthPtr, ok := search.(*int)
-		if ok {
-			perfdataNumericValuesMaxLength = *(perfdataNumericValuesMaxLengthPtr)
-		} else {
-			return nil, errors.New("expected a *int value out of the config value for Nagios Spoolfile Perfdata UOM Max Length")
-		}
-	}
-
-	perfdataThresholdsMaxLength := PerfdataThresholdsMaxLengthDefault
-	search, found = helper.GetPreferredConfigValue(cfg, "NagiosSpoolfile.PerfdataThresholdsMaxLength", []string{})
-	if found {
-		perfdataThresholdsMaxLengthPtr, ok := search.(*int)
-		if ok {
-			perfdataThresholdsMaxLength = *(perfdataThresholdsMaxLengthPtr)
-		} else {
-			return nil, errors.New("expected a *int value out of the config value for Nagios Spoolfile Perfdata Thresholds Max Length")
-		}
+	limits, err := PerfdataLimitsFromConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	s := &NagiosSpoolfileCollector{
@@ -121,7 +83,7 @@ func NagiosSpoolfileCollectorFactory(cfg config.Config, results collector.Result
 		workers:        make([]*NagiosSpoolfileWorker, workerAmount),
 	}
 
-	gen := NagiosSpoolfileWorkerGenerator(s.jobs, results, livestatusCacheBuilder, fileBufferSize, defaultTarget, perfdataLabelMaxLength, perfdataUOMMaxLength, perfdataNumericValuesMaxLength, perfdataThresholdsMaxLength)
+	gen := NagiosSpoolfileWorkerGenerator(s.jobs, results, livestatusCacheBuilder, fileBufferSize, defaultTarget, limits.Label, limits.UOM, limits.NumericValues, limits.Thresholds)
 
 	for w := range workerAmount {
 		s.workers[w] = gen()
@@ -131,6 +93,43 @@ func NagiosSpoolfileCollectorFactory(cfg config.Config, results collector.Result
 	return s, nil
 }
 
+// PerfdataLimits are the maximal lengths of the perfdata fields, longer ones are treated as anomaly.
+type PerfdataLimits struct {
+	Label         int
+	UOM           int
+	NumericValues int
+	Thresholds    int
+}
+
+// PerfdataLimitsFromConfig reads the limits of the NagiosSpoolfile section, the unset ones get their default.
+func PerfdataLimitsFromConfig(cfg config.Config) (PerfdataLimits, error) {
+	limits := PerfdataLimits{
+		Label: PerfdataLabelMaxLengthDefault, UOM: PerfdataUOMMaxLengthDefault,
+		NumericValues: PerfdataNumericValuesMaxLengthDefault, Thresholds: PerfdataThresholdsMaxLengthDefault,
+	}
+	for _, option := range []struct {
+		key   string
+		name  string
+		value *int
+	}{
+		{"NagiosSpoolfile.PerfdataLabelMaxLength", "Label", &limits.Label},
+		{"NagiosSpoolfile.PerfdataUOMMaxLength", "UOM", &limits.UOM},
+		{"NagiosSpoolfile.PerfdataNumericValuesMaxLength", "Numeric Values", &limits.NumericValues},
+		{"NagiosSpoolfile.PerfdataThresholdsMaxLength", "Thresholds", &limits.Thresholds},
+	} {
+		search, found := helper.GetPreferredConfigValue(cfg, option.key, []string{})
+		if !found {
+			continue
+		}
+		value, ok := search.(*int)
+		if !ok {
+			return limits, fmt.Errorf("expected a *int value out of the config value for Nagios Spoolfile Perfdata %s Max Length", option.name)
+		}
+		*option.value = *value
+	}
+	return limits, nil
+}
+
 // Stop stops his workers and itself.
 func (s *NagiosSpoolfileCollector) Stop() {
 	s.quit <- true
@@ -150,12 +149,6 @@ func (s *NagiosSpoolfileCollector) run() {
 			s.quit <- true
 			return
 		case <-time.After(IntervalToCheckDirectory):
-			pause := config.IsAnyTargetOnPause()
-			if pause {
-				logging.GetLogger().Debugln("NagiosSpoolfileCollector in pause")
-				continue
-			}
-
 			logging.GetLogger().Debug("Reading Directory: ", s.spoolDirectory)
 			oldFiles, totalFiles := FilesInDirectoryOlderThanX(s.spoolDirectory, MinFileAge)
 			promServer.SpoolFilesOnDisk.Set(float64(totalFiles))
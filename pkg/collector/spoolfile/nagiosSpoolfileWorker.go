@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ConSol-Monitoring/nagflux/pkg/cardinality"
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
 	"github.com/ConSol-Monitoring/nagflux/pkg/config"
@@ -118,6 +119,7 @@ type NagiosSpoolfileWorker struct {
 	perfdataUOMMaxLength           int
 	perfdataNumericValuesMaxLength int
 	perfdataThresholdsMaxLength    int
+	counters                       *counterStore
 }
 
 // NewNagiosSpoolfileWorker returns a new NagiosSpoolfileWorker.
@@ -138,9 +140,15 @@ func NewNagiosSpoolfileWorker(workerID int, jobs chan string, results collector.
 		perfdataUOMMaxLength:           perfdataUOMMaxLength,
 		perfdataNumericValuesMaxLength: perfdataNumericValuesMaxLength,
 		perfdataThresholdsMaxLength:    perfdataThresholdsMaxLength,
+		counters:                       sharedCounters,
 	}
 }
 
+// IsolateCounters gives the worker counter rates of its own, so a dry run does not change the rates of the collectors.
+func (w *NagiosSpoolfileWorker) IsolateCounters() {
+	w.counters = newCounterStore()
+}
+
 // NagiosSpoolfileWorkerGenerator generates a worker and starts it.
 func NagiosSpoolfileWorkerGenerator(jobs chan string, results collector.ResultQueues,
 	livestatusCacheBuilder *livestatus.CacheBuilder, fileBufferSize int, defaultTarget collector.Filterable, perfdataLabelMaxLength int, perfdataUOMMaxLength int, perfdataNumericValuesMaxLength int, perfdataThresholdsMaxLength int,
@@ -184,17 +192,16 @@ func (w *NagiosSpoolfileWorker) run() {
 			queries := 0
 			line, isPrefix, err := reader.ReadLine()
 			for err == nil && !isPrefix {
-				splittedPerformanceData := helper.StringToMap(string(line), "\t", "::")
-				if skipLine := w.filterProcessor.TestLine(line); !skipLine {
+				perfdata, ok := w.ParseLine(line)
+				if !ok {
 					log.Debugf("skipping line %s", string(line))
-
-					line, isPrefix, err = reader.ReadLine()
-					continue
 				}
-				for singlePerfdata := range w.PerformanceDataIterator(splittedPerformanceData) {
+				for _, singlePerfdata := range perfdata {
+					w.results.RLock()
 					for _, r := range w.results {
 						select {
 						case <-w.quit:
+							w.results.RUnlock()
 							w.quit <- true
 							return
 						case r <- singlePerfdata:
@@ -203,6 +210,7 @@ func (w *NagiosSpoolfileWorker) run() {
 							log.Warn("NagiosSpoolfileWorker: Could not write to buffer")
 						}
 					}
+					w.results.RUnlock()
 				}
 				line, isPrefix, err = reader.ReadLine()
 			}
@@ -230,6 +238,17 @@ func (w *NagiosSpoolfileWorker) run() {
 	}
 }
 
+// ParseLine returns the perfdata of a spoolfile line, ok is false if the line is skipped by the Filter.SpoolFileLineTerms.
+func (w *NagiosSpoolfileWorker) ParseLine(line []byte) (perfdata []*PerformanceData, ok bool) {
+	if !w.filterProcessor.TestLine(line) {
+		return nil, false
+	}
+	for singlePerfdata := range w.PerformanceDataIterator(helper.StringToMap(string(line), "\t", "::")) {
+		perfdata = append(perfdata, singlePerfdata)
+	}
+	return perfdata, true
+}
+
 // PerformanceDataIterator returns an iterator to loop over generated perf data.
 //
 //nolint:maintidx // the lambda inside has to check all fields of the performance data, adds a lot of branching code
@@ -269,6 +288,18 @@ func (w *NagiosSpoolfileWorker) PerformanceDataIterator(input map[string]string)
 		perfdataStringMatches := regexPerformancelable.FindAllStringSubmatch(perfdataStringErrorsRemoved, -1)
 		currentCheckMultiLabel := ""
 
+		cfg := config.GetConfig()
+		evaluateThresholds := cfg.NagiosSpoolfile.EvaluateThresholds
+		normalizeUnits := cfg.NagiosSpoolfile.NormalizeUnits
+		rewriteRules := currentRewriteRules(cfg)
+		cardinalityGuard := cardinality.Current(cfg)
+
+		// tags of the Livestatus.HostColumns and Livestatus.ServiceColumns
+		var livestatusTags map[string]string
+		if w.livestatusCacheBuilder != nil {
+			livestatusTags = w.livestatusCacheBuilder.Tags(input[hostname], currentService)
+		}
+
 		// try to find a check_multi prefix
 		if len(perfdataStringMatches) > 0 && len(perfdataStringMatches[0]) > 1 {
 			currentCheckMultiLabel = getCheckMultiRegexMatch(perfdataStringMatches[0][1])
@@ -299,6 +330,12 @@ func (w *NagiosSpoolfileWorker) PerformanceDataIterator(input map[string]string)
 			if tagString, ok := input[nagfluxTags]; ok {
 				tags = helper.StringToMap(tagString, " ", "=")
 			}
+			// the NAGFLUX:TAG of the check takes precedence
+			for key, value := range livestatusTags {
+				if _, exists := tags[key]; !exists {
+					tags[key] = value
+				}
+			}
 			field := map[string]string{}
 			if fieldString, ok := input[nagfluxField]; ok {
 				field = helper.StringToMap(fieldString, " ", "=")
@@ -334,6 +371,7 @@ func (w *NagiosSpoolfileWorker) PerformanceDataIterator(input map[string]string)
 			}
 
 			// perfdataStringMatch might not have all fields like perfdataStringMatch[Crit] available, iterate each field until the end
+			thresholds := map[PerformanceDataSliceFields]string{}
 			for i, data := range perfdataStringMatch {
 				fieldType, err := indexToPerformanceDataSliceField(i)
 				if err != nil {
@@ -378,6 +416,7 @@ func (w *NagiosSpoolfileWorker) PerformanceDataIterator(input map[string]string)
 
 				switch fieldType {
 				case Warn, Crit:
+					thresholds[fieldType] = data
 					// Range handling
 					fillLabel := fieldType.String() + "-fill"
 					// find how many numbers are there in the string, if there are two it is a range
@@ -414,6 +453,26 @@ func (w *NagiosSpoolfileWorker) PerformanceDataIterator(input map[string]string)
 				}
 			}
 
+			if evaluateThresholds && len(thresholds) > 0 {
+				addThresholdState(perf, thresholds[Warn], thresholds[Crit])
+			}
+			if normalizeUnits {
+				normalizeUnit(perf, w.counters)
+			}
+			if droppedBy := applyRewriteRules(perf, rewriteRules); droppedBy != "" {
+				log.Debugf("Perfdata is dropped by Rewrite %q. Host: %v, Service: %v, Label: %v", droppedBy, perf.Hostname, perf.Service, perf.PerformanceLabel)
+				goto perfdataStringMatchLoopEnd
+			}
+			if cardinalityGuard != nil {
+				switch cardinalityGuard.Check(perf.Hostname, perf.Service, perf.Command, perf.PerformanceLabel, time.Now()) {
+				case cardinality.Drop:
+					goto perfdataStringMatchLoopEnd
+				case cardinality.Overflow:
+					perf.PerformanceLabel = cardinality.OverflowLabel
+				case cardinality.Accept:
+				}
+			}
+
 			ch <- perf
 
 		perfdataStringMatchLoopEnd: // To skip item without sending it to the channel
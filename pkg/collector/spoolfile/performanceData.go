@@ -48,15 +48,15 @@ func (p *PerformanceData) PrintForInfluxDB(version string) string {
 }
 
 // PrintForElasticsearch prints in the elasticsearch json format
-func (p *PerformanceData) PrintForElasticsearch(version, index string) string {
-	if helper.VersionOrdinal(version) >= helper.VersionOrdinal("2.0") {
+func (p *PerformanceData) PrintForElasticsearch(bulk helper.ElasticsearchBulk) string {
+	if bulk.IsSupported() {
 		if p.Service == "" {
 			p.Service = config.GetConfig().InfluxDBGlobal.HostcheckAlias
 		}
-		head := fmt.Sprintf(`{"index":{"_index":"%s","_type":"metrics"}}`, helper.GenIndex(index, p.Time)) + "\n"
+		head := bulk.Header("metrics", p.Time)
 		data := fmt.Sprintf(
-			`{"timestamp":%s,"host":"%s","service":"%s","command":"%s","performanceLabel":"%s"`,
-			p.Time,
+			`{"%s":%s,"host":"%s","service":"%s","command":"%s","performanceLabel":"%s"`,
+			bulk.TimestampField(), p.Time,
 			helper.SanitizeElasicInput(p.Hostname),
 			helper.SanitizeElasicInput(p.Service),
 			helper.SanitizeElasicInput(p.Command),
@@ -72,3 +72,18 @@ func (p *PerformanceData) PrintForElasticsearch(version, index string) string {
 	}
 	return ""
 }
+
+// PrintForJSON returns the perfdata as metric event
+func (p *PerformanceData) PrintForJSON() []collector.JSONEvent {
+	event := collector.NewJSONEvent(collector.JSONTypeMetric, collector.ParseTimestamp(p.Time))
+	event.Host = p.Hostname
+	event.Service = p.Service
+	event.Command = p.Command
+	event.PerformanceLabel = p.PerformanceLabel
+	event.Unit = p.Unit
+	if len(p.Tags) > 0 {
+		event.Tags = p.Tags
+	}
+	event.Fields = collector.JSONFields(p.Fields)
+	return []collector.JSONEvent{event}
+}
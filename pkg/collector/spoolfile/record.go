@@ -0,0 +1,67 @@
+package spoolfile
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+)
+
+// MarshalRecord returns the performance data as a JSON line. Targets without a text format of their own
+// store the perfdata like this in their write-ahead log or dumpfile.
+func (p *PerformanceData) MarshalRecord() string {
+	record, err := json.Marshal(p)
+	if err != nil {
+		return ""
+	}
+	return string(record) + "\n"
+}
+
+// RenderRecord renders performance data by MarshalRecord, Printables which were already rendered for the datatype
+// are passed through and everything else is skipped by an empty string.
+func RenderRecord(p collector.Printable, datatype data.Datatype) string {
+	switch printable := p.(type) {
+	case *PerformanceData:
+		return printable.MarshalRecord()
+	case collector.Rendered:
+		if text, ok := printable.RenderedText(datatype); ok {
+			return text
+		}
+	}
+	return ""
+}
+
+// PerformanceDataFor returns the perfdata of the Printable for a target of the datatype: the Printable itself or
+// the decoded records if it was rendered by RenderRecord. Records which can't be decoded are returned as error.
+func PerformanceDataFor(p collector.Printable, datatype data.Datatype) ([]*PerformanceData, error) {
+	switch printable := p.(type) {
+	case *PerformanceData:
+		return []*PerformanceData{printable}, nil
+	case collector.Rendered:
+		if text, ok := printable.RenderedText(datatype); ok {
+			return unmarshalRecords(text)
+		}
+	}
+	return nil, nil
+}
+
+// Decodes the lines written by MarshalRecord, the filter was applied before they were written.
+func unmarshalRecords(text string) ([]*PerformanceData, error) {
+	var result []*PerformanceData
+	var errs []error
+	for line := range strings.Lines(text) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p := &PerformanceData{}
+		if err := json.Unmarshal([]byte(line), p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.Filterable = collector.AllFilterable
+		result = append(result, p)
+	}
+	return result, errors.Join(errs...)
+}
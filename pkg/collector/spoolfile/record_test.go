@@ -0,0 +1,39 @@
+package spoolfile
+
+import (
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	perf := &PerformanceData{
+		Filterable: collector.Filterable{Filter: "prom"},
+		Hostname:   "host", Service: "load", Command: "check_load", PerformanceLabel: "load1", Unit: "", Time: "1441791000000",
+		Tags: map[string]string{"warn-fill": "none"}, Fields: map[string]string{"value": "1.0", "warn": "5.0"},
+	}
+	line := RenderRecord(perf, data.Prometheus)
+	assert.Equal(t, perf.MarshalRecord(), line)
+
+	replayed := &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: line + "\n" + line, Datatype: data.Prometheus}
+	assert.Equal(t, replayed.Text, RenderRecord(replayed, data.Prometheus), "rendered records are passed through")
+	assert.Empty(t, RenderRecord(replayed, data.Graphite))
+	assert.Empty(t, RenderRecord(&collector.SimplePrintable{Text: "m v=1", Datatype: data.InfluxDB}, data.Prometheus))
+
+	perfs, err := PerformanceDataFor(replayed, data.Prometheus)
+	require.NoError(t, err)
+	expected := *perf
+	expected.Filterable = collector.AllFilterable
+	assert.Equal(t, []*PerformanceData{&expected, &expected}, perfs)
+
+	perfs, err = PerformanceDataFor(&collector.SimplePrintable{Text: line + "{broken\n", Datatype: data.Prometheus}, data.Prometheus)
+	assert.Error(t, err)
+	assert.Len(t, perfs, 1, "valid records are kept")
+
+	perfs, err = PerformanceDataFor(perf, data.Graphite)
+	assert.NoError(t, err)
+	assert.Equal(t, []*PerformanceData{perf}, perfs)
+}
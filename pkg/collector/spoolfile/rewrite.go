@@ -0,0 +1,280 @@
+package spoolfile
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+)
+
+// The actions of a Rewrite rule.
+const (
+	RewriteRename    = "rename"
+	RewriteDrop      = "drop"
+	RewriteKeep      = "keep"
+	RewriteAddTag    = "add_tag"
+	RewriteRemoveTag = "remove_tag"
+	RewriteCopyField = "copy_field"
+	RewriteHashLabel = "hash_label"
+	RewriteSetTarget = "set_target"
+)
+
+// The attributes a rule can match on or change, tags are addressed as tag:name.
+const (
+	attributeHost             = "host"
+	attributeService          = "service"
+	attributeCommand          = "command"
+	attributePerformanceLabel = "performanceLabel"
+	attributeTagPrefix        = "tag:"
+)
+
+// A regex which has to match an attribute.
+type attributeMatcher struct {
+	attribute string
+	regex     *regexp.Regexp
+}
+
+// rewriteRule is a compiled Rewrite section.
+type rewriteRule struct {
+	name     string
+	matchers []attributeMatcher
+	action   string
+	// the attribute of rename and hash_label, and the regex which matched it, if there is one
+	attribute   string
+	regex       *regexp.Regexp
+	replacement string
+	tagName     string
+	newName     string
+	value       string
+}
+
+// RewriteError is returned for an invalid Rewrite section.
+type RewriteError struct {
+	Rule string
+	Err  error
+}
+
+func (e *RewriteError) Error() string {
+	return fmt.Sprintf("Rewrite %q: %s", e.Rule, e.Err)
+}
+
+func (e *RewriteError) Unwrap() error {
+	return e.Err
+}
+
+// The rules of the current config, they are compiled again if the Rewrite sections change.
+var rewriteCache struct {
+	mutex  sync.Mutex
+	source any
+	rules  []rewriteRule
+}
+
+// ValidateRewriteRules returns an error for every invalid Rewrite section.
+func ValidateRewriteRules(cfg config.Config) []error {
+	_, errs := compileRewriteRules(cfg)
+	return errs
+}
+
+// Returns the valid rules of the config, the invalid ones are logged and skipped.
+func currentRewriteRules(cfg config.Config) []rewriteRule {
+	rewriteCache.mutex.Lock()
+	defer rewriteCache.mutex.Unlock()
+	if rewriteCache.source == nil || !reflect.DeepEqual(rewriteCache.source, cfg.Rewrite) {
+		var errs []error
+		rewriteCache.rules, errs = compileRewriteRules(cfg)
+		for _, err := range errs {
+			log.Errorf("Rewrite rule is skipped: %s", err)
+		}
+		rewriteCache.source = cfg.Rewrite
+	}
+	return rewriteCache.rules
+}
+
+//nolint:cyclop // every action has its own parameters to check
+func compileRewriteRules(cfg config.Config) ([]rewriteRule, []error) {
+	var rules []rewriteRule
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(cfg.Rewrite)) {
+		section := cfg.Rewrite[name]
+		if section == nil {
+			continue
+		}
+		fail := func(format string, args ...any) {
+			errs = append(errs, &RewriteError{Rule: name, Err: fmt.Errorf(format, args...)})
+		}
+		rule := rewriteRule{
+			name: name, action: section.Action, attribute: section.Attribute, replacement: section.Replacement,
+			tagName: section.Name, newName: section.NewName, value: section.Value,
+		}
+		valid := true
+		addMatcher := func(attribute, expression string) {
+			if expression == "" {
+				return
+			}
+			regex, err := regexp.Compile(expression)
+			if err != nil {
+				fail("%s: %s", attribute, err)
+				valid = false
+				return
+			}
+			rule.matchers = append(rule.matchers, attributeMatcher{attribute, regex})
+		}
+		addMatcher(attributeHost, section.Host)
+		addMatcher(attributeService, section.Service)
+		addMatcher(attributeCommand, section.Command)
+		addMatcher(attributePerformanceLabel, section.PerformanceLabel)
+		for _, tag := range section.Tag {
+			tagName, expression, found := strings.Cut(tag, "=")
+			if !found || tagName == "" {
+				fail("Tag %q has to be name=regex", tag)
+				valid = false
+				continue
+			}
+			addMatcher(attributeTagPrefix+tagName, expression)
+		}
+
+		switch section.Action {
+		case RewriteRename, RewriteHashLabel:
+			if rule.attribute == "" {
+				rule.attribute = attributePerformanceLabel
+			}
+			if !isRewriteAttribute(rule.attribute) {
+				fail("Attribute %q is not one of host, service, command, performanceLabel or tag:name", rule.attribute)
+				valid = false
+			}
+			for i, matcher := range rule.matchers {
+				if matcher.attribute != rule.attribute {
+					continue
+				}
+				rule.regex = matcher.regex
+				if section.Action == RewriteRename {
+					// like the relabel_configs of Prometheus the regex has to match the whole value, which is replaced
+					rule.regex = regexp.MustCompile("^(?:" + matcher.regex.String() + ")$")
+					rule.matchers[i].regex = rule.regex
+				}
+			}
+		case RewriteAddTag, RewriteRemoveTag:
+			if rule.tagName == "" {
+				fail("Name is required by %s", section.Action)
+				valid = false
+			}
+		case RewriteCopyField:
+			if rule.tagName == "" || rule.newName == "" {
+				fail("Name and NewName are required by %s", section.Action)
+				valid = false
+			}
+		case RewriteSetTarget:
+			if rule.value == "" {
+				fail("Value is required by %s", section.Action)
+				valid = false
+			}
+		case RewriteDrop, RewriteKeep:
+		default:
+			fail("Action %q is not supported", section.Action)
+			valid = false
+		}
+		if valid {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, errs
+}
+
+func isRewriteAttribute(attribute string) bool {
+	switch attribute {
+	case attributeHost, attributeService, attributeCommand, attributePerformanceLabel:
+		return true
+	}
+	tagName, found := strings.CutPrefix(attribute, attributeTagPrefix)
+	return found && tagName != ""
+}
+
+// applyRewriteRules changes the perfdata by the rules, it returns the name of the rule if the perfdata has to be dropped.
+func applyRewriteRules(perf *PerformanceData, rules []rewriteRule) (droppedBy string) {
+	for _, rule := range rules {
+		matches := rule.matches(perf)
+		if rule.action == RewriteKeep {
+			if !matches {
+				return rule.name
+			}
+			continue
+		}
+		if !matches {
+			continue
+		}
+		switch rule.action {
+		case RewriteDrop:
+			return rule.name
+		case RewriteRename:
+			value := rule.replacement
+			if rule.regex != nil {
+				attribute := perf.attribute(rule.attribute)
+				match := rule.regex.FindStringSubmatchIndex(attribute)
+				value = string(rule.regex.ExpandString(nil, rule.replacement, attribute, match))
+			}
+			perf.setAttribute(rule.attribute, value)
+		case RewriteHashLabel:
+			hash := fnv.New64a()
+			hash.Write([]byte(perf.attribute(rule.attribute)))
+			perf.setAttribute(rule.attribute, strconv.FormatUint(hash.Sum64(), 16))
+		case RewriteAddTag:
+			perf.Tags[rule.tagName] = rule.value
+		case RewriteRemoveTag:
+			delete(perf.Tags, rule.tagName)
+		case RewriteCopyField:
+			if value, ok := perf.Fields[rule.tagName]; ok {
+				perf.Fields[rule.newName] = value
+			}
+		case RewriteSetTarget:
+			perf.Filterable = collector.Filterable{Filter: rule.value}
+		}
+	}
+	return ""
+}
+
+// Returns true if every regex of the rule matches, a missing tag is matched as empty string.
+func (rule rewriteRule) matches(perf *PerformanceData) bool {
+	for _, matcher := range rule.matchers {
+		if !matcher.regex.MatchString(perf.attribute(matcher.attribute)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *PerformanceData) attribute(attribute string) string {
+	switch attribute {
+	case attributeHost:
+		return p.Hostname
+	case attributeService:
+		return p.Service
+	case attributeCommand:
+		return p.Command
+	case attributePerformanceLabel:
+		return p.PerformanceLabel
+	}
+	return p.Tags[strings.TrimPrefix(attribute, attributeTagPrefix)]
+}
+
+func (p *PerformanceData) setAttribute(attribute, value string) {
+	switch attribute {
+	case attributeHost:
+		p.Hostname = value
+	case attributeService:
+		p.Service = value
+	case attributeCommand:
+		p.Command = value
+	case attributePerformanceLabel:
+		p.PerformanceLabel = value
+	default:
+		p.Tags[strings.TrimPrefix(attribute, attributeTagPrefix)] = value
+	}
+}
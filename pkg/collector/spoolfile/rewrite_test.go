@@ -0,0 +1,164 @@
+package spoolfile
+
+import (
+	"hash/fnv"
+	"strconv"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gcfg.v1"
+)
+
+func TestCompileRewriteRules(t *testing.T) {
+	var cfg config.Config
+	require.NoError(t, gcfg.ReadStringInto(&cfg, `
+[Rewrite "ok"]
+    Action = keep
+[Rewrite "regex"]
+    Host = "(unclosed"
+    Action = drop
+[Rewrite "tag"]
+    Tag = "novalue"
+    Action = drop
+[Rewrite "action"]
+    Action = move
+[Rewrite "attribute"]
+    Action = rename
+    Attribute = unit
+[Rewrite "name"]
+    Action = add_tag
+[Rewrite "copy"]
+    Action = copy_field
+    Name = value
+[Rewrite "target"]
+    Action = set_target
+`))
+	rules, errs := compileRewriteRules(cfg)
+	assert.Len(t, rules, 1)
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		`Rewrite "action": Action "move" is not supported`,
+		`Rewrite "attribute": Attribute "unit" is not one of host, service, command, performanceLabel or tag:name`,
+		`Rewrite "copy": Name and NewName are required by copy_field`,
+		`Rewrite "name": Name is required by add_tag`,
+		"Rewrite \"regex\": host: error parsing regexp: missing closing ): `(unclosed`",
+		`Rewrite "tag": Tag "novalue" has to be name=regex`,
+		`Rewrite "target": Value is required by set_target`,
+	}, messages)
+}
+
+func TestApplyRewriteRules(t *testing.T) {
+	var cfg config.Config
+	require.NoError(t, gcfg.ReadStringInto(&cfg, `
+[Rewrite "1-keep"]
+    Tag = "env=^prod$"
+    Action = keep
+[Rewrite "2-remove"]
+    Action = remove_tag
+    Name = env
+[Rewrite "3-tag"]
+    Tag = "mount=^/data/(.*)$"
+    Action = rename
+    Attribute = tag:mount
+    Replacement = "$1"
+`))
+	rules, errs := compileRewriteRules(cfg)
+	require.Empty(t, errs)
+
+	perf := &PerformanceData{Tags: map[string]string{"env": "prod", "mount": "/data/db"}}
+	assert.Empty(t, applyRewriteRules(perf, rules))
+	assert.Equal(t, map[string]string{"mount": "db"}, perf.Tags)
+
+	perf = &PerformanceData{Tags: map[string]string{"env": "test"}}
+	assert.Equal(t, "1-keep", applyRewriteRules(perf, rules))
+	assert.Equal(t, "1-keep", applyRewriteRules(&PerformanceData{Tags: map[string]string{}}, rules), "a missing tag is matched as empty string")
+}
+
+func TestRenameMatchesWholeValue(t *testing.T) {
+	var cfg config.Config
+	require.NoError(t, gcfg.ReadStringInto(&cfg, `
+[Rewrite "suffix"]
+    PerformanceLabel = "(.*)_ms"
+    Action = rename
+    Replacement = "$1"
+`))
+	rules, errs := compileRewriteRules(cfg)
+	require.Empty(t, errs)
+
+	perf := &PerformanceData{PerformanceLabel: "rta_ms", Tags: map[string]string{}}
+	applyRewriteRules(perf, rules)
+	assert.Equal(t, "rta", perf.PerformanceLabel)
+
+	perf = &PerformanceData{PerformanceLabel: "foo_ms_x", Tags: map[string]string{}}
+	applyRewriteRules(perf, rules)
+	assert.Equal(t, "foo_ms_x", perf.PerformanceLabel, "the regex has to match the whole label")
+}
+
+func TestPerformanceDataIteratorRewrite(t *testing.T) {
+	config.InitConfigFromString(configFileContent + `
+[Rewrite "10-root"]
+    Host = "^rewrite$"
+    PerformanceLabel = "^/$"
+    Action = rename
+    Replacement = root
+[Rewrite "20-mount"]
+    Host = "^rewrite$"
+    PerformanceLabel = "^/(.+)$"
+    Action = rename
+    Replacement = "$1"
+[Rewrite "30-drop"]
+    Host = "^rewrite$"
+    PerformanceLabel = "^tmp$"
+    Action = drop
+[Rewrite "40-team"]
+    Host = "^rewrite$"
+    Action = add_tag
+    Name = team
+    Value = storage
+[Rewrite "50-copy"]
+    Host = "^rewrite$"
+    Action = copy_field
+    Name = value
+    NewName = raw
+[Rewrite "60-target"]
+    Host = "^rewrite$"
+    Action = set_target
+    Value = influx
+[Rewrite "70-hash"]
+    Host = "^rewrite$"
+    Action = hash_label
+    Attribute = host
+`)
+	// the config is merged into the same map, so the cache does not notice the new rules
+	rewriteCache.source = nil
+
+	w := NewNagiosSpoolfileWorker(0, nil, nil, nil, 4096, collector.AllFilterable, PerfdataLabelMaxLengthDefault, PerfdataUOMMaxLengthDefault, PerfdataNumericValuesMaxLengthDefault, PerfdataThresholdsMaxLengthDefault)
+	input := helper.StringToMap("DATATYPE::SERVICEPERFDATA	TIMET::1441791000	HOSTNAME::rewrite	SERVICEDESC::disk	SERVICEPERFDATA::/=1 /var=2 tmp=3	SERVICECHECKCOMMAND::check_disk	SERVICESTATE::0	SERVICESTATETYPE::1", "\t", "::")
+
+	hash := fnv.New64a()
+	hash.Write([]byte("rewrite"))
+	hashedHost := strconv.FormatUint(hash.Sum64(), 16)
+	collected := []PerformanceData{}
+	for perf := range w.PerformanceDataIterator(input) {
+		collected = append(collected, *perf)
+	}
+	assert.Equal(t, []PerformanceData{
+		{
+			Hostname: hashedHost, Service: "disk", Command: "check_disk", PerformanceLabel: "root", Time: "1441791000000",
+			Tags: map[string]string{"team": "storage"}, Fields: map[string]string{"value": "1.0", "raw": "1.0"},
+			Filterable: collector.Filterable{Filter: "influx"},
+		},
+		{
+			Hostname: hashedHost, Service: "disk", Command: "check_disk", PerformanceLabel: "var", Time: "1441791000000",
+			Tags: map[string]string{"team": "storage"}, Fields: map[string]string{"value": "2.0", "raw": "2.0"},
+			Filterable: collector.Filterable{Filter: "influx"},
+		},
+	}, collected)
+}
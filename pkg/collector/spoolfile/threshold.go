@@ -0,0 +1,108 @@
+package spoolfile
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+)
+
+// The states of a threshold evaluation, like the exit codes of the monitoring plugins.
+const (
+	StateOK       = 0
+	StateWarning  = 1
+	StateCritical = 2
+)
+
+// Values of the threshold_breach tag, named like the warn and crit fields.
+var thresholdBreachTags = map[int]string{StateOK: "none", StateWarning: "warn", StateCritical: "crit"}
+
+// thresholdRange is a warn or crit range of the monitoring plugins.
+// https://www.monitoring-plugins.org/doc/guidelines.html#THRESHOLDFORMAT
+type thresholdRange struct {
+	start, end float64
+	// alert if the value is inside of the range, instead of outside
+	inside bool
+}
+
+// parseRange parses ranges like 10, 10:, ~:10, 10:20 and @10:20.
+func parseRange(text string) (thresholdRange, error) {
+	r := thresholdRange{end: math.Inf(1)}
+	text = strings.TrimSpace(text)
+	if rest, found := strings.CutPrefix(text, "@"); found {
+		r.inside = true
+		text = rest
+	}
+	start, end, hasColon := strings.Cut(text, ":")
+	if !hasColon {
+		start, end = "", text
+	}
+	var err error
+	switch start {
+	case "~":
+		r.start = math.Inf(-1)
+	case "":
+	default:
+		if r.start, err = strconv.ParseFloat(start, 64); err != nil {
+			return r, fmt.Errorf("range %q: start is not a number", text)
+		}
+	}
+	if end != "" {
+		if r.end, err = strconv.ParseFloat(end, 64); err != nil {
+			return r, fmt.Errorf("range %q: end is not a number", text)
+		}
+	} else if !hasColon {
+		return r, fmt.Errorf("range %q is empty", text)
+	}
+	if r.start > r.end {
+		return r, fmt.Errorf("range %q: start is greater than the end", text)
+	}
+	return r, nil
+}
+
+// alerts returns true if the value breaches the range, the borders belong to the range.
+func (r thresholdRange) alerts(value float64) bool {
+	inRange := value >= r.start && value <= r.end
+	return inRange == r.inside
+}
+
+// evaluateThresholds returns the state of the value, an empty warn or crit is not evaluated.
+func evaluateThresholds(value float64, warn, crit string) (int, error) {
+	for _, threshold := range []struct {
+		text  string
+		state int
+	}{{crit, StateCritical}, {warn, StateWarning}} {
+		if threshold.text == "" {
+			continue
+		}
+		r, err := parseRange(threshold.text)
+		if err != nil {
+			return StateOK, err
+		}
+		if r.alerts(value) {
+			return threshold.state, nil
+		}
+	}
+	return StateOK, nil
+}
+
+// addThresholdState adds the state field and the threshold_breach tag, if the value is a number and the ranges are valid.
+func addThresholdState(perf *PerformanceData, warn, crit string) {
+	valueString, ok := perf.Fields[Value.String()]
+	if !ok {
+		return
+	}
+	value, err := strconv.ParseFloat(valueString, 64)
+	if err != nil {
+		return
+	}
+	state, err := evaluateThresholds(value, warn, crit)
+	if err != nil {
+		log.Debugf("Thresholds are not evaluated: %s. Host: %v, Service: %v", err, perf.Hostname, perf.Service)
+		return
+	}
+	perf.Fields["state"] = helper.StringIntToStringFloat(strconv.Itoa(state))
+	perf.Tags["threshold_breach"] = thresholdBreachTags[state]
+}
@@ -0,0 +1,66 @@
+package spoolfile
+
+import (
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateThresholds(t *testing.T) {
+	tests := []struct {
+		value      float64
+		warn, crit string
+		state      int
+	}{
+		{5, "10", "20", StateOK},
+		{15, "10", "20", StateWarning},
+		{25, "10", "20", StateCritical},
+		{-1, "10", "20", StateCritical},
+		{10, "10", "", StateOK},
+		{5, "10:", "", StateWarning},
+		{10, "10:", "", StateOK},
+		{-50, "~:10", "", StateOK},
+		{11, "~:10", "", StateWarning},
+		{5, ":20", "", StateOK},
+		{-5, ":20", "", StateWarning},
+		{15, "10:20", "5:30", StateOK},
+		{25, "10:20", "5:30", StateWarning},
+		{35, "10:20", "5:30", StateCritical},
+		{15, "@10:20", "", StateWarning},
+		{20, "@10:20", "", StateWarning},
+		{25, "@10:20", "", StateOK},
+		{5, "@10", "", StateWarning},
+		{5, "", "", StateOK},
+	}
+	for _, test := range tests {
+		state, err := evaluateThresholds(test.value, test.warn, test.crit)
+		assert.NoError(t, err)
+		assert.Equalf(t, test.state, state, "value %v, warn %q, crit %q", test.value, test.warn, test.crit)
+	}
+
+	for _, invalid := range []string{"20:10", "a", "1:b", "@", "~"} {
+		_, err := evaluateThresholds(1, invalid, "")
+		assert.Errorf(t, err, "range %q", invalid)
+	}
+}
+
+func TestPerformanceDataIteratorThresholdState(t *testing.T) {
+	previous := config.GetConfig()
+	t.Cleanup(func() { config.SetConfig(previous) })
+	config.InitConfigFromString(configFileContent + "\tEvaluateThresholds = true\n")
+
+	w := NewNagiosSpoolfileWorker(0, nil, nil, nil, 4096, collector.AllFilterable, PerfdataLabelMaxLengthDefault, PerfdataUOMMaxLengthDefault, PerfdataNumericValuesMaxLengthDefault, PerfdataThresholdsMaxLengthDefault)
+	input := helper.StringToMap("DATATYPE::SERVICEPERFDATA	TIMET::1441791000	HOSTNAME::xxx	SERVICEDESC::range	SERVICEPERFDATA::a=4;10:;20 b=4;2;10 c=12,5;~:10;@12:13 d=1 e=U;1;2	SERVICECHECKCOMMAND::check_ranges	SERVICESTATE::0	SERVICESTATETYPE::1", "\t", "::")
+
+	states := map[string]string{}
+	breaches := map[string]string{}
+	for perf := range w.PerformanceDataIterator(input) {
+		states[perf.PerformanceLabel] = perf.Fields["state"]
+		breaches[perf.PerformanceLabel] = perf.Tags["threshold_breach"]
+	}
+	assert.Equal(t, map[string]string{"a": "1.0", "b": "1.0", "c": "2.0", "d": "", "e": ""}, states)
+	assert.Equal(t, map[string]string{"a": "warn", "b": "warn", "c": "crit", "d": "", "e": ""}, breaches, "perfdata without thresholds or value is not evaluated")
+}
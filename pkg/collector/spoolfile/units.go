@@ -0,0 +1,125 @@
+package spoolfile
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+)
+
+// The unit of continuous counters, a rate field is derived from them.
+const counterUnit = "c"
+
+// Counters which were not updated for this time are forgotten.
+const counterMaxAge = time.Hour
+
+// baseUnit is the unit a UOM of the monitoring plugins is converted to.
+type baseUnit struct {
+	unit   string
+	factor float64
+}
+
+// The UOMs of the monitoring plugins and their base unit, bytes use the factor 1024.
+// https://www.monitoring-plugins.org/doc/guidelines.html#AEN201
+var baseUnits = map[string]baseUnit{
+	"s":  {"s", 1},
+	"ms": {"s", 1e-3},
+	"us": {"s", 1e-6},
+	"B":  {"B", 1},
+	"KB": {"B", 1 << 10},
+	"MB": {"B", 1 << 20},
+	"GB": {"B", 1 << 30},
+	"TB": {"B", 1 << 40},
+}
+
+// The fields which are converted to the base unit.
+var unitFields = []string{"value", "warn", "crit", "min", "max", "warn-min", "warn-max", "crit-min", "crit-max"}
+
+// The last value of a counter.
+type counterSample struct {
+	value float64
+	time  int64 // in ms like PerformanceData.Time
+	seen  time.Time
+}
+
+// Keeps the last value of every counter series.
+type counterStore struct {
+	mutex     sync.Mutex
+	samples   map[string]counterSample
+	lastPrune time.Time
+}
+
+func newCounterStore() *counterStore {
+	return &counterStore{samples: map[string]counterSample{}}
+}
+
+// The counters of the collectors, shared by all their workers since a series can be parsed by any of them.
+var sharedCounters = newCounterStore()
+
+// normalizeUnit converts the fields to the base unit and rewrites the unit, counters get a rate field from the store.
+func normalizeUnit(perf *PerformanceData, counters *counterStore) {
+	if perf.Unit == counterUnit {
+		counters.addRate(perf)
+		return
+	}
+	base, ok := baseUnits[perf.Unit]
+	if !ok {
+		return
+	}
+	if base.factor != 1 {
+		for _, field := range unitFields {
+			value, err := strconv.ParseFloat(perf.Fields[field], 64)
+			if err != nil {
+				continue
+			}
+			perf.Fields[field] = formatFloat(value * base.factor)
+		}
+	}
+	perf.Unit = base.unit
+}
+
+// addRate adds the increase per second since the last value of the series.
+// There is no rate for the first value, if the counter was reset or if the data is older than the last value.
+func (store *counterStore) addRate(perf *PerformanceData) {
+	value, err := strconv.ParseFloat(perf.Fields["value"], 64)
+	if err != nil {
+		return
+	}
+	timestamp, err := strconv.ParseInt(perf.Time, 10, 64)
+	if err != nil {
+		return
+	}
+	key := perf.Hostname + "\t" + perf.Service + "\t" + perf.Command + "\t" + perf.PerformanceLabel
+	if rate, ok := store.update(key, value, timestamp); ok {
+		perf.Fields["rate"] = formatFloat(rate)
+	}
+}
+
+// update stores the value and returns the rate per second since the last one.
+func (store *counterStore) update(key string, value float64, timestamp int64) (float64, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(store.lastPrune) > counterMaxAge {
+		for k, sample := range store.samples {
+			if now.Sub(sample.seen) > counterMaxAge {
+				delete(store.samples, k)
+			}
+		}
+		store.lastPrune = now
+	}
+	last, found := store.samples[key]
+	if found && timestamp <= last.time {
+		return 0, false
+	}
+	store.samples[key] = counterSample{value: value, time: timestamp, seen: now}
+	if !found || value < last.value {
+		return 0, false
+	}
+	return (value - last.value) / (float64(timestamp-last.time) / 1000), true
+}
+
+func formatFloat(value float64) string {
+	return helper.StringIntToStringFloat(strconv.FormatFloat(value, 'f', -1, 64))
+}
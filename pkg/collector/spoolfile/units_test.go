@@ -0,0 +1,79 @@
+package spoolfile
+
+import (
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeUnit(t *testing.T) {
+	perf := &PerformanceData{Unit: "MB", Fields: map[string]string{"value": "1.5", "warn-min": "2", "max": "1024.0"}}
+	normalizeUnit(perf, newCounterStore())
+	assert.Equal(t, "B", perf.Unit)
+	assert.Equal(t, map[string]string{"value": "1572864.0", "warn-min": "2097152.0", "max": "1073741824.0"}, perf.Fields)
+
+	perf = &PerformanceData{Unit: "ms", Fields: map[string]string{"value": "0.024", "unknown": "true"}}
+	normalizeUnit(perf, newCounterStore())
+	assert.Equal(t, "s", perf.Unit)
+	assert.Equal(t, map[string]string{"value": "0.000024", "unknown": "true"}, perf.Fields)
+
+	perf = &PerformanceData{Unit: "%", Fields: map[string]string{"value": "12.0"}}
+	normalizeUnit(perf, newCounterStore())
+	assert.Equal(t, "%", perf.Unit)
+	assert.Equal(t, map[string]string{"value": "12.0"}, perf.Fields)
+}
+
+func TestCounterRate(t *testing.T) {
+	counters := newCounterStore()
+	rates := []string{}
+	for _, sample := range []struct{ value, time string }{
+		{"100", "1000000"},
+		{"160", "1060000"}, // 1/s
+		{"150", "1050000"}, // older than the last one
+		{"100", "1120000"}, // reset
+		{"400", "1180000"}, // 5/s
+	} {
+		perf := &PerformanceData{Hostname: "host", Service: "service", PerformanceLabel: "packets", Unit: "c", Time: sample.time, Fields: map[string]string{"value": sample.value}}
+		normalizeUnit(perf, counters)
+		rates = append(rates, perf.Fields["rate"])
+		assert.Equal(t, "c", perf.Unit)
+	}
+	assert.Equal(t, []string{"", "1.0", "", "", "5.0"}, rates)
+
+	// another check with the same label is another series
+	perf := &PerformanceData{Hostname: "host", Service: "service", Command: "check_other", PerformanceLabel: "packets", Unit: "c", Time: "1240000", Fields: map[string]string{"value": "700"}}
+	normalizeUnit(perf, counters)
+	assert.NotContains(t, perf.Fields, "rate")
+}
+
+func TestIsolateCounters(t *testing.T) {
+	config.InitConfigFromString(configFileContent + "\tNormalizeUnits = true\n")
+	defer config.InitConfigFromString(configFileContent + "\tNormalizeUnits = false\n")
+
+	w := NewNagiosSpoolfileWorker(0, nil, nil, nil, 4096, collector.AllFilterable, PerfdataLabelMaxLengthDefault, PerfdataUOMMaxLengthDefault, PerfdataNumericValuesMaxLengthDefault, PerfdataThresholdsMaxLengthDefault)
+	w.IsolateCounters()
+	input := helper.StringToMap("DATATYPE::SERVICEPERFDATA	TIMET::1441791000	HOSTNAME::isolated	SERVICEDESC::if	SERVICEPERFDATA::packets=100c	SERVICECHECKCOMMAND::check_if	SERVICESTATE::0	SERVICESTATETYPE::1", "\t", "::")
+	for range w.PerformanceDataIterator(input) {
+	}
+	assert.Empty(t, sharedCounters.samples, "the isolated worker must not change the counters of the collectors")
+	assert.Len(t, w.counters.samples, 1)
+}
+
+func TestPerformanceDataIteratorNormalizeUnits(t *testing.T) {
+	config.InitConfigFromString(configFileContent + "\tNormalizeUnits = true\n")
+	defer config.InitConfigFromString(configFileContent + "\tNormalizeUnits = false\n")
+
+	w := NewNagiosSpoolfileWorker(0, nil, nil, nil, 4096, collector.AllFilterable, PerfdataLabelMaxLengthDefault, PerfdataUOMMaxLengthDefault, PerfdataNumericValuesMaxLengthDefault, PerfdataThresholdsMaxLengthDefault)
+	input := helper.StringToMap("DATATYPE::SERVICEPERFDATA	TIMET::1441791000	HOSTNAME::xxx	SERVICEDESC::disk	SERVICEPERFDATA::used=2KB;1:3;4;0;8	SERVICECHECKCOMMAND::check_disk	SERVICESTATE::0	SERVICESTATETYPE::1", "\t", "::")
+
+	collected := []*PerformanceData{}
+	for perf := range w.PerformanceDataIterator(input) {
+		collected = append(collected, perf)
+	}
+	assert.Len(t, collected, 1)
+	assert.Equal(t, "B", collected[0].Unit)
+	assert.Equal(t, map[string]string{"value": "2048.0", "warn-min": "1024.0", "warn-max": "3072.0", "crit": "4096.0", "min": "0.0", "max": "8192.0"}, collected[0].Fields)
+}
@@ -1,5 +1,16 @@
 package config
 
+// TLS are the options of a section which connects to a server via TLS.
+// It's embedded, so the options are set like TLSCAFile in the section itself.
+type TLS struct {
+	TLSCAFile             string // PEM file of the CAs, the CAs of the system are used if it's empty
+	TLSInsecureSkipVerify bool   // don't verify the certificate of the server
+	TLSServerName         string // name which is verified instead of the host of the address
+	TLSCertFile           string // PEM file of the client certificate for mTLS
+	TLSKeyFile            string // PEM file of the key of the client certificate
+	TLSMinVersion         string // 1.0, 1.1, 1.2 or 1.3, defaults to 1.2
+}
+
 // Config Represents the config file.
 // Optional arguments use pointers, if they are unspecified, they will be set to nil
 type Config struct {
@@ -26,6 +37,21 @@ type Config struct {
 		SecretFile string
 		Worker     int
 	}
+	Icinga2 map[string]*struct {
+		Enabled            bool
+		Address            string
+		User               string
+		Password           string
+		Queue              string
+		InsecureSkipVerify bool
+		TLS
+	}
+	HTTPIngest struct {
+		Enabled     bool
+		Address     string
+		Token       []string
+		MaxBodySize int64
+	}
 	Log struct {
 		LogFile     string
 		MinSeverity string
@@ -39,8 +65,33 @@ type Config struct {
 		LivestatusHostsFilter         []string // filter used while querying active host downtimes
 		LivestatusServicesFilter      []string // filter used while querying active service downtimes
 	}
+	WAL struct {
+		Enabled     bool
+		Folder      string
+		SegmentSize int // in bytes
+		MaxSize     int // in bytes, records which were not delivered are dropped if the log gets bigger
+		MaxAge      int // in seconds, records which were not delivered are dropped if they get older, 0 disables the cap
+	}
+	Retry struct {
+		MaxAttempts      int
+		InitialBackoff   float64 // in seconds
+		MaxBackoff       float64 // in seconds
+		Multiplier       float64
+		Jitter           *float64 // fraction of the backoff which is randomized
+		BreakerThreshold *int     // consecutive failures until the circuit breaker opens, 0 disables it
+		BreakerCooldown  float64  // in seconds
+	}
+	WorkerScaling struct {
+		Enabled        bool
+		Interval       int     // in seconds
+		HighWatermark  float64 // queue fill which adds a worker
+		LowWatermark   float64 // queue fill which removes a worker
+		MaxBusy        float64 // fraction of the time the workers spend sending, which adds a worker
+		ScaleDownAfter int     // amount of intervals below the low watermark until a worker is removed
+	}
 	Monitoring struct {
 		PrometheusAddress string
+		AdminToken        []string
 	}
 	InfluxDBGlobal struct {
 		CreateDatabaseIfNotExists bool
@@ -57,13 +108,23 @@ type Config struct {
 		StopPullingDataIfDown bool
 		HealthURL             string
 		AuthToken             string
+		RetentionPeriod       int     // seconds, of a created bucket or rp, 0 is infinite
+		BatchMaxLines         int     // lines per request, 0 is the default of 500
+		BatchMaxKB            int     // uncompressed KB per request, 0 is unlimited
+		BatchMaxDelay         float64 // seconds the first line of a batch waits at most, 0 is the default of 5
+		Gzip                  bool
+		TLS
 	}
 	Livestatus struct {
 		Enabled       *bool
-		Type          string
+		Type          string // tcp, tls or file
 		Address       string
 		MinutesToWait int
 		Version       string
+		// columns of the hosts and services table which are added as tags to the perfdata
+		HostColumns    []string
+		ServiceColumns []string
+		TLS
 	}
 	NagiosSpoolfile struct {
 		Enabled *bool
@@ -75,6 +136,8 @@ type Config struct {
 		PerfdataUOMMaxLength           *int // Log errors and skip perfdata if perfdata Unit of Measurement length is longer than this length
 		PerfdataNumericValuesMaxLength *int // Log errors and skip perfdata if perfdata current value, min or max strings are longer than this length
 		PerfdataThresholdsMaxLength    *int // Log errors and skip perfdata if perfdata warn/crit threshold strings are longer than this length
+		EvaluateThresholds             bool // Add the state field and the threshold_breach tag by evaluating the warn/crit ranges
+		NormalizeUnits                 bool // Convert the perfdata to seconds and bytes, add a rate field to counters
 	}
 	NagfluxSpoolfile struct {
 		Enabled *bool
@@ -88,14 +151,86 @@ type Config struct {
 		IndexRotation    string
 	}
 	Elasticsearch map[string]*struct {
-		Enabled bool
-		Address string
-		Index   string
-		Version string
+		Enabled         bool
+		Address         string
+		Index           string // prefix of the indices or the name of the data stream
+		Version         string
+		Distribution    string // elasticsearch or opensearch
+		DataStream      bool
+		LifecyclePolicy string // name of the ILM or ISM policy
+		Username        string
+		Password        string
+		APIKey          string
+		BearerToken     string
+		TLS
+	}
+	Prometheus map[string]*struct {
+		Enabled        bool
+		Address        string // remote write url
+		HealthURL      string
+		AuthToken      string
+		MetricPrefix   string
+		HostcheckAlias string
+		ClientTimeout  int
+		TLS
+	}
+	Graphite map[string]*struct {
+		Enabled        bool
+		Address        string
+		Protocol       string // plaintext or pickle
+		Prefix         string
+		PathTemplate   string
+		HostcheckAlias string
+	}
+	OTLP map[string]*struct {
+		Enabled        bool
+		Endpoint       string
+		HealthURL      string
+		Encoding       string // protobuf or json
+		Header         []string
+		MetricPrefix   string
+		HostcheckAlias string
+		ClientTimeout  int
+		TLS
 	}
 	JSONFileExport map[string]*struct {
 		Enabled               bool
 		Path                  string
 		AutomaticFileRotation int
+		Format                string // ndjson or array, defaults to array with rotation and ndjson without
+	}
+	FileExport map[string]*struct {
+		Enabled        bool
+		Path           string
+		Format         string // json, influx or csv
+		Compression    string // none, gzip or zstd
+		RotateInterval int    // in seconds, 0 disables the rotation by time
+		RotateSize     int    // in MB of the uncompressed data, 0 disables the rotation by size
+		MaxFiles       int    // 0 keeps every file
+		MaxAge         int    // in seconds, 0 keeps every file
+	}
+	Cardinality struct {
+		Enabled             bool
+		Window              int    // in seconds, the series are counted from the start of a window
+		MaxSeriesPerHost    int    // 0 disables the limit
+		MaxSeriesPerCommand int    // 0 disables the limit
+		MaxSeries           int    // 0 disables the limit
+		Action              string // drop, overflow or log
+		TopOffenders        int    // amount of hosts and commands exported as nagflux_cardinality_top_series
+	}
+	// Rules which change or drop the perfdata before it's queued, applied in the order of their names
+	Rewrite map[string]*struct {
+		// regexes which have to match, empty ones match everything
+		Host             string
+		Service          string
+		Command          string
+		PerformanceLabel string
+		Tag              []string // name=regex
+		Action           string   // rename, drop, keep, add_tag, remove_tag, copy_field, hash_label or set_target
+		Attribute        string   // host, service, command, performanceLabel or tag:name, used by rename and hash_label
+		Replacement      string   // new value of rename, may contain $1 of the attributes regex
+		Name             string   // tag of add_tag/remove_tag, field to copy of copy_field
+		NewName          string   // name of the copy of copy_field
+		Value            string   // value of add_tag, target filter of set_target
 	}
 }
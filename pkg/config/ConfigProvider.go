@@ -22,8 +22,38 @@ func InitConfig(configPath string) {
 	}
 }
 
+// ReloadConfig reads the config file into a new config object and replaces the current one with it.
+// If the file is not valid, the current config is kept and the error is returned.
+func ReloadConfig(configPath string) error {
+	newConfig, err := ReadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	mutex.Lock()
+	config = newConfig
+	mutex.Unlock()
+	return nil
+}
+
+// ReadConfigFile reads the config file into a new config object, the current one is not changed.
+// If the error is not fatal (see gcfg.FatalOnly), the returned config holds every valid value.
+func ReadConfigFile(configPath string) (Config, error) {
+	var newConfig Config
+	err := gcfg.ReadFileInto(&newConfig, configPath)
+	return newConfig, err
+}
+
+// SetConfig replaces the static config object, e.g. to restore it after a test.
+func SetConfig(newConfig Config) {
+	mutex.Lock()
+	config = newConfig
+	mutex.Unlock()
+}
+
 // GetConfig returns the static config object
 func GetConfig() Config {
+	mutex.Lock()
+	defer mutex.Unlock()
 	return config
 }
 
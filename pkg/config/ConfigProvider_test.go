@@ -129,3 +129,33 @@ func TestInitConfigFromString(t *testing.T) {
 		t.Errorf("Content did not match %d != %d", cfg.Main.MaxInfluxWorker, 5)
 	}
 }
+
+func TestReloadConfig(t *testing.T) {
+	folder := t.TempDir()
+	valid := folder + "/valid.gcfg"
+	if err := os.WriteFile(valid, []byte("[main]\n\tInfluxWorker = 3\n[InfluxDB \"a\"]\n\tEnabled = true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	InitConfigFromString(configFileContent)
+	if err := ReloadConfig(valid); err != nil {
+		t.Fatal(err)
+	}
+	cfg := GetConfig()
+	if cfg.Main.InfluxWorker != 3 || cfg.Main.MaxInfluxWorker != 0 {
+		t.Errorf("The old config should be replaced, not merged: %d %d", cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker)
+	}
+	if len(cfg.InfluxDB) != 1 {
+		t.Errorf("Expected one InfluxDB, got %d", len(cfg.InfluxDB))
+	}
+
+	invalid := folder + "/invalid.gcfg"
+	if err := os.WriteFile(invalid, []byte("[main\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfig(invalid); err == nil {
+		t.Error("An invalid config should return an error")
+	}
+	if GetConfig().Main.InfluxWorker != 3 {
+		t.Error("The current config should be kept, if the new one is invalid")
+	}
+}
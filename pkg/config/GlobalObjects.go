@@ -1,6 +1,7 @@
 package config
 
 import (
+	"maps"
 	"sync"
 
 	"github.com/ConSol-Monitoring/nagflux/pkg/data"
@@ -9,25 +10,19 @@ import (
 // PauseMap is a map to store if an target requested pause or not
 type PauseMap map[data.Target]bool
 
-// pauseNagflux is used to sync the state of the influxdb
+// pauseNagflux is used to sync the state of the targets
 var pauseNagflux = PauseMap{}
 
 var objMutex = &sync.Mutex{}
 
-// IsAnyTargetOnPause will return true if any target requested pause, false otherwise
-func IsAnyTargetOnPause() bool {
+// GetPauseMap returns a copy of the pause state of every target
+func GetPauseMap() PauseMap {
 	objMutex.Lock()
-	result := false
-	for _, v := range pauseNagflux {
-		if v {
-			result = true
-			break
-		}
-	}
-	objMutex.Unlock()
-	return result
+	defer objMutex.Unlock()
+	return maps.Clone(pauseNagflux)
 }
 
+// StoreValue stores the pause state of the target
 func StoreValue(target data.Target, value bool) {
 	objMutex.Lock()
 	pauseNagflux[target] = value
@@ -13,7 +13,7 @@ func TestStoreValue(t *testing.T) {
 	if len(pauseNagflux) != 1 {
 		t.Error("Map size does not match")
 	}
-	if IsAnyTargetOnPause() {
+	if GetPauseMap()[target] {
 		t.Error("No target should be at pause")
 	}
 	target2 := data.Target{Name: "bar", Datatype: data.InfluxDB}
@@ -21,7 +21,15 @@ func TestStoreValue(t *testing.T) {
 	if len(pauseNagflux) != 2 {
 		t.Error("Map size does not match")
 	}
-	if !IsAnyTargetOnPause() {
-		t.Error("One target should be at pause")
+	if !GetPauseMap()[target2] {
+		t.Error("The second target should be at pause")
+	}
+	if GetPauseMap()[target] {
+		t.Error("The pause of one target should not affect the other")
+	}
+	pauseMap := GetPauseMap()
+	pauseMap[target] = true
+	if GetPauseMap()[target] {
+		t.Error("The returned map should be a copy")
 	}
 }
@@ -10,4 +10,12 @@ const (
 	Elasticsearch Datatype = "elastic"
 	// JSONFile enum
 	JSONFile Datatype = "json"
+	// Prometheus enum
+	Prometheus Datatype = "prometheus"
+	// Graphite enum
+	Graphite Datatype = "graphite"
+	// OTLP enum
+	OTLP Datatype = "otlp"
+	// File enum
+	File Datatype = "file"
 )
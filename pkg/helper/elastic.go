@@ -45,3 +45,49 @@ func GenIndex(index, timeString string) string {
 		panic(fmt.Sprintf("The given IndexRotation[%s] is not supported", rotation))
 	}
 }
+
+// ElasticsearchBulk encodes the documents for the bulk API of an Elasticsearch or OpenSearch cluster.
+type ElasticsearchBulk struct {
+	// Version of the cluster, e.g. 8.13 or 2.11 for OpenSearch
+	Version    string
+	OpenSearch bool
+	// Index is the prefix of the rotated indices or the name of the data stream
+	Index      string
+	DataStream bool
+}
+
+// IsSupported is false for Elasticsearch before 2.0.
+func (b ElasticsearchBulk) IsSupported() bool {
+	return b.OpenSearch || VersionOrdinal(b.Version) >= VersionOrdinal("2.0")
+}
+
+// HasMappingTypes is true for Elasticsearch before 7.0, the later versions and OpenSearch have typeless indices.
+func (b ElasticsearchBulk) HasMappingTypes() bool {
+	return !b.OpenSearch && VersionOrdinal(b.Version) < VersionOrdinal("7.0")
+}
+
+// HasComposableTemplates is true if the cluster supports _index_template, which data streams require.
+func (b ElasticsearchBulk) HasComposableTemplates() bool {
+	return b.OpenSearch || VersionOrdinal(b.Version) >= VersionOrdinal("7.9")
+}
+
+// Header returns the action line of a document, the mapping type is only sent to clusters which have mapping types.
+func (b ElasticsearchBulk) Header(mappingType, timeString string) string {
+	if b.DataStream {
+		// data streams are append only and rotated by their lifecycle
+		return fmt.Sprintf(`{"create":{"_index":"%s"}}`, b.Index) + "\n"
+	}
+	index := GenIndex(b.Index, timeString)
+	if b.HasMappingTypes() {
+		return fmt.Sprintf(`{"index":{"_index":"%s","_type":"%s"}}`, index, mappingType) + "\n"
+	}
+	return fmt.Sprintf(`{"index":{"_index":"%s"}}`, index) + "\n"
+}
+
+// TimestampField is the name of the time of a document, data streams require @timestamp.
+func (b ElasticsearchBulk) TimestampField() string {
+	if b.DataStream {
+		return "@timestamp"
+	}
+	return "timestamp"
+}
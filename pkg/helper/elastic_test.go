@@ -167,3 +167,35 @@ func didThisPanic(f func(string, string) string, arg1, arg2 string) (result bool
 	f(arg1, arg2)
 	return false
 }
+
+func TestElasticsearchBulk(t *testing.T) {
+	config.InitConfigFromString(fmt.Sprintf(Config, "monthly"))
+	for _, data := range []struct {
+		bulk     ElasticsearchBulk
+		header   string
+		field    string
+		template bool
+	}{
+		{ElasticsearchBulk{Version: "6.8", Index: "index"}, `{"index":{"_index":"index-2016.03","_type":"metrics"}}`, "timestamp", false},
+		{ElasticsearchBulk{Version: "7.5", Index: "index"}, `{"index":{"_index":"index-2016.03"}}`, "timestamp", false},
+		{ElasticsearchBulk{Version: "8.13", Index: "index"}, `{"index":{"_index":"index-2016.03"}}`, "timestamp", true},
+		{ElasticsearchBulk{Version: "8.13", Index: "nagflux", DataStream: true}, `{"create":{"_index":"nagflux"}}`, "@timestamp", true},
+		{ElasticsearchBulk{Version: "1.3", OpenSearch: true, Index: "index"}, `{"index":{"_index":"index-2016.03"}}`, "timestamp", true},
+	} {
+		if !data.bulk.IsSupported() {
+			t.Errorf("%+v: expected to be supported", data.bulk)
+		}
+		if actual := data.bulk.Header("metrics", "1458828043000"); actual != data.header+"\n" {
+			t.Errorf("%+v: expected:%s, actual:%s", data.bulk, data.header, actual)
+		}
+		if actual := data.bulk.TimestampField(); actual != data.field {
+			t.Errorf("%+v: expected:%s, actual:%s", data.bulk, data.field, actual)
+		}
+		if actual := data.bulk.HasComposableTemplates(); actual != data.template {
+			t.Errorf("%+v: expected composable templates:%t, actual:%t", data.bulk, data.template, actual)
+		}
+	}
+	if (ElasticsearchBulk{Version: "1.7"}).IsSupported() {
+		t.Error("Elasticsearch 1.7 is not supported")
+	}
+}
@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig creates the TLS config of the options, the files are read immediately.
+func NewTLSConfig(options config.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.TLSInsecureSkipVerify, //nolint:gosec // it's the decision of the admin
+		ServerName:         options.TLSServerName,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if options.TLSMinVersion != "" {
+		version, ok := tlsVersions[options.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("TLSMinVersion %q is not supported, use 1.0, 1.1, 1.2 or 1.3", options.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+	if options.TLSCAFile != "" {
+		pem, err := os.ReadFile(options.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLSCAFile: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("TLSCAFile: %s contains no PEM certificate", options.TLSCAFile)
+		}
+	}
+	if options.TLSCertFile != "" || options.TLSKeyFile != "" {
+		if options.TLSCertFile == "" || options.TLSKeyFile == "" {
+			return nil, fmt.Errorf("TLSCertFile and TLSKeyFile are required both for a client certificate")
+		}
+		certificate, err := tls.LoadX509KeyPair(options.TLSCertFile, options.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLSCertFile: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
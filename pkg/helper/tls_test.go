@@ -0,0 +1,111 @@
+package helper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gcfg.v1"
+)
+
+// Writes a self signed client certificate and its key as PEM files.
+func writeClientCertificate(t *testing.T, folder string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "nagflux"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile = filepath.Join(folder, "client.crt"), filepath.Join(folder, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+	var clientCertificates int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		clientCertificates = len(r.TLS.PeerCertificates)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(folder, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	certFile, keyFile := writeClientCertificate(t, folder)
+
+	get := func(options config.TLS) error {
+		tlsConfig, err := NewTLSConfig(options)
+		require.NoError(t, err)
+		client := http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}, Timeout: 5 * time.Second}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	assert.Error(t, get(config.TLS{}), "the certificate of the server is unknown")
+	assert.NoError(t, get(config.TLS{TLSInsecureSkipVerify: true}))
+	assert.NoError(t, get(config.TLS{TLSCAFile: caFile}))
+	assert.Equal(t, 0, clientCertificates)
+	assert.NoError(t, get(config.TLS{TLSCAFile: caFile, TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.3"}))
+	assert.Equal(t, 1, clientCertificates)
+	assert.Error(t, get(config.TLS{TLSCAFile: caFile, TLSServerName: "other.example"}), "the name does not match")
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+	noPEM := filepath.Join(folder, "empty.pem")
+	require.NoError(t, os.WriteFile(noPEM, []byte("no certificate"), 0o600))
+	for _, options := range []config.TLS{
+		{TLSMinVersion: "1.4"},
+		{TLSCAFile: filepath.Join(folder, "missing.pem")},
+		{TLSCAFile: noPEM},
+		{TLSCertFile: noPEM},
+		{TLSCertFile: noPEM, TLSKeyFile: noPEM},
+	} {
+		_, err := NewTLSConfig(options)
+		assert.Error(t, err, "%+v", options)
+	}
+
+	tlsConfig, err := NewTLSConfig(config.TLS{})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+}
+
+func TestTLSOptionsOfConfig(t *testing.T) {
+	var cfg config.Config
+	err := gcfg.ReadStringInto(&cfg, `
+[InfluxDB "nagflux"]
+    TLSCAFile = /etc/ssl/ca.pem
+    TLSInsecureSkipVerify = true
+    TLSMinVersion = 1.3
+`)
+	require.NoError(t, err)
+	assert.Equal(t, config.TLS{TLSCAFile: "/etc/ssl/ca.pem", TLSInsecureSkipVerify: true, TLSMinVersion: "1.3"}, cfg.InfluxDB["nagflux"].TLS)
+}
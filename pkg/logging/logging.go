@@ -41,6 +41,14 @@ func GetLogger() *factorlog.FactorLog {
 	return singleLogger
 }
 
+// SetOutput changes the output of the existing logger, so the packages which stored it write there as well.
+func SetOutput(w io.Writer, format, minSeverity string) {
+	logger := GetLogger()
+	logger.SetOutput(w)
+	logger.SetFormatter(factorlog.NewStdFormatter(format))
+	logger.SetMinMaxSeverity(factorlog.StringToSeverity(minSeverity), factorlog.StringToSeverity("PANIC"))
+}
+
 // InitTestLogger creates logger for testing
 func InitTestLogger() {
 	singleLogger = factorlog.New(os.Stderr, factorlog.NewStdFormatter(""))
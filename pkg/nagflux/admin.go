@@ -0,0 +1,151 @@
+package nagflux
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/admin"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target"
+)
+
+// Implemented by the connectors which know if their database is reachable.
+type aliveChecker interface {
+	IsAlive() bool
+}
+
+// Implemented by the connectors which know if their database or template exists.
+type databaseChecker interface {
+	DatabaseExists() bool
+}
+
+// Returns the first stoppable which implements T.
+func findStoppable[T any](stoppables []Stoppable) (T, bool) {
+	for _, stoppable := range stoppables {
+		if result, ok := stoppable.(T); ok {
+			return result, true
+		}
+	}
+	var empty T
+	return empty, false
+}
+
+// Status returns the state of the running targets and collectors for the admin API.
+func (c *components) Status() admin.Status {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	status := admin.Status{Targets: []admin.TargetStatus{}, Collectors: []string{}, PauseMap: map[string]bool{}}
+	queues := c.resultQueues.Copy()
+	for t, running := range c.targets {
+		targetStatus := admin.TargetStatus{
+			Name: t.Name, Type: string(t.Datatype),
+			Queue: len(queues[t]), QueueCapacity: cap(queues[t]),
+		}
+		if hasWorker, ok := findStoppable[target.HasWorker](running.stoppables); ok {
+			workers := hasWorker.AmountWorkers()
+			targetStatus.Workers = &workers
+		}
+		if checker, ok := findStoppable[aliveChecker](running.stoppables); ok {
+			alive := checker.IsAlive()
+			targetStatus.Alive = &alive
+		}
+		if checker, ok := findStoppable[databaseChecker](running.stoppables); ok {
+			exists := checker.DatabaseExists()
+			targetStatus.DatabaseExists = &exists
+		}
+		if pausable, ok := findStoppable[target.Pausable](running.stoppables); ok {
+			paused := pausable.IsPaused()
+			targetStatus.Paused = &paused
+		}
+		status.Targets = append(status.Targets, targetStatus)
+	}
+	slices.SortFunc(status.Targets, func(a, b admin.TargetStatus) int {
+		return strings.Compare(a.Type+"/"+a.Name, b.Type+"/"+b.Name)
+	})
+	for name := range c.collectors {
+		status.Collectors = append(status.Collectors, name)
+	}
+	slices.Sort(status.Collectors)
+	for t, paused := range config.GetPauseMap() {
+		status.PauseMap[t.String()] = paused
+	}
+	return status
+}
+
+// Do runs an action of the admin API on the target.
+func (c *components) Do(t data.Target, action string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.reloading {
+		// the reload stops the targets without holding the mutex
+		return fmt.Errorf("%w: the config is being reloaded", admin.ErrConflict)
+	}
+	running, found := c.targets[t]
+	if !found {
+		return admin.ErrUnknownTarget
+	}
+	switch action {
+	case admin.ActionPause, admin.ActionResume:
+		pausable, ok := findStoppable[target.Pausable](running.stoppables)
+		if !ok {
+			return admin.ErrNotSupported
+		}
+		if action == admin.ActionPause {
+			pausable.Pause()
+		} else {
+			pausable.Resume()
+		}
+	case admin.ActionFlush:
+		flushable, ok := findStoppable[target.Flushable](running.stoppables)
+		if !ok {
+			return admin.ErrNotSupported
+		}
+		flushable.Flush()
+	case admin.ActionAddWorker, admin.ActionRemoveWorker:
+		hasWorker, ok := findStoppable[target.HasWorker](running.stoppables)
+		if !ok {
+			return admin.ErrNotSupported
+		}
+		if action == admin.ActionAddWorker {
+			hasWorker.AddWorker()
+		} else {
+			hasWorker.RemoveWorker()
+		}
+	case admin.ActionReplay:
+		return c.replay(t, running)
+	default:
+		return admin.ErrUnknownAction
+	}
+	return nil
+}
+
+// Starts a DumpfileCollector which sends the dumpfile of the target again. The collector moves the dumpfile aside
+// before reading it, so the data which fails again is dumped into a new dumpfile.
+func (c *components) replay(t data.Target, running *component) error {
+	switch t.Datatype {
+	case data.InfluxDB, data.Elasticsearch, data.Prometheus, data.Graphite, data.OTLP:
+	default:
+		return admin.ErrNotSupported
+	}
+	for _, stoppable := range running.stoppables {
+		if dump, ok := stoppable.(*nagflux.DumpfileCollector); ok && dump.IsRunning {
+			return fmt.Errorf("%w: the dumpfile is still being replayed", admin.ErrConflict)
+		}
+	}
+	cfg := config.GetConfig()
+	dumpFile := nagflux.GenDumpfileName(cfg.Main.DumpFile, t)
+	if _, err := os.Stat(dumpFile); err != nil {
+		// the rest of an interrupted replay
+		if _, replayErr := os.Stat(dumpFile + nagflux.ReplaySuffix); replayErr != nil {
+			return err
+		}
+	}
+	log.Infof("Replaying the dumpfile of %s", t)
+	dump := nagflux.NewDumpfileCollector(c.resultQueues[t], cfg.Main.DumpFile, t, cfg.Main.FileBufferSize)
+	running.stoppables = append(running.stoppables, dump)
+	return nil
+}
@@ -0,0 +1,118 @@
+package nagflux
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/admin"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConnector struct {
+	fakeStoppable
+	workers int
+	paused  bool
+	flushed int
+}
+
+func (f *fakeConnector) AddWorker()         { f.workers++ }
+func (f *fakeConnector) RemoveWorker()      { f.workers-- }
+func (f *fakeConnector) AmountWorkers() int { return f.workers }
+func (f *fakeConnector) IsAlive() bool      { return true }
+func (f *fakeConnector) Pause()             { f.paused = true }
+func (f *fakeConnector) Resume()            { f.paused = false }
+func (f *fakeConnector) IsPaused() bool     { return f.paused }
+func (f *fakeConnector) Flush()             { f.flushed++ }
+
+func TestAdminController(t *testing.T) {
+	logging.InitTestLogger()
+	log = logging.GetLogger()
+	config.InitConfigFromString("[main]\n\tBufferSize = 10\n\tDumpFile = \"" + t.TempDir() + "/dump\"\n")
+	c := newComponents(collector.ResultQueues{}, nil, '&')
+	influxTarget := data.Target{Name: "a", Datatype: data.InfluxDB}
+	jsonTarget := data.Target{Name: "b", Datatype: data.JSONFile}
+	connector := &fakeConnector{workers: 1}
+	c.applyTargets(map[data.Target]targetSpec{
+		influxTarget: {settings: 1, start: func(chan collector.Printable) []Stoppable { return []Stoppable{connector} }},
+		jsonTarget:   {settings: 1, start: func(chan collector.Printable) []Stoppable { return []Stoppable{&fakeStoppable{}} }},
+	})
+	c.collectors["Livestatus"] = &component{}
+
+	assert.NoError(t, c.Do(influxTarget, admin.ActionAddWorker))
+	assert.NoError(t, c.Do(influxTarget, admin.ActionPause))
+	assert.NoError(t, c.Do(influxTarget, admin.ActionFlush))
+	assert.Equal(t, 2, connector.workers)
+	assert.True(t, connector.paused)
+	assert.Equal(t, 1, connector.flushed)
+
+	status := c.Status()
+	assert.Equal(t, []string{"Livestatus"}, status.Collectors)
+	assert.Len(t, status.Targets, 2)
+	assert.Equal(t, "influx", status.Targets[0].Type)
+	assert.Equal(t, 2, *status.Targets[0].Workers)
+	assert.True(t, *status.Targets[0].Paused)
+	assert.Equal(t, 10, status.Targets[0].QueueCapacity)
+	assert.Nil(t, status.Targets[1].Workers, "the json target has no workers")
+
+	assert.ErrorIs(t, c.Do(jsonTarget, admin.ActionPause), admin.ErrNotSupported)
+	assert.ErrorIs(t, c.Do(jsonTarget, admin.ActionReplay), admin.ErrNotSupported)
+	assert.ErrorIs(t, c.Do(influxTarget, "unknown"), admin.ErrUnknownAction)
+	assert.ErrorIs(t, c.Do(data.Target{Name: "c", Datatype: data.InfluxDB}, admin.ActionFlush), admin.ErrUnknownTarget)
+	assert.True(t, errors.Is(c.Do(influxTarget, admin.ActionReplay), fs.ErrNotExist), "there is no dumpfile to replay")
+
+	c.setReloading(true)
+	assert.ErrorIs(t, c.Do(influxTarget, admin.ActionFlush), admin.ErrConflict, "the reload stops the targets")
+	c.setReloading(false)
+	assert.NoError(t, c.Do(influxTarget, admin.ActionFlush))
+}
+
+func TestAdminReplayKeepsDumpedLines(t *testing.T) {
+	logging.InitTestLogger()
+	log = logging.GetLogger()
+	config.InitConfigFromString("[main]\n\tBufferSize = 1\n\tDumpFile = \"" + t.TempDir() + "/dump\"\n")
+	resultQueues := collector.ResultQueues{}
+	c := newComponents(resultQueues, nil, '&')
+	influxTarget := data.Target{Name: "a", Datatype: data.InfluxDB}
+	c.applyTargets(map[data.Target]targetSpec{
+		influxTarget: {settings: 1, start: func(chan collector.Printable) []Stoppable { return []Stoppable{&fakeConnector{}} }},
+	})
+	dumpFile := nagflux.GenDumpfileName(config.GetConfig().Main.DumpFile, influxTarget)
+	require.NoError(t, nagflux.AppendDumpfile(dumpFile, []string{"m v=1 1\n", "m v=2 2\n"}))
+
+	// the full queue blocks the replay, meanwhile the target dumps the next line
+	queue := resultQueues[influxTarget]
+	queue <- &collector.SimplePrintable{Text: "queued"}
+	require.NoError(t, c.Do(influxTarget, admin.ActionReplay))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(dumpFile)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond, "the dumpfile is moved aside before it's read")
+	require.NoError(t, nagflux.AppendDumpfile(dumpFile, []string{"m v=3 3\n"}))
+
+	var replayed []string
+	for range 3 {
+		select {
+		case p := <-queue:
+			replayed = append(replayed, p.PrintForInfluxDB("1.0"))
+		case <-time.After(5 * time.Second):
+			t.Fatal("the dumpfile was not replayed")
+		}
+	}
+	assert.Equal(t, []string{"", "m v=1 1", "m v=2 2"}, replayed)
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(dumpFile + nagflux.ReplaySuffix)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+	content, err := os.ReadFile(dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, "m v=3 3\n", string(content), "the line dumped during the replay is kept")
+}
@@ -0,0 +1,456 @@
+package nagflux
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/cardinality"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/elasticsearch"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/file/archive"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/file/jsontarget"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/graphite"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/otlp"
+	"golang.org/x/sys/unix"
+	"gopkg.in/gcfg.v1"
+)
+
+var (
+	versionRegex = regexp.MustCompile(`^\d+(\.\d+)*$`)
+	columnRegex  = regexp.MustCompile(`^[a-z_]+$`)
+)
+
+// Collects the problems of a config.
+type configChecker struct {
+	cfg      config.Config
+	problems []string
+}
+
+// runCheckConfig is the check-config subcommand, it prints every problem of the config and returns the exit code.
+func runCheckConfig(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configPath := flags.String("configPath", "config.gcfg", "path to the config file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	// only the problems should be printed
+	logging.InitLogger("", "ERROR")
+	cfg, err := config.ReadConfigFile(*configPath)
+	var problems []string
+	if err != nil {
+		problems = append(problems, strings.Split(err.Error(), "\n")...)
+	}
+	if gcfg.FatalOnly(err) == nil {
+		problems = append(problems, checkConfig(cfg)...)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(out, "%s: OK\n", *configPath)
+		return 0
+	}
+	fmt.Fprintf(out, "%s: %d problems found\n", *configPath, len(problems))
+	for _, problem := range problems {
+		fmt.Fprintf(out, "  - %s\n", problem)
+	}
+	return 1
+}
+
+// checkConfig validates every section of the config and returns the problems.
+//
+//nolint:funlen
+func checkConfig(cfg config.Config) []string {
+	c := &configChecker{cfg: cfg}
+
+	if len(cfg.Main.FieldSeparator) < 1 {
+		c.addf("Main.FieldSeparator", "must not be empty")
+	}
+	if cfg.Main.BufferSize < 1 {
+		c.addf("Main.BufferSize", "must be greater than 0")
+	}
+	if cfg.Main.FileBufferSize < 1 {
+		c.addf("Main.FileBufferSize", "must be greater than 0")
+	}
+	if cfg.Main.InfluxWorker < 1 || cfg.Main.MaxInfluxWorker < cfg.Main.InfluxWorker {
+		c.addf("Main.InfluxWorker", "has to be at least 1 and not above MaxInfluxWorker")
+	}
+	c.checkParentFolder("Main.DumpFile", cfg.Main.DumpFile)
+	c.checkParentFolder("Log.LogFile", cfg.Log.LogFile)
+	if c.isEnabled("NagiosSpoolfile.Enabled") {
+		c.checkFolder("NagiosSpoolfile.Folder", c.preferredString("NagiosSpoolfile.Folder", "Main.NagiosSpoolfileFolder"))
+	}
+	if c.isEnabled("NagfluxSpoolfile.Enabled") {
+		c.checkFolder("NagfluxSpoolfile.Folder", c.preferredString("NagfluxSpoolfile.Folder", "Main.NagfluxSpoolfileFolder"))
+	}
+	if cfg.WAL.Enabled {
+		c.checkParentFolder("WAL.Folder", filepath.Join(cfg.WAL.Folder, "segment"))
+	}
+
+	c.checkRegexes("Filter.SpoolFileLineTerms", cfg.Filter.SpoolFileLineTerms)
+	c.checkRegexes("Filter.LivestatusLineTerms", cfg.Filter.LivestatusLineTerms)
+	for section, filter := range map[string][]string{
+		"Filter.LivestatusCommentsFilter":      cfg.Filter.LivestatusCommentsFilter,
+		"Filter.LivestatusDowntimesFilter":     cfg.Filter.LivestatusDowntimesFilter,
+		"Filter.LivestatusNotificationsFilter": cfg.Filter.LivestatusNotificationsFilter,
+		"Filter.LivestatusHostsFilter":         cfg.Filter.LivestatusHostsFilter,
+		"Filter.LivestatusServicesFilter":      cfg.Filter.LivestatusServicesFilter,
+	} {
+		for _, err := range livestatus.ValidateFilter(filter) {
+			c.addf(section, "%s", err)
+		}
+	}
+
+	if _, _, err := cardinality.LimitsFromConfig(cfg); err != nil {
+		c.addf("Cardinality.Action", "%s", err)
+	}
+	for _, err := range spoolfile.ValidateRewriteRules(cfg) {
+		if rewriteErr, ok := err.(*spoolfile.RewriteError); ok {
+			c.addf(fmt.Sprintf("Rewrite %q", rewriteErr.Rule), "%s", rewriteErr.Err)
+		}
+	}
+
+	if c.isEnabled("Livestatus.Enabled") {
+		switch cfg.Livestatus.Type {
+		case "tcp":
+			c.checkHostPort("Livestatus.Address", cfg.Livestatus.Address)
+		case "tls":
+			c.checkHostPort("Livestatus.Address", cfg.Livestatus.Address)
+			c.checkTLS("Livestatus", cfg.Livestatus.TLS)
+		case "file":
+			if _, err := os.Stat(cfg.Livestatus.Address); err != nil {
+				c.addf("Livestatus.Address", "%s", err)
+			}
+		default:
+			c.addf("Livestatus.Type", "%q is not supported, use tcp, tls or file", cfg.Livestatus.Type)
+		}
+		if v := cfg.Livestatus.Version; v != "" && v != "Nagios" && v != "Icinga2" && v != "Naemon" {
+			c.addf("Livestatus.Version", "%q is not supported, use Nagios, Icinga2, Naemon or leave it empty to detect it", v)
+		}
+		for key, entries := range map[string][]string{
+			"Livestatus.HostColumns":    cfg.Livestatus.HostColumns,
+			"Livestatus.ServiceColumns": cfg.Livestatus.ServiceColumns,
+		} {
+			for _, column := range livestatus.TagColumns(entries) {
+				if !columnRegex.MatchString(column) {
+					c.addf(key, "%q is not a livestatus column", column)
+				}
+			}
+		}
+	}
+	for name, gearman := range cfg.ModGearman {
+		if gearman == nil || !gearman.Enabled {
+			continue
+		}
+		section := fmt.Sprintf("ModGearman %q", name)
+		c.checkHostPort(section+".Address", gearman.Address)
+		if gearman.Secret == "" && gearman.SecretFile != "" {
+			if _, err := os.ReadFile(gearman.SecretFile); err != nil {
+				c.addf(section+".SecretFile", "%s", err)
+			}
+		}
+	}
+	for name, icinga2 := range cfg.Icinga2 {
+		if icinga2 != nil && icinga2.Enabled {
+			c.checkURL(fmt.Sprintf("Icinga2 %q.Address", name), icinga2.Address)
+			c.checkTLS(fmt.Sprintf("Icinga2 %q", name), icinga2.TLS)
+		}
+	}
+	if cfg.HTTPIngest.Enabled {
+		c.checkHostPort("HTTPIngest.Address", cfg.HTTPIngest.Address)
+	}
+	if cfg.Monitoring.PrometheusAddress != "" {
+		c.checkHostPort("Monitoring.PrometheusAddress", cfg.Monitoring.PrometheusAddress)
+	}
+
+	c.checkTargets()
+	// the sections are read from maps
+	slices.Sort(c.problems)
+	return c.problems
+}
+
+// Checks the target sections and if their names can be told apart by the target filter.
+//
+//nolint:funlen
+func (c *configChecker) checkTargets() {
+	var targets []data.Target
+	addTarget := func(name string, datatype data.Datatype) string {
+		targets = append(targets, data.Target{Name: name, Datatype: datatype})
+		return fmt.Sprintf("%s %q", datatype, name)
+	}
+
+	for name, influx := range c.cfg.InfluxDB {
+		if influx == nil || !influx.Enabled {
+			continue
+		}
+		section := addTarget(name, data.InfluxDB)
+		c.checkURL(section+".Address", influx.Address)
+		c.checkTLS(section, influx.TLS)
+		if influx.HealthURL != "" {
+			c.checkURL(section+".HealthURL", influx.HealthURL)
+		}
+		switch {
+		case !versionRegex.MatchString(influx.Version):
+			c.addf(section+".Version", "%q is not a version, use 1.0 or 2.0", influx.Version)
+		case strings.HasPrefix(influx.Version, "2.") && influx.Version != "2.0":
+			c.addf(section+".Version", "%q is handled like 1.0, use 2.0 for every InfluxDB 2.x", influx.Version)
+		}
+		arguments := helper.StringToMap(influx.Arguments, "&", "=")
+		if influx.Version == "2.0" && arguments["bucket"] == "" {
+			c.addf(section+".Arguments", "bucket is required for InfluxDB 2.0")
+		}
+		if influx.RetentionPeriod < 0 {
+			c.addf(section+".RetentionPeriod", "mustn't be below zero")
+		}
+		if influx.BatchMaxLines < 0 {
+			c.addf(section+".BatchMaxLines", "mustn't be below zero")
+		}
+		if influx.BatchMaxKB < 0 {
+			c.addf(section+".BatchMaxKB", "mustn't be below zero")
+		}
+		if influx.BatchMaxDelay < 0 {
+			c.addf(section+".BatchMaxDelay", "mustn't be below zero")
+		}
+	}
+	rotatedIndices := false
+	for name, elastic := range c.cfg.Elasticsearch {
+		if elastic == nil || !elastic.Enabled {
+			continue
+		}
+		section := addTarget(name, data.Elasticsearch)
+		c.checkURL(section+".Address", elastic.Address)
+		c.checkTLS(section, elastic.TLS)
+		rotatedIndices = rotatedIndices || !elastic.DataStream
+		if !versionRegex.MatchString(elastic.Version) {
+			c.addf(section+".Version", "%q is not a version", elastic.Version)
+		}
+		if elastic.Index == "" {
+			c.addf(section+".Index", "must not be empty")
+		}
+		switch elastic.Distribution {
+		case "", elasticsearch.DistributionElasticsearch, elasticsearch.DistributionOpenSearch:
+		default:
+			c.addf(section+".Distribution", "%q is not supported, use elasticsearch or opensearch", elastic.Distribution)
+		}
+		bulk := helper.ElasticsearchBulk{Version: elastic.Version, OpenSearch: elastic.Distribution == elasticsearch.DistributionOpenSearch}
+		if elastic.DataStream && !bulk.HasComposableTemplates() {
+			c.addf(section+".DataStream", "requires Elasticsearch 7.9 or OpenSearch")
+		}
+		if elastic.LifecyclePolicy != "" && bulk.HasMappingTypes() {
+			c.addf(section+".LifecyclePolicy", "requires Elasticsearch 7.0 or OpenSearch")
+		}
+		credentials := 0
+		for _, credential := range []string{elastic.Username, elastic.APIKey, elastic.BearerToken} {
+			if credential != "" {
+				credentials++
+			}
+		}
+		if credentials > 1 {
+			c.addf(section, "use only one of Username, APIKey and BearerToken")
+		}
+		if elastic.Password != "" && elastic.Username == "" {
+			c.addf(section+".Password", "is set without a Username")
+		}
+	}
+	if rotation := c.cfg.ElasticsearchGlobal.IndexRotation; rotatedIndices && rotation != "monthly" && rotation != "yearly" {
+		c.addf("ElasticsearchGlobal.IndexRotation", "%q is not supported, use monthly or yearly", rotation)
+	}
+	for name, prometheus := range c.cfg.Prometheus {
+		if prometheus == nil || !prometheus.Enabled {
+			continue
+		}
+		section := addTarget(name, data.Prometheus)
+		c.checkURL(section+".Address", prometheus.Address)
+		c.checkTLS(section, prometheus.TLS)
+		if prometheus.HealthURL != "" {
+			c.checkURL(section+".HealthURL", prometheus.HealthURL)
+		}
+	}
+	for name, graphiteConfig := range c.cfg.Graphite {
+		if graphiteConfig == nil || !graphiteConfig.Enabled {
+			continue
+		}
+		section := addTarget(name, data.Graphite)
+		c.checkHostPort(section+".Address", graphiteConfig.Address)
+		if p := graphiteConfig.Protocol; p != "" && p != graphite.Plaintext && p != graphite.Pickle {
+			c.addf(section+".Protocol", "%q is not supported, use %s or %s", p, graphite.Plaintext, graphite.Pickle)
+		}
+	}
+	for name, otlpConfig := range c.cfg.OTLP {
+		if otlpConfig == nil || !otlpConfig.Enabled {
+			continue
+		}
+		section := addTarget(name, data.OTLP)
+		c.checkURL(section+".Endpoint", otlpConfig.Endpoint)
+		c.checkTLS(section, otlpConfig.TLS)
+		if otlpConfig.HealthURL != "" {
+			c.checkURL(section+".HealthURL", otlpConfig.HealthURL)
+		}
+		if e := otlpConfig.Encoding; e != "" && e != otlp.EncodingProtobuf && e != otlp.EncodingJSON {
+			c.addf(section+".Encoding", "%q is not supported, use %s or %s", e, otlp.EncodingProtobuf, otlp.EncodingJSON)
+		}
+		for _, header := range otlpConfig.Header {
+			if key, _, found := strings.Cut(header, ":"); !found || strings.TrimSpace(key) == "" {
+				c.addf(section+".Header", "%q is not in the format Key: Value", header)
+			}
+		}
+	}
+	paths := map[string]string{}
+	for _, name := range slices.Sorted(maps.Keys(c.cfg.JSONFileExport)) {
+		jsonFile := c.cfg.JSONFileExport[name]
+		if jsonFile == nil || !jsonFile.Enabled {
+			continue
+		}
+		section := addTarget(name, data.JSONFile)
+		c.checkFolder(section+".Path", jsonFile.Path)
+		if other, found := paths[filepath.Clean(jsonFile.Path)]; found {
+			c.addf(section+".Path", "is also used by %s", other)
+		}
+		paths[filepath.Clean(jsonFile.Path)] = section
+		if jsonFile.AutomaticFileRotation < 0 {
+			c.addf(section+".AutomaticFileRotation", "must not be negative")
+		}
+		if _, err := jsontarget.FormatOrDefault(jsonFile.Format, jsonFile.AutomaticFileRotation); err != nil {
+			c.addf(section+".Format", "%s", err)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.cfg.FileExport)) {
+		fileExport := c.cfg.FileExport[name]
+		if fileExport == nil || !fileExport.Enabled {
+			continue
+		}
+		section := addTarget(name, data.File)
+		if _, err := archive.SettingsFromConfig(name, c.cfg); err != nil {
+			c.addf(section, "%s", err)
+		} else {
+			c.checkParentFolder(section+".Path", filepath.Join(fileExport.Path, "perfdata"))
+		}
+		if other, found := paths[filepath.Clean(fileExport.Path)]; found {
+			c.addf(section+".Path", "is also used by %s", other)
+		}
+		paths[filepath.Clean(fileExport.Path)] = section
+	}
+
+	// the target filter compares the names case insensitive and splits them by comma
+	slices.SortFunc(targets, func(a, b data.Target) int { return strings.Compare(a.String(), b.String()) })
+	names := map[string]data.Target{}
+	for _, t := range targets {
+		name := strings.ToLower(t.Name)
+		other, found := names[name]
+		switch {
+		case name == collector.All || strings.Contains(name, ","):
+			c.addf(fmt.Sprintf("%s %q", t.Datatype, t.Name), "the name must not be %q or contain a comma, as it is used as target filter", collector.All)
+		case found:
+			c.addf(fmt.Sprintf("%s %q", t.Datatype, t.Name), "the name collides with %s %q, the target filter can't tell them apart", other.Datatype, other.Name)
+		default:
+			names[name] = t
+		}
+	}
+	for defaultTarget := range strings.SplitSeq(strings.ToLower(c.cfg.Main.DefaultTarget), ",") {
+		if _, found := names[defaultTarget]; defaultTarget != "" && defaultTarget != collector.All && !found {
+			c.addf("Main.DefaultTarget", "%q is not an enabled target", defaultTarget)
+		}
+	}
+}
+
+func (c *configChecker) addf(section, format string, args ...any) {
+	c.problems = append(c.problems, section+": "+fmt.Sprintf(format, args...))
+}
+
+// Returns the value of a *bool option, which is enabled if it's unset.
+func (c *configChecker) isEnabled(key string) bool {
+	if value, found := helper.GetPreferredConfigValue(c.cfg, key, []string{}); found {
+		if enabled, ok := value.(*bool); ok {
+			return *enabled
+		}
+	}
+	return true
+}
+
+// Returns the value of a *string option or of its deprecated alternative.
+func (c *configChecker) preferredString(key, alternative string) string {
+	if value, found := helper.GetPreferredConfigValue(c.cfg, key, []string{alternative}); found {
+		if result, ok := value.(*string); ok {
+			return *result
+		}
+	}
+	return ""
+}
+
+func (c *configChecker) checkURL(section, value string) {
+	u, err := url.Parse(value)
+	switch {
+	case err != nil:
+		c.addf(section, "%s", err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		c.addf(section, "%q has to start with http:// or https://", value)
+	case u.Host == "":
+		c.addf(section, "%q has no host", value)
+	}
+}
+
+func (c *configChecker) checkHostPort(section, value string) {
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		c.addf(section, "%q is not in the format host:port: %s", value, err)
+	}
+}
+
+func (c *configChecker) checkRegexes(section string, terms []string) {
+	for _, term := range terms {
+		if _, err := regexp.Compile(term); err != nil {
+			c.addf(section, "%s", err)
+		}
+	}
+}
+
+// Checks if the folder exists and the files within can be read, written and deleted.
+// Checks if the TLS options are valid and the certificates can be read.
+func (c *configChecker) checkTLS(section string, options config.TLS) {
+	if _, err := helper.NewTLSConfig(options); err != nil {
+		c.addf(section, "%s", err)
+	}
+}
+
+func (c *configChecker) checkFolder(section, folder string) {
+	if folder == "" {
+		c.addf(section, "must not be empty")
+		return
+	}
+	stat, err := os.Stat(folder)
+	switch {
+	case err != nil:
+		c.addf(section, "%s", err)
+	case !stat.IsDir():
+		c.addf(section, "%s is not a folder", folder)
+	case unix.Access(folder, unix.R_OK|unix.W_OK|unix.X_OK) != nil:
+		c.addf(section, "%s is not readable and writable", folder)
+	}
+}
+
+// Checks if the file can be created, the folders are created if the first existing parent is writable.
+func (c *configChecker) checkParentFolder(section, file string) {
+	if file == "" {
+		return
+	}
+	folder := filepath.Dir(file)
+	for {
+		if _, err := os.Stat(folder); err == nil || folder == filepath.Dir(folder) {
+			break
+		}
+		folder = filepath.Dir(folder)
+	}
+	c.checkFolder(section, folder)
+}
@@ -0,0 +1,138 @@
+package nagflux
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/gcfg.v1"
+)
+
+func TestCheckConfig(t *testing.T) {
+	folder := t.TempDir()
+	var cfg config.Config
+	err := gcfg.ReadStringInto(&cfg, strings.ReplaceAll(`
+[main]
+    NagiosSpoolfileFolder = "FOLDER"
+    NagfluxSpoolfileFolder = "FOLDER/missing"
+    InfluxWorker = 2
+    MaxInfluxWorker = 1
+    DumpFile = "FOLDER/dump/nagflux.dump"
+    FieldSeparator = "&"
+    BufferSize = 10
+    FileBufferSize = 10
+    DefaultTarget = "nagflux,unknown"
+[Filter]
+    SpoolFileLineTerms = "(unclosed"
+    LivestatusCommentsFilter = "Filter: state = 0\nOr: x"
+[Livestatus]
+    Type = "udp"
+    HostColumns = "groups, custom-variables"
+[ModGearman "gearman"]
+    Enabled = true
+    Address = "127.0.0.1:4730"
+    SecretFile = "FOLDER/secret"
+[InfluxDB "nagflux"]
+    Enabled = true
+    Address = "127.0.0.1:8086"
+    Version = "2.7"
+    TLSMinVersion = "1.4"
+    RetentionPeriod = -1
+[Elasticsearch "Nagflux"]
+    Enabled = true
+    Address = "http://127.0.0.1:9200"
+    Index = "nagflux"
+    Version = "7.5"
+    DataStream = true
+    APIKey = "key"
+    BearerToken = "token"
+[Elasticsearch "rotated"]
+    Enabled = true
+    Address = "http://127.0.0.1:9200"
+    Index = "nagflux"
+    Version = "8.13"
+    Distribution = "elastic"
+[Graphite "carbon"]
+    Enabled = true
+    Address = "127.0.0.1"
+    Protocol = "line"
+[Cardinality]
+    Enabled = true
+    Action = block
+[Rewrite "root"]
+    Action = rename
+    Attribute = unit
+[JSONFileExport "a"]
+    Enabled = true
+    Path = "FOLDER"
+[JSONFileExport "b"]
+    Enabled = true
+    Path = "FOLDER/"
+    Format = "csv"
+[FileExport "archive"]
+    Enabled = true
+    Path = "FOLDER/archive"
+    Compression = lz4
+`, "FOLDER", folder))
+	assert.NoError(t, err)
+
+	problems := checkConfig(cfg)
+	expected := []string{
+		`Cardinality.Action: the action "block" is not supported, use drop, overflow or log`,
+		`ElasticsearchGlobal.IndexRotation: "" is not supported, use monthly or yearly`,
+		`Filter.LivestatusCommentsFilter: "Or: x": expected the amount of filters to combine`,
+		"Filter.SpoolFileLineTerms: error parsing regexp: missing closing ): `(unclosed`",
+		`Livestatus.HostColumns: "custom-variables" is not a livestatus column`,
+		`Livestatus.Type: "udp" is not supported, use tcp, tls or file`,
+		`Main.DefaultTarget: "unknown" is not an enabled target`,
+		`Main.InfluxWorker: has to be at least 1 and not above MaxInfluxWorker`,
+		`ModGearman "gearman".SecretFile: open ` + folder + `/secret: no such file or directory`,
+		`NagfluxSpoolfile.Folder: stat ` + folder + `/missing: no such file or directory`,
+		`Rewrite "root": Attribute "unit" is not one of host, service, command, performanceLabel or tag:name`,
+		`elastic "Nagflux".DataStream: requires Elasticsearch 7.9 or OpenSearch`,
+		`elastic "Nagflux": use only one of Username, APIKey and BearerToken`,
+		`elastic "rotated".Distribution: "elastic" is not supported, use elasticsearch or opensearch`,
+		`file "archive": the compression "lz4" is not supported, use none, gzip or zstd`,
+		`graphite "carbon".Address: "127.0.0.1" is not in the format host:port: address 127.0.0.1: missing port in address`,
+		`graphite "carbon".Protocol: "line" is not supported, use plaintext or pickle`,
+		`influx "nagflux".Address: parse "127.0.0.1:8086": first path segment in URL cannot contain colon`,
+		`influx "nagflux".RetentionPeriod: mustn't be below zero`,
+		`influx "nagflux".Version: "2.7" is handled like 1.0, use 2.0 for every InfluxDB 2.x`,
+		`influx "nagflux": TLSMinVersion "1.4" is not supported, use 1.0, 1.1, 1.2 or 1.3`,
+		`influx "nagflux": the name collides with elastic "Nagflux", the target filter can't tell them apart`,
+		`json "b".Format: the format "csv" is not supported, use ndjson or array`,
+		`json "b".Path: is also used by json "a"`,
+	}
+	assert.Equal(t, expected, problems)
+}
+
+func TestRunCheckConfig(t *testing.T) {
+	folder := t.TempDir()
+	configPath := filepath.Join(folder, "config.gcfg")
+	assert.NoError(t, os.WriteFile(configPath, []byte(`
+[main]
+    InfluxWorker = 1
+    MaxInfluxWorker = 1
+    FieldSeparator = "&"
+    BufferSize = 10
+    FileBufferSize = 10
+[Livestatus]
+    Enabled = false
+[NagiosSpoolfile]
+    Enabled = false
+[NagfluxSpoolfile]
+    Enabled = false
+`), 0o600))
+	out := &bytes.Buffer{}
+	assert.Equal(t, 0, runCheckConfig([]string{"-configPath", configPath}, out))
+	assert.Equal(t, configPath+": OK\n", out.String())
+
+	assert.NoError(t, os.WriteFile(configPath, []byte("[main\n"), 0o600))
+	out.Reset()
+	assert.Equal(t, 1, runCheckConfig([]string{"-configPath", configPath}, out))
+	assert.Contains(t, out.String(), "1 problems found")
+}
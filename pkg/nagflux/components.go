@@ -0,0 +1,782 @@
+package nagflux
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/httpingest"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/icinga2"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/modgearman"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/elasticsearch"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/file/archive"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/file/jsontarget"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/graphite"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/influx"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/otlp"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/prometheus"
+)
+
+// A running target or collector and the settings it was started with.
+type component struct {
+	settings   any
+	stoppables []Stoppable
+	dumpFile   string
+	render     func(p collector.Printable) string
+}
+
+// Describes a target of the config. start returns nil if the target could not be started.
+// render returns the line of the queued data for the dumpFile, which is replayed on the next start. Targets without
+// a dumpfile leave both empty.
+type targetSpec struct {
+	settings any
+	start    func(queue chan collector.Printable) []Stoppable
+	dumpFile string
+	render   func(p collector.Printable) string
+}
+
+// Describes a collector of the config. start returns nil if the collector could not be started.
+type collectorSpec struct {
+	name     string
+	settings any
+	start    func() []Stoppable
+}
+
+// Keeps the running targets and collectors, so a reload only replaces the ones whose settings changed.
+// Only the startup and the reloads, which run one after another, change the maps. The mutex guards the changes
+// against the admin API, it is not held while a target or collector is started or stopped, as that can take a while.
+// So the admin API refuses its actions while reloading is set.
+type components struct {
+	mutex            sync.Mutex
+	reloading        bool
+	resultQueues     collector.ResultQueues
+	workerSupervisor *target.WorkerSupervisor
+	fieldSeparator   rune
+	livestatusCache  *livestatus.CacheBuilder
+	targets          map[data.Target]*component
+	collectors       map[string]*component
+}
+
+func newComponents(resultQueues collector.ResultQueues, workerSupervisor *target.WorkerSupervisor, fieldSeparator rune) *components {
+	return &components{
+		resultQueues:     resultQueues,
+		workerSupervisor: workerSupervisor,
+		fieldSeparator:   fieldSeparator,
+		targets:          map[data.Target]*component{},
+		collectors:       map[string]*component{},
+	}
+}
+
+// Stops the targets which were removed or changed and starts the new and changed ones.
+// A changed target keeps its queue, so the collected data is sent by the new one. While the new one starts, the
+// queue is written to the dumpfile, which is replayed afterwards. The queue of a removed target is written to its
+// dumpfile.
+func (c *components) applyTargets(specs map[data.Target]targetSpec) {
+	drainers := map[data.Target]*queueDrainer{}
+	for t, running := range c.targets {
+		spec, found := specs[t]
+		if found && reflect.DeepEqual(spec.settings, running.settings) {
+			continue
+		}
+		c.workerSupervisor.Unwatch(t)
+		c.mutex.Lock()
+		delete(c.targets, t)
+		stoppables := running.stoppables
+		c.mutex.Unlock()
+		if found {
+			log.Infof("Restarting target %s", t)
+			stopAll(stoppables)
+			if spec.render != nil {
+				// the start can take a while, e.g. if the database is not reachable, the collectors must not block
+				drainers[t] = drainQueue(t, spec, c.resultQueues[t])
+			}
+		} else {
+			log.Infof("Stopping target %s", t)
+			queue := c.resultQueues.Remove(t)
+			stopAll(stoppables)
+			dumpQueue(t, running, queue)
+		}
+	}
+	for t, spec := range specs {
+		if _, running := c.targets[t]; running {
+			continue
+		}
+		// this is the only place where queues are added or removed, so reading the map is safe
+		queue, found := c.resultQueues[t]
+		if !found {
+			queue = make(chan collector.Printable, config.GetConfig().Main.BufferSize)
+		}
+		stoppables := spec.start(queue)
+		dumped := 0
+		if drainer, ok := drainers[t]; ok {
+			dumped = drainer.Stop()
+		}
+		if stoppables == nil {
+			// nobody would read the queue, which would block the collectors
+			if found {
+				dumpQueue(t, &component{dumpFile: spec.dumpFile, render: spec.render}, c.resultQueues.Remove(t))
+			}
+			continue
+		}
+		if !found {
+			c.resultQueues.Add(t, queue)
+		}
+		if dumped > 0 {
+			stoppables = replayDrained(t, queue, stoppables)
+		}
+		c.mutex.Lock()
+		c.targets[t] = &component{settings: spec.settings, stoppables: stoppables, dumpFile: spec.dumpFile, render: spec.render}
+		c.mutex.Unlock()
+	}
+}
+
+// Returns the lines of the printables for the dumpfile, each one ends with a newline.
+func renderLines(render func(p collector.Printable) string, printables []collector.Printable) []string {
+	var lines []string
+	for _, p := range printables {
+		line := render(p)
+		if line == "" {
+			continue
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Writes the data which is left in the queue of a stopped target to its dumpfile, it's sent if the target is
+// started again.
+func dumpQueue(t data.Target, running *component, queue chan collector.Printable) {
+	if len(queue) == 0 {
+		return
+	}
+	if running.render == nil {
+		log.Warnf("Discarding %d queued elements of the stopped target %s", len(queue), t)
+		return
+	}
+	var printables []collector.Printable
+	for len(queue) > 0 {
+		printables = append(printables, <-queue)
+	}
+	lines := renderLines(running.render, printables)
+	log.Infof("Dumping %d queued elements of the stopped target %s to %s", len(lines), t, running.dumpFile)
+	if err := nagflux.AppendDumpfile(running.dumpFile, lines); err != nil {
+		log.Errorf("Could not dump the queue of the stopped target %s: %s", t, err.Error())
+	}
+}
+
+// Writes the queue of a restarted target to its dumpfile until it's stopped.
+type queueDrainer struct {
+	stop   chan bool
+	dumped chan int
+}
+
+func drainQueue(t data.Target, spec targetSpec, queue chan collector.Printable) *queueDrainer {
+	drainer := &queueDrainer{stop: make(chan bool), dumped: make(chan int, 1)}
+	go func() {
+		count := 0
+		for {
+			select {
+			case <-drainer.stop:
+				drainer.dumped <- count
+				return
+			case p := <-queue:
+				printables := []collector.Printable{p}
+			more:
+				for {
+					// the new target may read the queue as well
+					select {
+					case p := <-queue:
+						printables = append(printables, p)
+					default:
+						break more
+					}
+				}
+				lines := renderLines(spec.render, printables)
+				if err := nagflux.AppendDumpfile(spec.dumpFile, lines); err != nil {
+					log.Errorf("Could not dump the queue of the restarting target %s: %s", t, err.Error())
+					continue
+				}
+				count += len(lines)
+			}
+		}
+	}()
+	return drainer
+}
+
+// Stop stops the drainer and returns the amount of dumped lines.
+func (drainer *queueDrainer) Stop() int {
+	close(drainer.stop)
+	return <-drainer.dumped
+}
+
+// Replays the data which was dumped while the target started, unless the dumpfile is still being replayed.
+func replayDrained(t data.Target, queue chan collector.Printable, stoppables []Stoppable) []Stoppable {
+	for _, stoppable := range stoppables {
+		if dump, ok := stoppable.(*nagflux.DumpfileCollector); ok && dump.IsRunning {
+			log.Infof("The data of %s, which was queued during the restart, is kept in its dumpfile", t)
+			return stoppables
+		}
+	}
+	cfg := config.GetConfig()
+	return append(stoppables, nagflux.NewDumpfileCollector(queue, cfg.Main.DumpFile, t, cfg.Main.FileBufferSize))
+}
+
+// Stops the collectors which were removed or changed and starts the new and changed ones in the given order.
+func (c *components) applyCollectors(specs []collectorSpec) {
+	specsByName := map[string]collectorSpec{}
+	for _, spec := range specs {
+		specsByName[spec.name] = spec
+	}
+	for name, running := range c.collectors {
+		spec, found := specsByName[name]
+		if found && reflect.DeepEqual(spec.settings, running.settings) {
+			continue
+		}
+		log.Infof("Stopping collector %s", name)
+		c.mutex.Lock()
+		delete(c.collectors, name)
+		c.mutex.Unlock()
+		stopAll(running.stoppables)
+	}
+	for _, spec := range specs {
+		if _, running := c.collectors[spec.name]; running {
+			continue
+		}
+		if stoppables := spec.start(); stoppables != nil {
+			c.mutex.Lock()
+			c.collectors[spec.name] = &component{settings: spec.settings, stoppables: stoppables}
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// Returns everything which is running, in the order it has to be started. The collectors come last, so they are stopped first.
+func (c *components) stoppables() []Stoppable {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var result []Stoppable
+	for _, running := range c.targets {
+		result = append(result, running.stoppables...)
+	}
+	if c.workerSupervisor != nil {
+		// is stopped before the targets
+		result = append(result, c.workerSupervisor)
+	}
+	for _, running := range c.collectors {
+		result = append(result, running.stoppables...)
+	}
+	return result
+}
+
+// Stops the items in the reversed order.
+func stopAll(itemsToStop []Stoppable) {
+	for i := len(itemsToStop) - 1; i >= 0; i-- {
+		itemsToStop[i].Stop()
+	}
+}
+
+// The settings of the Main section which are used by every target.
+type sharedTargetSettings struct {
+	InfluxWorker    int
+	MaxInfluxWorker int
+	DumpFile        string
+	FileBufferSize  int
+	WAL             any
+	Retry           any
+}
+
+func newSharedTargetSettings(cfg config.Config) sharedTargetSettings {
+	return sharedTargetSettings{
+		InfluxWorker: cfg.Main.InfluxWorker, MaxInfluxWorker: cfg.Main.MaxInfluxWorker,
+		DumpFile: cfg.Main.DumpFile, FileBufferSize: cfg.Main.FileBufferSize,
+		WAL: cfg.WAL, Retry: cfg.Retry,
+	}
+}
+
+// Returns the enabled targets of the config.
+//
+//nolint:funlen
+func (c *components) targetSpecs(cfg config.Config) map[data.Target]targetSpec {
+	specs := map[data.Target]targetSpec{}
+	shared := newSharedTargetSettings(cfg)
+
+	for name, value := range cfg.InfluxDB {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		influxConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.InfluxDB}
+		render := func(p collector.Printable) string {
+			if !p.TestTargetFilter(target.Name) {
+				return ""
+			}
+			return p.PrintForInfluxDB(influxConfig.Version)
+		}
+		specs[target] = targetSpec{
+			settings: []any{influxConfig, cfg.InfluxDBGlobal, shared},
+			dumpFile: nagflux.GenDumpfileName(cfg.Main.DumpFile, target),
+			render:   render,
+			start: func(queue chan collector.Printable) []Stoppable {
+				tlsConfig, err := helper.NewTLSConfig(influxConfig.TLS)
+				if err != nil {
+					log.Criticalf("Nagflux is disabled for InfluxDB(%s): %s", target.Name, err)
+					return nil
+				}
+				var stoppables []Stoppable
+				config.StoreValue(target, false)
+				jobs := queue
+				writeAheadLog := newWriteAheadLog(cfg, queue, target, render)
+				if writeAheadLog != nil {
+					stoppables = append(stoppables, writeAheadLog)
+					jobs = writeAheadLog.Output()
+				}
+				influx := influx.ConnectorFactory(
+					jobs, queue,
+					influxConfig.Address, influxConfig.Arguments, cfg.Main.DumpFile, influxConfig.Version,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, cfg.InfluxDBGlobal.CreateDatabaseIfNotExists,
+					influxConfig.StopPullingDataIfDown, target, cfg.InfluxDBGlobal.ClientTimeout, influxConfig.HealthURL, influxConfig.AuthToken,
+					influxConfig.RetentionPeriod,
+					influx.NewBatchSettings(influxConfig.BatchMaxLines, influxConfig.BatchMaxKB, influxConfig.BatchMaxDelay, influxConfig.Gzip),
+					tlsConfig,
+				)
+				stoppables = append(stoppables, influx)
+				if influx.AmountWorkers() == 0 {
+					log.Criticalf("Nagflux is disabled for InfluxDB(%s)", target.Name)
+					stopAll(stoppables)
+					return nil
+				}
+				c.workerSupervisor.Watch(target, jobs, influx)
+				influxDumpFileCollector := nagflux.NewDumpfileCollector(queue, cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
+				waitForDumpfileCollector(influxDumpFileCollector)
+				return append(stoppables, influxDumpFileCollector)
+			},
+		}
+	}
+
+	for name, value := range cfg.Elasticsearch {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		elasticConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.Elasticsearch}
+		bulk := helper.ElasticsearchBulk{
+			Version:    elasticConfig.Version,
+			OpenSearch: elasticConfig.Distribution == elasticsearch.DistributionOpenSearch,
+			Index:      elasticConfig.Index,
+			DataStream: elasticConfig.DataStream,
+		}
+		render := func(p collector.Printable) string {
+			if !p.TestTargetFilter(target.Name) {
+				return ""
+			}
+			return p.PrintForElasticsearch(bulk)
+		}
+		specs[target] = targetSpec{
+			settings: []any{elasticConfig, cfg.ElasticsearchGlobal, shared},
+			dumpFile: nagflux.GenDumpfileName(cfg.Main.DumpFile, target),
+			render:   render,
+			start: func(queue chan collector.Printable) []Stoppable {
+				tlsConfig, err := helper.NewTLSConfig(elasticConfig.TLS)
+				if err != nil {
+					log.Criticalf("Nagflux is disabled for Elasticsearch(%s): %s", target.Name, err)
+					return nil
+				}
+				var stoppables []Stoppable
+				config.StoreValue(target, false)
+				jobs := queue
+				writeAheadLog := newWriteAheadLog(cfg, queue, target, render)
+				if writeAheadLog != nil {
+					stoppables = append(stoppables, writeAheadLog)
+					jobs = writeAheadLog.Output()
+				}
+				authorization := elasticsearch.Authorization(elasticConfig.Username, elasticConfig.Password, elasticConfig.APIKey, elasticConfig.BearerToken)
+				elasticsearch := elasticsearch.ConnectorFactory(
+					jobs,
+					elasticConfig.Address, cfg.Main.DumpFile, bulk, elasticConfig.LifecyclePolicy, authorization,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, true, target, tlsConfig,
+				)
+				stoppables = append(stoppables, elasticsearch)
+				c.workerSupervisor.Watch(target, jobs, elasticsearch)
+				elasticDumpFileCollector := nagflux.NewDumpfileCollector(queue, cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
+				waitForDumpfileCollector(elasticDumpFileCollector)
+				return append(stoppables, elasticDumpFileCollector)
+			},
+		}
+	}
+
+	for name, value := range cfg.Prometheus {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		prometheusConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.Prometheus}
+		specs[target] = targetSpec{
+			settings: []any{prometheusConfig, shared},
+			dumpFile: nagflux.GenDumpfileName(cfg.Main.DumpFile, target),
+			render:   recordRender(target, spoolfile.RenderRecord),
+			start: func(queue chan collector.Printable) []Stoppable {
+				tlsConfig, err := helper.NewTLSConfig(prometheusConfig.TLS)
+				if err != nil {
+					log.Criticalf("Nagflux is disabled for Prometheus(%s): %s", target.Name, err)
+					return nil
+				}
+				clientTimeout := prometheusConfig.ClientTimeout
+				if clientTimeout <= 0 {
+					clientTimeout = 30
+				}
+				stoppables, jobs := recordWriteAheadLog(cfg, queue, target, spoolfile.RenderRecord)
+				prometheusConnector := prometheus.ConnectorFactory(
+					jobs,
+					prometheusConfig.Address, prometheusConfig.HealthURL, prometheusConfig.AuthToken,
+					prometheusConfig.MetricPrefix, prometheusConfig.HostcheckAlias, cfg.Main.DumpFile,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, target, clientTimeout, tlsConfig,
+				)
+				c.workerSupervisor.Watch(target, jobs, prometheusConnector)
+				stoppables = append(stoppables, prometheusConnector)
+				prometheusDumpFileCollector := nagflux.NewDumpfileCollector(queue, cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
+				waitForDumpfileCollector(prometheusDumpFileCollector)
+				return append(stoppables, prometheusDumpFileCollector)
+			},
+		}
+	}
+
+	for name, value := range cfg.Graphite {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		graphiteConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.Graphite}
+		specs[target] = targetSpec{
+			settings: []any{graphiteConfig, shared},
+			dumpFile: nagflux.GenDumpfileName(cfg.Main.DumpFile, target),
+			render:   recordRender(target, spoolfile.RenderRecord),
+			start: func(queue chan collector.Printable) []Stoppable {
+				protocol := graphiteConfig.Protocol
+				if protocol == "" {
+					protocol = graphite.Plaintext
+				}
+				stoppables, jobs := recordWriteAheadLog(cfg, queue, target, spoolfile.RenderRecord)
+				graphiteWorker := graphite.NewGraphiteWorker(
+					jobs, target, graphiteConfig.Address, protocol, cfg.Main.DumpFile,
+					graphite.NewPathBuilder(graphiteConfig.PathTemplate, graphiteConfig.Prefix, graphiteConfig.HostcheckAlias),
+				)
+				if graphiteWorker == nil {
+					stopAll(stoppables)
+					return nil
+				}
+				stoppables = append(stoppables, graphiteWorker)
+				graphiteDumpFileCollector := nagflux.NewDumpfileCollector(queue, cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
+				waitForDumpfileCollector(graphiteDumpFileCollector)
+				return append(stoppables, graphiteDumpFileCollector)
+			},
+		}
+	}
+
+	for name, value := range cfg.OTLP {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		otlpConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.OTLP}
+		specs[target] = targetSpec{
+			settings: []any{otlpConfig, shared},
+			dumpFile: nagflux.GenDumpfileName(cfg.Main.DumpFile, target),
+			render:   recordRender(target, otlp.RenderRecord),
+			start: func(queue chan collector.Printable) []Stoppable {
+				tlsConfig, err := helper.NewTLSConfig(otlpConfig.TLS)
+				if err != nil {
+					log.Criticalf("Nagflux is disabled for OTLP(%s): %s", target.Name, err)
+					return nil
+				}
+				clientTimeout := otlpConfig.ClientTimeout
+				if clientTimeout <= 0 {
+					clientTimeout = 30
+				}
+				encoding := otlpConfig.Encoding
+				if encoding == "" {
+					encoding = otlp.EncodingProtobuf
+				}
+				stoppables, jobs := recordWriteAheadLog(cfg, queue, target, otlp.RenderRecord)
+				otlpConnector := otlp.ConnectorFactory(
+					jobs,
+					otlpConfig.Endpoint, otlpConfig.HealthURL, encoding, otlpConfig.Header,
+					otlpConfig.MetricPrefix, otlpConfig.HostcheckAlias, cfg.Main.DumpFile,
+					cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, target, clientTimeout, tlsConfig,
+				)
+				if otlpConnector == nil {
+					stopAll(stoppables)
+					return nil
+				}
+				c.workerSupervisor.Watch(target, jobs, otlpConnector)
+				stoppables = append(stoppables, otlpConnector)
+				otlpDumpFileCollector := nagflux.NewDumpfileCollector(queue, cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
+				waitForDumpfileCollector(otlpDumpFileCollector)
+				return append(stoppables, otlpDumpFileCollector)
+			},
+		}
+	}
+
+	for name, value := range cfg.JSONFileExport {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		jsonFileConfig := (*value)
+		target := data.Target{Name: name, Datatype: data.JSONFile}
+		specs[target] = targetSpec{
+			settings: []any{jsonFileConfig},
+			start: func(queue chan collector.Printable) []Stoppable {
+				templateFile := jsontarget.NewJSONFileWorker(
+					log, jsonFileConfig.AutomaticFileRotation, jsonFileConfig.Format,
+					queue, target, jsonFileConfig.Path,
+				)
+				if templateFile == nil {
+					return nil
+				}
+				return []Stoppable{templateFile}
+			},
+		}
+	}
+
+	for name, value := range cfg.FileExport {
+		if value == nil || !(*value).Enabled {
+			continue
+		}
+		settings, err := archive.SettingsFromConfig(name, cfg)
+		if err != nil {
+			log.Criticalf("File(%s) is disabled: %s", name, err)
+			continue
+		}
+		target := data.Target{Name: name, Datatype: data.File}
+		specs[target] = targetSpec{
+			settings: []any{settings},
+			start: func(queue chan collector.Printable) []Stoppable {
+				fileWorker := archive.NewWorker(queue, target, settings)
+				if fileWorker == nil {
+					return nil
+				}
+				return []Stoppable{fileWorker}
+			},
+		}
+	}
+	return specs
+}
+
+// Returns the enabled collectors of the config, Livestatus comes first as the others use its cache.
+//
+//nolint:funlen
+func (c *components) collectorSpecs(cfg config.Config) []collectorSpec {
+	var specs []collectorSpec
+
+	// livestatus spoolfile collection is enabled by default
+	livestatusEnabled := true
+	if search, found := helper.GetPreferredConfigValue(cfg, "Livestatus.Enabled", []string{}); found {
+		ptr, ok := search.(*bool)
+		if ok {
+			livestatusEnabled = *(ptr)
+		} else {
+			log.Warnf("Expected a *bool value out of the config value for Livestatus Enablement")
+		}
+	}
+	// every collector which uses the cache is restarted with the new one, if these settings change
+	livestatusSettings := []any{livestatusEnabled, cfg.Livestatus, cfg.Filter}
+	if livestatusEnabled {
+		specs = append(specs, collectorSpec{
+			name:     "Livestatus",
+			settings: livestatusSettings,
+			start: func() []Stoppable {
+				livestatusConnector := &livestatus.Connector{Log: log, LivestatusAddress: cfg.Livestatus.Address, ConnectionType: cfg.Livestatus.Type}
+				if cfg.Livestatus.Type == "tls" {
+					tlsConfig, err := helper.NewTLSConfig(cfg.Livestatus.TLS)
+					if err != nil {
+						log.Criticalf("Livestatus is disabled: %s", err)
+						c.livestatusCache = nil
+						return nil
+					}
+					livestatusConnector.TLSConfig = tlsConfig
+				}
+				livestatusCollector := livestatus.NewLivestatusCollector(c.resultQueues, livestatusConnector, cfg.Livestatus.Version)
+				c.livestatusCache = livestatus.NewLivestatusCacheBuilder(livestatusConnector)
+				return []Stoppable{c.livestatusCache, livestatusCollector}
+			},
+		})
+	} else {
+		c.livestatusCache = nil
+	}
+
+	for name, data := range cfg.ModGearman {
+		if data == nil {
+			continue
+		}
+		if !data.Enabled {
+			log.Debugf("Worker for Mod-Gearman: %s - %s %s will not be started, it is disabled", name, data.Address, data.Queue)
+			continue
+		}
+		gearmanConfig := *data
+		specs = append(specs, collectorSpec{
+			name:     "ModGearman " + name,
+			settings: []any{gearmanConfig, livestatusSettings, cfg.Main.BufferSize},
+			start: func() []Stoppable {
+				if c.livestatusCache == nil {
+					log.Debugf("%s - %s %s will start with a nil livestatusCache, will not process downtime data in perf", name, gearmanConfig.Address, gearmanConfig.Queue)
+				}
+				log.Infof("Mod_Gearman: %s - %s [%s]", name, gearmanConfig.Address, gearmanConfig.Queue)
+				secret := modgearman.GetSecret(gearmanConfig.Secret, gearmanConfig.SecretFile)
+				var stoppables []Stoppable
+				for range gearmanConfig.Worker {
+					gearmanWorker := modgearman.NewGearmanWorker(gearmanConfig.Address,
+						gearmanConfig.Queue,
+						secret,
+						c.resultQueues,
+						c.livestatusCache,
+					)
+					stoppables = append(stoppables, gearmanWorker)
+				}
+				return stoppables
+			},
+		})
+	}
+
+	for name, data := range cfg.Icinga2 {
+		if data == nil || !data.Enabled {
+			continue
+		}
+		icinga2Config := *data
+		specs = append(specs, collectorSpec{
+			name:     "Icinga2 " + name,
+			settings: []any{icinga2Config, livestatusSettings},
+			start: func() []Stoppable {
+				log.Infof("Icinga2: %s - %s", name, icinga2Config.Address)
+				// InsecureSkipVerify is kept for older configs
+				tlsOptions := icinga2Config.TLS
+				tlsOptions.TLSInsecureSkipVerify = tlsOptions.TLSInsecureSkipVerify || icinga2Config.InsecureSkipVerify
+				tlsConfig, err := helper.NewTLSConfig(tlsOptions)
+				if err != nil {
+					log.Criticalf("Icinga2(%s) is disabled: %s", name, err)
+					return nil
+				}
+				icinga2Collector := icinga2.NewIcinga2Collector(name, icinga2Config.Address, icinga2Config.User, icinga2Config.Password,
+					icinga2Config.Queue, tlsConfig, c.resultQueues, c.livestatusCache,
+				)
+				return []Stoppable{icinga2Collector}
+			},
+		})
+	}
+
+	if cfg.HTTPIngest.Enabled {
+		specs = append(specs, collectorSpec{
+			name:     "HTTPIngest",
+			settings: []any{cfg.HTTPIngest, livestatusSettings},
+			start: func() []Stoppable {
+				httpIngestServer := httpingest.NewHTTPIngestServer(cfg.HTTPIngest.Address, cfg.HTTPIngest.Token, cfg.HTTPIngest.MaxBodySize,
+					c.fieldSeparator, c.resultQueues, c.livestatusCache,
+				)
+				return []Stoppable{httpIngestServer}
+			},
+		})
+	}
+
+	// nagios spoolfile collection is enabled by default
+	nagiosSpoolFileCollectorEnabled := true
+	if search, found := helper.GetPreferredConfigValue(cfg, "NagiosSpoolfile.Enabled", []string{}); found {
+		ptr, ok := search.(*bool)
+		if ok {
+			nagiosSpoolFileCollectorEnabled = *(ptr)
+		} else {
+			log.Warnf("Expected a *bool value out of the config value for Nagios Spoolfile Collection Enablement")
+		}
+	}
+	if nagiosSpoolFileCollectorEnabled {
+		specs = append(specs, collectorSpec{
+			name:     "NagiosSpoolfile",
+			settings: []any{cfg.NagiosSpoolfile, cfg.Main, livestatusSettings},
+			start: func() []Stoppable {
+				nagiosCollector, err := spoolfile.NagiosSpoolfileCollectorFactory(
+					cfg,
+					c.resultQueues,
+					c.livestatusCache,
+					cfg.Main.FileBufferSize,
+					collector.Filterable{Filter: cfg.Main.DefaultTarget},
+				)
+				if err != nil {
+					log.Criticalf("Error when setting up NagiosSpoolfileCollectorFactory: %s", err.Error())
+					return nil
+				}
+				return []Stoppable{nagiosCollector}
+			},
+		})
+	}
+
+	// nagflux spoolfile collection is enabled by default
+	nagfluxCollectorEnabled := true
+	if val, found := helper.GetPreferredConfigValue(cfg, "NagfluxSpoolfile.Enabled", []string{}); found {
+		ptr, ok := val.(*bool)
+		if ok {
+			nagfluxCollectorEnabled = *(ptr)
+		} else {
+			log.Warnf("Expected a *bool value out of the config value for Nagflux Spoolfile Enablement")
+		}
+	}
+	if nagfluxCollectorEnabled {
+		nagfluxCollectorFolderSearch, found := helper.GetPreferredConfigValue(cfg, "NagfluxSpoolfile.Folder", []string{"Main.NagfluxSpoolfileFolder"})
+		if !found {
+			log.Criticalf("Could not find a config value for Nagflux Spoolfile Folder")
+			return specs
+		}
+		nagfluxCollectorFolderString := ""
+		if nagfluxCollectionFolderPtr, ok := nagfluxCollectorFolderSearch.(*string); ok {
+			nagfluxCollectorFolderString = *(nagfluxCollectionFolderPtr)
+		} else {
+			log.Warnf("Expected a *string value out of the config value for Nagflux Spoolfile Folder")
+		}
+		specs = append(specs, collectorSpec{
+			name:     "NagfluxSpoolfile",
+			settings: []any{nagfluxCollectorFolderString},
+			start: func() []Stoppable {
+				log.Info("Nagflux Spoolfile Folder: ", nagfluxCollectorFolderString)
+				return []Stoppable{nagflux.NewNagfluxFileCollector(c.resultQueues, nagfluxCollectorFolderString, c.fieldSeparator)}
+			},
+		})
+	}
+	return specs
+}
+
+func (c *components) setReloading(reloading bool) {
+	c.mutex.Lock()
+	c.reloading = reloading
+	c.mutex.Unlock()
+}
+
+// Re-reads the config file and replaces the targets and collectors whose settings changed.
+func (c *components) reload(configPath string) {
+	log.Infof("Reloading config: %s", configPath)
+	c.setReloading(true)
+	defer c.setReloading(false)
+	oldConfig := config.GetConfig()
+	if err := config.ReloadConfig(configPath); err != nil {
+		log.Errorf("Could not reload the config, keeping the current one: %s", err.Error())
+		return
+	}
+	cfg := config.GetConfig()
+	if cfg.Main.FieldSeparator != oldConfig.Main.FieldSeparator || cfg.Main.BufferSize != oldConfig.Main.BufferSize ||
+		!reflect.DeepEqual(cfg.Log, oldConfig.Log) || !reflect.DeepEqual(cfg.Monitoring, oldConfig.Monitoring) ||
+		!reflect.DeepEqual(cfg.WorkerScaling, oldConfig.WorkerScaling) {
+		log.Warn("Main.FieldSeparator, Main.BufferSize, Log, Monitoring and WorkerScaling are only applied after a restart")
+	}
+	c.applyTargets(c.targetSpecs(cfg))
+	// Some time for the dumpfile to fill the queue
+	time.Sleep(time.Duration(100) * time.Millisecond)
+	c.applyCollectors(c.collectorSpecs(cfg))
+	log.Infof("Reloaded config, %d targets and %d collectors are running", len(c.targets), len(c.collectors))
+}
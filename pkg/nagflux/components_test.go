@@ -0,0 +1,183 @@
+package nagflux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStoppable struct {
+	stopped bool
+}
+
+func (f *fakeStoppable) Stop() {
+	f.stopped = true
+}
+
+// Returns a spec which records the started stoppables and the queues they got.
+func fakeTargetSpec(settings any, started *[]*fakeStoppable, queues *[]chan collector.Printable) targetSpec {
+	return targetSpec{
+		settings: settings,
+		start: func(queue chan collector.Printable) []Stoppable {
+			stoppable := &fakeStoppable{}
+			*started = append(*started, stoppable)
+			*queues = append(*queues, queue)
+			return []Stoppable{stoppable}
+		},
+	}
+}
+
+func TestApplyTargets(t *testing.T) {
+	logging.InitTestLogger()
+	log = logging.GetLogger()
+	config.InitConfigFromString("[main]\n\tBufferSize = 10\n")
+	resultQueues := collector.ResultQueues{}
+	c := newComponents(resultQueues, nil, '&')
+	influxTarget := data.Target{Name: "a", Datatype: data.InfluxDB}
+	jsonTarget := data.Target{Name: "b", Datatype: data.JSONFile}
+
+	var started []*fakeStoppable
+	var queues []chan collector.Printable
+	c.applyTargets(map[data.Target]targetSpec{
+		influxTarget: fakeTargetSpec("address 1", &started, &queues),
+		jsonTarget:   fakeTargetSpec("path", &started, &queues),
+	})
+	assert.Len(t, started, 2)
+	assert.Len(t, resultQueues, 2)
+	assert.Len(t, c.stoppables(), 2)
+
+	// queued data has to survive the restart of the target
+	resultQueues[influxTarget] <- &collector.SimplePrintable{Text: "queued"}
+	oldInflux := c.targets[influxTarget].stoppables[0].(*fakeStoppable)
+	oldJSON := c.targets[jsonTarget].stoppables[0].(*fakeStoppable)
+	started, queues = nil, nil
+	c.applyTargets(map[data.Target]targetSpec{
+		influxTarget: fakeTargetSpec("address 2", &started, &queues),
+		jsonTarget:   fakeTargetSpec("path", &started, &queues),
+	})
+	assert.True(t, oldInflux.stopped, "the changed target should be stopped")
+	assert.False(t, oldJSON.stopped, "the unchanged target should keep running")
+	assert.Len(t, started, 1)
+	assert.Equal(t, resultQueues[influxTarget], queues[0], "the restarted target should read the old queue")
+	assert.Len(t, queues[0], 1)
+
+	started, queues = nil, nil
+	c.applyTargets(map[data.Target]targetSpec{
+		influxTarget: fakeTargetSpec("address 2", &started, &queues),
+	})
+	assert.True(t, oldJSON.stopped, "the removed target should be stopped")
+	assert.Empty(t, started)
+	assert.Len(t, resultQueues, 1)
+
+	// a target which can't be started must not get a queue, as nobody would read it
+	c.applyTargets(map[data.Target]targetSpec{
+		influxTarget: {settings: "address 3", start: func(chan collector.Printable) []Stoppable { return nil }},
+	})
+	assert.Empty(t, resultQueues)
+	assert.Empty(t, c.targets)
+}
+
+func TestRemovedTargetDumpsQueue(t *testing.T) {
+	logging.InitTestLogger()
+	log = logging.GetLogger()
+	config.InitConfigFromString("[main]\n\tBufferSize = 10\n")
+	resultQueues := collector.ResultQueues{}
+	c := newComponents(resultQueues, nil, '&')
+	influxTarget := data.Target{Name: "a", Datatype: data.InfluxDB}
+	spec := fakeTargetSpec("address", new([]*fakeStoppable), new([]chan collector.Printable))
+	spec.dumpFile = filepath.Join(t.TempDir(), "nagflux.dump")
+	spec.render = func(p collector.Printable) string { return p.PrintForInfluxDB("1.0") }
+	c.applyTargets(map[data.Target]targetSpec{influxTarget: spec})
+
+	resultQueues[influxTarget] <- &collector.SimplePrintable{Text: "m v=1 1", Datatype: data.InfluxDB}
+	resultQueues[influxTarget] <- &collector.SimplePrintable{Text: "m v=2 2\n", Datatype: data.InfluxDB}
+	c.applyTargets(map[data.Target]targetSpec{})
+	content, err := os.ReadFile(spec.dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, "m v=1 1\nm v=2 2\n", string(content), "the queue of a removed target must not be discarded")
+}
+
+func TestRestartedTargetDrainsQueue(t *testing.T) {
+	logging.InitTestLogger()
+	log = logging.GetLogger()
+	config.InitConfigFromString("[main]\n\tBufferSize = 2\n\tDumpFile = \"" + t.TempDir() + "/dump\"\n")
+	resultQueues := collector.ResultQueues{}
+	c := newComponents(resultQueues, nil, '&')
+	influxTarget := data.Target{Name: "a", Datatype: data.InfluxDB}
+	spec := fakeTargetSpec("address 1", new([]*fakeStoppable), new([]chan collector.Printable))
+	spec.dumpFile = nagflux.GenDumpfileName(config.GetConfig().Main.DumpFile, influxTarget)
+	spec.render = func(p collector.Printable) string { return p.PrintForInfluxDB("1.0") }
+	c.applyTargets(map[data.Target]targetSpec{influxTarget: spec})
+	queue := resultQueues[influxTarget]
+
+	// the new instance takes a while to start, meanwhile the collectors must not block on the queue
+	started := make(chan bool)
+	changed := spec
+	changed.settings = "address 2"
+	changed.start = func(chan collector.Printable) []Stoppable {
+		<-started
+		return []Stoppable{&fakeStoppable{}}
+	}
+	applied := make(chan bool)
+	go func() {
+		c.applyTargets(map[data.Target]targetSpec{influxTarget: changed})
+		close(applied)
+	}()
+	var expected []string
+	for i := range 5 {
+		line := fmt.Sprintf("m v=%d %d", i, i)
+		expected = append(expected, line)
+		select {
+		case queue <- &collector.SimplePrintable{Text: line, Datatype: data.InfluxDB}:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the queue of the restarting target is blocked")
+		}
+	}
+	close(started)
+	<-applied
+
+	// the dumped data is replayed into the queue of the new instance
+	var replayed []string
+	for range expected {
+		select {
+		case p := <-queue:
+			replayed = append(replayed, p.PrintForInfluxDB("1.0"))
+		case <-time.After(5 * time.Second):
+			t.Fatal("the data queued during the restart was not replayed")
+		}
+	}
+	assert.ElementsMatch(t, expected, replayed)
+}
+
+func TestApplyCollectors(t *testing.T) {
+	logging.InitTestLogger()
+	log = logging.GetLogger()
+	c := newComponents(collector.ResultQueues{}, nil, '&')
+	var order []string
+	spec := func(name string, settings any) collectorSpec {
+		return collectorSpec{name: name, settings: settings, start: func() []Stoppable {
+			order = append(order, name)
+			return []Stoppable{&fakeStoppable{}}
+		}}
+	}
+
+	c.applyCollectors([]collectorSpec{spec("Livestatus", 1), spec("Icinga2 a", 1), spec("HTTPIngest", 1)})
+	assert.Equal(t, []string{"Livestatus", "Icinga2 a", "HTTPIngest"}, order)
+
+	order = nil
+	httpIngest := c.collectors["HTTPIngest"].stoppables[0].(*fakeStoppable)
+	c.applyCollectors([]collectorSpec{spec("Livestatus", 2), spec("Icinga2 a", 2)})
+	assert.Equal(t, []string{"Livestatus", "Icinga2 a"}, order, "the changed collectors should be restarted in order")
+	assert.True(t, httpIngest.stopped)
+	assert.Len(t, c.collectors, 2)
+}
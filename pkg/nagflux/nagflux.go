@@ -6,22 +6,19 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
 	"syscall"
 	"time"
 
+	"github.com/ConSol-Monitoring/nagflux/pkg/admin"
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
-	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
-	"github.com/ConSol-Monitoring/nagflux/pkg/collector/modgearman"
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
-	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
 	"github.com/ConSol-Monitoring/nagflux/pkg/config"
 	"github.com/ConSol-Monitoring/nagflux/pkg/data"
-	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
-	"github.com/ConSol-Monitoring/nagflux/pkg/target/elasticsearch"
-	"github.com/ConSol-Monitoring/nagflux/pkg/target/file/jsontarget"
-	"github.com/ConSol-Monitoring/nagflux/pkg/target/influx"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/wal"
 	"github.com/kdar/factorlog"
 )
 
@@ -40,6 +37,15 @@ var (
 
 //nolint:funlen,maintidx
 func Nagflux(Build string) {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check-config":
+			os.Exit(runCheckConfig(os.Args[2:], os.Stdout))
+		case "parse":
+			os.Exit(runParse(os.Args[2:], os.Stdin, os.Stdout))
+		}
+	}
+
 	// Parse Args
 	var configPath string
 	var printver bool
@@ -49,6 +55,10 @@ Commandline Parameter:
 -configPath Path to the config file. If no file path is given the default is ./config.gcfg.
 -V Print version and exit
 
+Subcommands:
+check-config [-configPath] Validate the config file, every problem is printed and the exit code is 1 if there are any.
+parse [-configPath] [-format nagios|gearman|nagflux] [-secret] [file...] Print what every target would send for the input, stdin is read if no file is given.
+
 Original author: Philip Griesbacher
 For further informations / bugs reports: https://github.com/ConSol-Monitoring/nagflux
 `, nagfluxVersion, Build, runtime.Version())
@@ -78,203 +88,52 @@ For further informations / bugs reports: https://github.com/ConSol-Monitoring/na
 	log.Info(`Started Nagflux `, nagfluxVersion)
 	log.Debugf("Using Config: %s", configPath)
 	resultQueues := collector.ResultQueues{}
-	stoppables := []Stoppable{}
 	if len(cfg.Main.FieldSeparator) < 1 {
 		panic("FieldSeparator is too short!")
 	}
 	pro := statistics.NewPrometheusServer(cfg.Monitoring.PrometheusAddress)
 	pro.WatchResultQueueLength(resultQueues)
 	fieldSeparator := []rune(cfg.Main.FieldSeparator)[0]
-
-	for name, value := range cfg.InfluxDB {
-		if value == nil || !(*value).Enabled {
-			continue
-		}
-		influxConfig := (*value)
-		target := data.Target{Name: name, Datatype: data.InfluxDB}
-		config.StoreValue(target, false)
-		resultQueues[target] = make(chan collector.Printable, cfg.Main.BufferSize)
-		influx := influx.ConnectorFactory(
-			resultQueues[target],
-			influxConfig.Address, influxConfig.Arguments, cfg.Main.DumpFile, influxConfig.Version,
-			cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, cfg.InfluxDBGlobal.CreateDatabaseIfNotExists,
-			influxConfig.StopPullingDataIfDown, target, cfg.InfluxDBGlobal.ClientTimeout, influxConfig.HealthURL, influxConfig.AuthToken,
-		)
-		if influx.IsAlive() {
-			stoppables = append(stoppables, influx)
-		} else {
-			log.Criticalf("Nagflux is disabled for InfluxDB(%s)", target.Name)
-		}
-		influxDumpFileCollector := nagflux.NewDumpfileCollector(resultQueues[target], cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
-		waitForDumpfileCollector(influxDumpFileCollector)
-		stoppables = append(stoppables, influxDumpFileCollector)
+	var workerSupervisor *target.WorkerSupervisor
+	if cfg.WorkerScaling.Enabled {
+		workerSupervisor = target.NewWorkerSupervisor(cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker)
 	}
 
-	for name, value := range cfg.Elasticsearch {
-		if value == nil || !(*value).Enabled {
-			continue
-		}
-		elasticConfig := (*value)
-		target := data.Target{Name: name, Datatype: data.Elasticsearch}
-		resultQueues[target] = make(chan collector.Printable, cfg.Main.BufferSize)
-		config.StoreValue(target, false)
-		elasticsearch := elasticsearch.ConnectorFactory(
-			resultQueues[target],
-			elasticConfig.Address, elasticConfig.Index, cfg.Main.DumpFile, elasticConfig.Version,
-			cfg.Main.InfluxWorker, cfg.Main.MaxInfluxWorker, true,
-		)
-		stoppables = append(stoppables, elasticsearch)
-		elasticDumpFileCollector := nagflux.NewDumpfileCollector(resultQueues[target], cfg.Main.DumpFile, target, cfg.Main.FileBufferSize)
-		waitForDumpfileCollector(elasticDumpFileCollector)
-		stoppables = append(stoppables, elasticDumpFileCollector)
-	}
-
-	for name, value := range cfg.JSONFileExport {
-		if value == nil || !(*value).Enabled {
-			continue
-		}
-		jsonFileConfig := (*value)
-		target := data.Target{Name: name, Datatype: data.JSONFile}
-		resultQueues[target] = make(chan collector.Printable, cfg.Main.BufferSize)
-		templateFile := jsontarget.NewJSONFileWorker(
-			log, jsonFileConfig.AutomaticFileRotation,
-			resultQueues[target], target, jsonFileConfig.Path,
-		)
-		stoppables = append(stoppables, templateFile)
-	}
+	components := newComponents(resultQueues, workerSupervisor, fieldSeparator)
+	components.applyTargets(components.targetSpecs(cfg))
 
 	// Some time for the dumpfile to fill the queue
 	time.Sleep(time.Duration(100) * time.Millisecond)
 
-	var livestatusConnector *livestatus.Connector
-	var livestatusCollector *livestatus.Collector
-	var livestatusCache *livestatus.CacheBuilder
-
-	// livestatus spoolfile collection is enabled by default
-	livestatusEnabled := true
-	if search, found := helper.GetPreferredConfigValue(cfg, "Livestatus.Enabled", []string{}); found {
-		ptr, ok := search.(*bool)
-		if ok {
-			livestatusEnabled = *(ptr)
-		} else {
-			log.Warnf("Expected a *bool value out of the config value for Livestatus Enablement")
-		}
-	}
-	if livestatusEnabled {
-		livestatusConnector = &livestatus.Connector{Log: log, LivestatusAddress: cfg.Livestatus.Address, ConnectionType: cfg.Livestatus.Type}
-		livestatusCollector = livestatus.NewLivestatusCollector(resultQueues, livestatusConnector, cfg.Livestatus.Version)
-		livestatusCache = livestatus.NewLivestatusCacheBuilder(livestatusConnector)
-	}
-
-	for name, data := range cfg.ModGearman {
-		if data == nil {
-			continue
-		}
-		if !data.Enabled {
-			log.Debugf("Worker for Mod-Gearman: %s - %s %s will not be started, it is disabled", name, data.Address, data.Queue)
-			continue
-		}
-		if livestatusCache == nil {
-			log.Debugf("%s - %s %s will start with a nil livestatusCache, will not process downtime data in perf", name, data.Address, data.Queue)
-		}
-		log.Infof("Mod_Gearman: %s - %s [%s]", name, data.Address, data.Queue)
-		secret := modgearman.GetSecret(data.Secret, data.SecretFile)
-		for range data.Worker {
-			gearmanWorker := modgearman.NewGearmanWorker(data.Address,
-				data.Queue,
-				secret,
-				resultQueues,
-				livestatusCache,
-			)
-			stoppables = append(stoppables, gearmanWorker)
-		}
-	}
+	components.applyCollectors(components.collectorSpecs(cfg))
 
-	var nagiosCollector *spoolfile.NagiosSpoolfileCollector
-	var err error
-	// nagios spoolfile collection is enabled by default
-	nagiosSpoolFileCollectorEnabled := true
-	if search, found := helper.GetPreferredConfigValue(cfg, "NagiosSpoolfile.Enabled", []string{}); found {
-		ptr, ok := search.(*bool)
-		if ok {
-			nagiosSpoolFileCollectorEnabled = *(ptr)
+	checkActiveModuleCount(components.stoppables())
+	if cfg.Monitoring.PrometheusAddress != "" {
+		// the admin API can pause targets and replay data, so it's only served with a token
+		adminTokens := slices.DeleteFunc(slices.Clone(cfg.Monitoring.AdminToken), func(token string) bool { return token == "" })
+		if len(adminTokens) == 0 {
+			log.Info("The admin API is disabled, set Monitoring.AdminToken to enable it")
 		} else {
-			log.Warnf("Expected a *bool value out of the config value for Nagios Spoolfile Collection Enablement")
+			statistics.Handle("/api/", admin.NewHandler(components, adminTokens))
 		}
 	}
 
-	if nagiosSpoolFileCollectorEnabled {
-		nagiosCollector, err = spoolfile.NagiosSpoolfileCollectorFactory(
-			cfg,
-			resultQueues,
-			livestatusCache,
-			cfg.Main.FileBufferSize,
-			collector.Filterable{Filter: cfg.Main.DefaultTarget},
-		)
-		if err != nil {
-			log.Criticalf("Error when setting up NagiosSpoolfileCollectorFactory: %s", err.Error())
-			<-quit
-		}
-	}
-
-	// nagflux spoolfile collection is enabled by default
-	var nagfluxCollector *nagflux.FileCollector
-	nagfluxCollectorEnabled := true
-	if val, found := helper.GetPreferredConfigValue(cfg, "NagfluxSpoolfile.Enabled", []string{}); found {
-		ptr, ok := val.(*bool)
-		if ok {
-			nagfluxCollectorEnabled = *(ptr)
-		} else {
-			log.Warnf("Expected a *bool value out of the config value for Nagflux Spoolfile Enablement")
-		}
-	}
-	if nagfluxCollectorEnabled {
-		nagfluxCollectorFolderString := ""
-		nagfluxCollectorFolderSearch, found := helper.GetPreferredConfigValue(cfg, "NagfluxSpoolfile.Folder", []string{"Main.NagfluxSpoolfileFolder"})
-		if !found {
-			log.Criticalf("Could not find a config value for Nagflux Spoolfile Folder")
-			<-quit
-		}
-		nagfluxCollectionFolderPtr, ok := nagfluxCollectorFolderSearch.(*string)
-		if ok {
-			nagfluxCollectorFolderString = *(nagfluxCollectionFolderPtr)
-		} else {
-			log.Warnf("Expected a *string value out of the config value for Nagflux Spoolfile Folder")
-		}
-
-		if found {
-			log.Info("Nagflux Spoolfile Folder: ", nagfluxCollectorFolderString)
-			nagfluxCollector = nagflux.NewNagfluxFileCollector(resultQueues, nagfluxCollectorFolderString, fieldSeparator)
-		}
-	}
-
-	checkActiveModuleCount(stoppables)
-
 	// Listen for Interrupts
 	signalChannel := make(chan os.Signal, 1)
 	signal.Notify(signalChannel, syscall.SIGINT)
 	signal.Notify(signalChannel, syscall.SIGTERM)
 	signal.Notify(signalChannel, syscall.SIGUSR1)
+	signal.Notify(signalChannel, syscall.SIGHUP)
 	go func() {
 		for {
 			switch <-signalChannel {
 			case syscall.SIGINT, syscall.SIGTERM:
 				log.Warn("Got Interrupted")
-				if livestatusCollector != nil {
-					stoppables = append(stoppables, livestatusCollector)
-				}
-				if livestatusCache != nil {
-					stoppables = append(stoppables, livestatusCache)
-				}
-				if nagiosCollector != nil {
-					stoppables = append(stoppables, nagiosCollector)
-				}
-				if nagfluxCollector != nil {
-					stoppables = append(stoppables, nagfluxCollector)
-				}
-				cleanUp(stoppables, resultQueues)
+				cleanUp(components.stoppables(), resultQueues)
 				quit <- true
 				return
+			case syscall.SIGHUP:
+				components.reload(configPath)
 			case syscall.SIGUSR1:
 				buf := make([]byte, 1<<16)
 				n := runtime.Stack(buf, true)
@@ -290,6 +149,46 @@ For further informations / bugs reports: https://github.com/ConSol-Monitoring/na
 	<-quit
 }
 
+// Puts a write-ahead log between the queue and the workers of the target, if it is enabled.
+// Returns nil if the log is disabled or could not be opened, the target reads from the queue directly then.
+func newWriteAheadLog(cfg config.Config, queue chan collector.Printable, target data.Target, render wal.RenderFunc) *wal.Log {
+	if !cfg.WAL.Enabled {
+		return nil
+	}
+	if cfg.WAL.Folder == "" {
+		log.Criticalf("WAL.Folder is empty, %s will run without write-ahead log", target.Name)
+		return nil
+	}
+	writeAheadLog, err := wal.NewLog(queue, target, cfg.WAL.Folder, cfg.WAL.SegmentSize, cfg.WAL.MaxSize, cfg.WAL.MaxAge, cfg.Main.BufferSize, render)
+	if err != nil {
+		log.Criticalf("Could not open the write-ahead log for %s, it will run without: %s", target.Name, err.Error())
+		return nil
+	}
+	return writeAheadLog
+}
+
+// Opens the write-ahead log of a target without a text format of its own, like spoolfile.RenderRecord,
+// and returns the queue its workers have to read from.
+func recordWriteAheadLog(cfg config.Config, queue chan collector.Printable, target data.Target,
+	renderRecord func(collector.Printable, data.Datatype) string,
+) ([]Stoppable, chan collector.Printable) {
+	writeAheadLog := newWriteAheadLog(cfg, queue, target, recordRender(target, renderRecord))
+	if writeAheadLog == nil {
+		return nil, queue
+	}
+	return []Stoppable{writeAheadLog}, writeAheadLog.Output()
+}
+
+// Renders the Printables for the target by renderRecord, after applying the target filter.
+func recordRender(target data.Target, renderRecord func(collector.Printable, data.Datatype) string) func(collector.Printable) string {
+	return func(p collector.Printable) string {
+		if !p.TestTargetFilter(target.Name) {
+			return ""
+		}
+		return renderRecord(p, target.Datatype)
+	}
+}
+
 func waitForDumpfileCollector(dump *nagflux.DumpfileCollector) {
 	if dump != nil {
 		for i := 0; i < 30 && dump.IsRunning; i++ {
@@ -301,10 +200,8 @@ func waitForDumpfileCollector(dump *nagflux.DumpfileCollector) {
 // Wait till the Performance Data is sent.
 func cleanUp(itemsToStop []Stoppable, resultQueues collector.ResultQueues) {
 	log.Info("Cleaning up...")
-	for i := len(itemsToStop) - 1; i >= 0; i-- {
-		itemsToStop[i].Stop()
-	}
-	for _, q := range resultQueues {
+	stopAll(itemsToStop)
+	for _, q := range resultQueues.Copy() {
 		log.Debugf("Remaining queries %d", len(q))
 	}
 }
@@ -0,0 +1,347 @@
+package nagflux
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/modgearman"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper/cryptohelper"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/elasticsearch"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/file/archive"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/graphite"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/otlp"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/prometheus"
+)
+
+// The input formats of the parse subcommand.
+const (
+	formatNagios  = "nagios"
+	formatGearman = "gearman"
+	formatNagflux = "nagflux"
+)
+
+// Renders the data which a target would send, excluded is true if the target filter does not match.
+type dryRunTarget struct {
+	name   string
+	render func(p collector.Printable) (lines []string, excluded bool)
+}
+
+// Parses the input like the collectors and prints what every target would send.
+type dryRun struct {
+	out            io.Writer
+	worker         *spoolfile.NagiosSpoolfileWorker
+	decrypter      *cryptohelper.AESECBDecrypter
+	fieldSeparator rune
+	targets        []dryRunTarget
+}
+
+// runParse is the parse subcommand, it reads from the given files or stdin and returns the exit code.
+func runParse(args []string, stdin io.Reader, out io.Writer) int {
+	flags := flag.NewFlagSet("parse", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configPath := flags.String("configPath", "config.gcfg", "path to the config file")
+	format := flags.String("format", formatNagios, "input format: nagios (spoolfile lines), gearman (Mod-Gearman payloads, one per line) or nagflux (CSV)")
+	secret := flags.String("secret", "", "key of the Mod-Gearman payloads, the secret of the enabled ModGearman section is used if it's empty")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	// the messages of the constructors are not of interest
+	logging.SetOutput(io.Discard, "", "ERROR")
+	if err := config.ReloadConfig(*configPath); err != nil {
+		fmt.Fprintf(out, "could not read the config %s: %s\n", *configPath, err)
+		return 2
+	}
+	d, err := newDryRun(config.GetConfig(), *format, *secret, out)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 2
+	}
+	logging.SetOutput(out, `  [%{Severity}] %{Message}`, "INFO")
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		input := stdin
+		if file != "-" {
+			fileHandle, err := os.Open(file)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				return 1
+			}
+			defer fileHandle.Close()
+			input = fileHandle
+		}
+		if err := d.parse(*format, input); err != nil {
+			fmt.Fprintf(out, "%s: %s\n", file, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func newDryRun(cfg config.Config, format, secret string, out io.Writer) (*dryRun, error) {
+	if format != formatNagios && format != formatGearman && format != formatNagflux {
+		return nil, fmt.Errorf("the format %q is not supported, use %s, %s or %s", format, formatNagios, formatGearman, formatNagflux)
+	}
+	limits, err := spoolfile.PerfdataLimitsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	d := &dryRun{
+		out: out,
+		worker: spoolfile.NewNagiosSpoolfileWorker(-1, make(chan string), make(collector.ResultQueues), nil, cfg.Main.FileBufferSize,
+			collector.Filterable{Filter: cfg.Main.DefaultTarget}, limits.Label, limits.UOM, limits.NumericValues, limits.Thresholds),
+		targets: dryRunTargets(cfg),
+	}
+	d.worker.IsolateCounters()
+	if len(cfg.Main.FieldSeparator) > 0 {
+		d.fieldSeparator = []rune(cfg.Main.FieldSeparator)[0]
+	}
+	if format == formatGearman {
+		if secret == "" {
+			for _, name := range slices.Sorted(maps.Keys(cfg.ModGearman)) {
+				if gearman := cfg.ModGearman[name]; gearman != nil && gearman.Enabled {
+					secret = modgearman.GetSecret(gearman.Secret, gearman.SecretFile)
+					break
+				}
+			}
+		}
+		if secret != "" {
+			if d.decrypter, err = cryptohelper.NewAESECBDecrypter(modgearman.ShapeKey(secret, modgearman.DefaultModGearmanKeyLength)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(d.targets) == 0 {
+		return nil, errors.New("no target is enabled in the config")
+	}
+	return d, nil
+}
+
+func (d *dryRun) parse(format string, input io.Reader) error {
+	if format == formatNagflux {
+		printables, err := nagflux.ParseCSV(input, d.fieldSeparator)
+		for i := range printables {
+			fmt.Fprintf(d.out, "record %d:\n", i+1)
+			d.printTargets([]collector.Printable{&printables[i]})
+		}
+		return err
+	}
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 4096), max(4096, config.GetConfig().Main.FileBufferSize))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		fmt.Fprintf(d.out, "line %d: %s\n", lineNumber, line)
+		if format == formatGearman && d.decrypter != nil {
+			decrypted, err := d.decrypter.Decypt(line)
+			if err != nil {
+				fmt.Fprintf(d.out, "  could not decrypt the payload: %s\n", err)
+				continue
+			}
+			line = []byte(strings.TrimRight(string(decrypted), "\x00"))
+			fmt.Fprintf(d.out, "  decrypted: %s\n", line)
+		}
+		perfdata, ok := d.worker.ParseLine(line)
+		switch {
+		case !ok:
+			fmt.Fprintln(d.out, "  skipped, it matches none of the Filter.SpoolFileLineTerms")
+		case len(perfdata) == 0:
+			fmt.Fprintln(d.out, "  no perfdata found")
+		default:
+			printables := make([]collector.Printable, len(perfdata))
+			for i, p := range perfdata {
+				printables[i] = p
+			}
+			d.printTargets(printables)
+		}
+	}
+	return scanner.Err()
+}
+
+func (d *dryRun) printTargets(printables []collector.Printable) {
+	for _, t := range d.targets {
+		var lines []string
+		excluded := 0
+		for _, printable := range printables {
+			rendered, isExcluded := t.render(printable)
+			if isExcluded {
+				excluded++
+			}
+			lines = append(lines, rendered...)
+		}
+		switch {
+		case excluded == len(printables):
+			fmt.Fprintf(d.out, "  %s: excluded by the target filter\n", t.name)
+		case len(lines) == 0:
+			fmt.Fprintf(d.out, "  %s: nothing to send\n", t.name)
+		default:
+			fmt.Fprintf(d.out, "  %s:\n", t.name)
+			for _, line := range lines {
+				fmt.Fprintf(d.out, "    %s\n", line)
+			}
+		}
+	}
+}
+
+// Returns the enabled targets of the config, rendering the data like their workers.
+//
+//nolint:funlen
+func dryRunTargets(cfg config.Config) []dryRunTarget {
+	var targets []dryRunTarget
+	add := func(datatype data.Datatype, name string, render func(collector.Printable) []string, filtered bool) {
+		targets = append(targets, dryRunTarget{
+			name: fmt.Sprintf("%s %q", datatype, name),
+			render: func(p collector.Printable) ([]string, bool) {
+				if filtered && !p.TestTargetFilter(name) {
+					return nil, true
+				}
+				return render(p), false
+			},
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.InfluxDB)) {
+		if influxConfig := cfg.InfluxDB[name]; influxConfig != nil && influxConfig.Enabled {
+			add(data.InfluxDB, name, func(p collector.Printable) []string {
+				return splitLines(p.PrintForInfluxDB(influxConfig.Version))
+			}, true)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Elasticsearch)) {
+		if elasticConfig := cfg.Elasticsearch[name]; elasticConfig != nil && elasticConfig.Enabled {
+			add(data.Elasticsearch, name, func(p collector.Printable) []string {
+				return splitLines(p.PrintForElasticsearch(helper.ElasticsearchBulk{
+					Version:    elasticConfig.Version,
+					OpenSearch: elasticConfig.Distribution == elasticsearch.DistributionOpenSearch,
+					Index:      elasticConfig.Index,
+					DataStream: elasticConfig.DataStream,
+				}))
+			}, false)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Prometheus)) {
+		if prometheusConfig := cfg.Prometheus[name]; prometheusConfig != nil && prometheusConfig.Enabled {
+			add(data.Prometheus, name, func(p collector.Printable) []string {
+				perf, ok := p.(*spoolfile.PerformanceData)
+				if !ok {
+					return nil
+				}
+				var lines []string
+				for _, series := range prometheus.PerformanceDataToTimeSeries(perf, prometheusConfig.MetricPrefix, prometheusConfig.HostcheckAlias) {
+					lines = append(lines, formatTimeSeries(series))
+				}
+				return lines
+			}, true)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Graphite)) {
+		if graphiteConfig := cfg.Graphite[name]; graphiteConfig != nil && graphiteConfig.Enabled {
+			pathBuilder := graphite.NewPathBuilder(graphiteConfig.PathTemplate, graphiteConfig.Prefix, graphiteConfig.HostcheckAlias)
+			add(data.Graphite, name, func(p collector.Printable) []string {
+				perf, ok := p.(*spoolfile.PerformanceData)
+				if !ok {
+					return nil
+				}
+				return splitLines(string(graphite.EncodePlaintext(pathBuilder.Metrics(perf))))
+			}, true)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.OTLP)) {
+		if otlpConfig := cfg.OTLP[name]; otlpConfig != nil && otlpConfig.Enabled {
+			add(data.OTLP, name, func(p collector.Printable) []string {
+				batch := otlp.NewBatch(otlpConfig.MetricPrefix, otlpConfig.HostcheckAlias)
+				switch printable := p.(type) {
+				case *spoolfile.PerformanceData:
+					batch.AddPerformanceData(printable)
+				case otlp.EventSource:
+					batch.AddEvents(printable.Events())
+				}
+				var lines []string
+				if metrics, ok := batch.Metrics(); ok {
+					lines = append(lines, marshalLine(metrics))
+				}
+				if logs, ok := batch.Logs(); ok {
+					lines = append(lines, marshalLine(logs))
+				}
+				return lines
+			}, true)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.JSONFileExport)) {
+		if jsonFileConfig := cfg.JSONFileExport[name]; jsonFileConfig != nil && jsonFileConfig.Enabled {
+			add(data.JSONFile, name, func(p collector.Printable) []string {
+				var lines []string
+				for _, event := range p.PrintForJSON() {
+					lines = append(lines, marshalLine(event))
+				}
+				return lines
+			}, true)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.FileExport)) {
+		if fileConfig := cfg.FileExport[name]; fileConfig != nil && fileConfig.Enabled {
+			settings, err := archive.SettingsFromConfig(name, cfg)
+			if err != nil {
+				continue
+			}
+			add(data.File, name, func(p collector.Printable) []string {
+				out, err := archive.Encode(p, settings.Format)
+				if err != nil {
+					return append(splitLines(string(out)), err.Error())
+				}
+				return splitLines(string(out))
+			}, true)
+		}
+	}
+	return targets
+}
+
+func splitLines(text string) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func marshalLine(v any) string {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+// Formats the series like the Prometheus text format.
+func formatTimeSeries(series prometheus.TimeSeries) string {
+	name := ""
+	var labels []string
+	for _, label := range series.Labels {
+		if label.Name == "__name__" {
+			name = label.Value
+			continue
+		}
+		labels = append(labels, label.Name+"="+strconv.Quote(label.Value))
+	}
+	return fmt.Sprintf("%s{%s} %s %d", name, strings.Join(labels, ","), strconv.FormatFloat(series.Value, 'f', -1, 64), series.Timestamp)
+}
@@ -0,0 +1,61 @@
+package nagflux
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunParse(t *testing.T) {
+	previous := config.GetConfig()
+	t.Cleanup(func() {
+		config.SetConfig(previous)
+		logging.InitTestLogger()
+	})
+	configPath := filepath.Join(t.TempDir(), "config.gcfg")
+	assert.NoError(t, os.WriteFile(configPath, []byte(`
+[main]
+    FieldSeparator = "&"
+    BufferSize = 10
+    FileBufferSize = 65536
+    DefaultTarget = "all"
+[Filter]
+    SpoolFileLineTerms = "HOSTNAME::xxx"
+[InfluxDB "nagflux"]
+    Enabled = true
+    Version = "1.11"
+[JSONFileExport "export"]
+    Enabled = true
+    Path = "/tmp"
+`), 0o600))
+
+	input := strings.Join([]string{
+		"DATATYPE::SERVICEPERFDATA\tTIMET::1441791000\tHOSTNAME::xxx\tSERVICEDESC::range\tSERVICEPERFDATA::a=4;2;10\tSERVICECHECKCOMMAND::check_ranges\tSERVICESTATE::0\tSERVICESTATETYPE::1",
+		"",
+		"DATATYPE::SERVICEPERFDATA\tTIMET::1441791000\tHOSTNAME::skipped\tSERVICEDESC::range\tSERVICEPERFDATA::a=4\tSERVICECHECKCOMMAND::check_ranges\tSERVICESTATE::0\tSERVICESTATETYPE::1",
+		"DATATYPE::SERVICEPERFDATA\tTIMET::1441791000\tHOSTNAME::xxx\tSERVICEDESC::range\tSERVICEPERFDATA::\tSERVICECHECKCOMMAND::check_ranges\tSERVICESTATE::0\tSERVICESTATETYPE::1",
+	}, "\n")
+	var out bytes.Buffer
+	assert.Equal(t, 0, runParse([]string{"-configPath", configPath}, strings.NewReader(input), &out))
+
+	result := out.String()
+	assert.Contains(t, result, "line 1: DATATYPE::SERVICEPERFDATA")
+	// the order of the tags and fields is random
+	assert.Contains(t, result, `  influx "nagflux":`+"\n    metrics,host=xxx,service=range,command=check_ranges,performanceLabel=a,")
+	assert.Regexp(t, `\n    metrics,.*warn-fill=none.* .*crit=10\.0.* 1441791000000\n`, result)
+	assert.Contains(t, result, `  json "export":`+"\n    {\"schema_version\":1,\"type\":\"metric\",\"timestamp\":1441791000000,\"host\":\"xxx\"")
+	assert.NotContains(t, result, "line 2:", "empty lines are ignored")
+	assert.Contains(t, result, "line 3: DATATYPE::SERVICEPERFDATA\tTIMET::1441791000\tHOSTNAME::skipped")
+	assert.Contains(t, result, "  skipped, it matches none of the Filter.SpoolFileLineTerms")
+	assert.Contains(t, result, "  no perfdata found")
+
+	out.Reset()
+	assert.Equal(t, 2, runParse([]string{"-configPath", configPath, "-format", "xml"}, strings.NewReader(input), &out))
+	assert.Contains(t, out.String(), `the format "xml" is not supported`)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // PrometheusServer stores all prometheus metrics
@@ -23,12 +24,24 @@ type PrometheusServer struct {
 	SpoolFilesLines          prometheus.Counter
 	BytesSend                *prometheus.CounterVec
 	SendDuration             *prometheus.CounterVec
+	WALPendingRecords        *prometheus.GaugeVec
+	SpilledQueries           *prometheus.CounterVec
+	CircuitBreakerState      *prometheus.GaugeVec
+	Retries                  *prometheus.CounterVec
+	SendLatency              *prometheus.HistogramVec
+	Workers                  *prometheus.GaugeVec
+	CardinalitySeries        prometheus.Gauge
+	CardinalityTopSeries     *prometheus.GaugeVec
+	CardinalityLimited       *prometheus.CounterVec
+	BulkItems                *prometheus.CounterVec
 }
 
 var (
 	server             PrometheusServer
 	pMutex             = &sync.Mutex{}
 	prometheusListener net.Listener
+	// serves the metrics and everything registered by Handle
+	serveMux = http.NewServeMux()
 )
 
 func initServerConfig() PrometheusServer {
@@ -96,12 +109,98 @@ func initServerConfig() PrometheusServer {
 			Help:      "Time per package to sent to database",
 		}, []string{"type"})
 	prometheus.MustRegister(SendDuration)
+	WALPendingRecords := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "nagflux",
+			Subsystem: "wal",
+			Name:      "pending_records",
+			Help:      "Records in the write-ahead log which are not acknowledged by the target",
+		}, []string{"target"})
+	prometheus.MustRegister(WALPendingRecords)
+	SpilledQueries := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "nagflux",
+			Subsystem: "target",
+			Name:      "spilled_queries",
+			Help:      "Queries which were buffered on disk while the target was down",
+		}, []string{"target"})
+	prometheus.MustRegister(SpilledQueries)
+	CircuitBreakerState := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "nagflux",
+			Subsystem: "target",
+			Name:      "circuit_breaker_state",
+			Help:      "State of the circuit breaker: 0 closed, 1 half-open, 2 open",
+		}, []string{"target"})
+	prometheus.MustRegister(CircuitBreakerState)
+	Retries := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "nagflux",
+			Subsystem: "target",
+			Name:      "retries",
+			Help:      "Retries to send data to the target",
+		}, []string{"target"})
+	prometheus.MustRegister(Retries)
+	SendLatency := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "nagflux",
+			Subsystem: "target",
+			Name:      "send_latency_seconds",
+			Help:      "Time a worker needs to send a batch including the retries",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60},
+		}, []string{"target"})
+	prometheus.MustRegister(SendLatency)
+	Workers := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "nagflux",
+			Subsystem: "target",
+			Name:      "workers",
+			Help:      "Current amount of workers",
+		}, []string{"target"})
+	prometheus.MustRegister(Workers)
+	CardinalitySeries := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "nagflux",
+			Subsystem: "cardinality",
+			Name:      "series",
+			Help:      "Unique host/service/label series in the current window",
+		})
+	prometheus.MustRegister(CardinalitySeries)
+	CardinalityTopSeries := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "nagflux",
+			Subsystem: "cardinality",
+			Name:      "top_series",
+			Help:      "Series in the current window of the hosts and commands with the most series",
+		}, []string{"scope", "name"})
+	prometheus.MustRegister(CardinalityTopSeries)
+	CardinalityLimited := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "nagflux",
+			Subsystem: "cardinality",
+			Name:      "limited",
+			Help:      "Perfdata of new series above a limit, by the exceeded limit and the action",
+		}, []string{"scope", "action"})
+	prometheus.MustRegister(CardinalityLimited)
+	BulkItems := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "nagflux",
+			Subsystem: "target",
+			Name:      "bulk_items",
+			Help:      "Documents of the Elasticsearch bulk requests by their result: ok, retryable or permanent",
+		}, []string{"target", "result"})
+	prometheus.MustRegister(BulkItems)
 
 	return PrometheusServer{
 		bufferLength: bufferLength, SpoolFilesOnDisk: spoolFilesOnDisk,
 		SpoolFilesInQueue: SpoolFilesInQueue, SpoolFilesParsedDuration: SpoolFilesParsedDuration,
 		SpoolFilesLines: SpoolFilesParsedSize, SpoolFilesParsed: SpoolFilesParsed,
 		BytesSend: BytesSend, SendDuration: SendDuration,
+		WALPendingRecords: WALPendingRecords, SpilledQueries: SpilledQueries,
+		CircuitBreakerState: CircuitBreakerState, Retries: Retries,
+		SendLatency: SendLatency, Workers: Workers,
+		CardinalitySeries: CardinalitySeries, CardinalityTopSeries: CardinalityTopSeries,
+		CardinalityLimited: CardinalityLimited, BulkItems: BulkItems,
 	}
 }
 
@@ -112,8 +211,8 @@ func NewPrometheusServer(address string) PrometheusServer {
 	pMutex.Unlock()
 	if address != "" {
 		go func() {
-			http.Handle("/metrics", promhttp.Handler())
-			if err := http.ListenAndServe(address, nil); err != nil {
+			serveMux.Handle("/metrics", promhttp.Handler())
+			if err := http.ListenAndServe(address, serveMux); err != nil {
 				logging.GetLogger().Warn(err.Error())
 			}
 		}()
@@ -122,6 +221,11 @@ func NewPrometheusServer(address string) PrometheusServer {
 	return server
 }
 
+// Handle registers a handler which is served next to the metrics, if a PrometheusAddress is configured.
+func Handle(pattern string, handler http.Handler) {
+	serveMux.Handle(pattern, handler)
+}
+
 // GetPrometheusServer returns the single Prometheusserver
 func GetPrometheusServer() PrometheusServer {
 	return server
@@ -131,7 +235,7 @@ func GetPrometheusServer() PrometheusServer {
 func (s PrometheusServer) WatchResultQueueLength(channels collector.ResultQueues) {
 	go func() {
 		for {
-			for k, c := range channels {
+			for k, c := range channels.Copy() {
 				s.bufferLength.WithLabelValues(fmt.Sprint(k)).Set(float64(len(c)))
 			}
 			time.Sleep(100 * time.Millisecond)
@@ -139,6 +243,19 @@ func (s PrometheusServer) WatchResultQueueLength(channels collector.ResultQueues
 	}()
 }
 
+// SendLatencyTotals returns the summed up send latency in seconds and the amount of sent batches of the target.
+func (s PrometheusServer) SendLatencyTotals(target string) (float64, uint64) {
+	metric, ok := s.SendLatency.WithLabelValues(target).(prometheus.Metric)
+	if !ok {
+		return 0, 0
+	}
+	result := &dto.Metric{}
+	if err := metric.Write(result); err != nil || result.GetHistogram() == nil {
+		return 0, 0
+	}
+	return result.GetHistogram().GetSampleSum(), result.GetHistogram().GetSampleCount()
+}
+
 // Stop stops the Server
 func (s PrometheusServer) Stop() {
 	prometheusListener.Close()
@@ -0,0 +1,6 @@
+package target
+
+// Flushable is a interface to represent a target whose workers buffer data, Flush makes them send it immediately.
+type Flushable interface {
+	Flush()
+}
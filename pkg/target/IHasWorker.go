@@ -4,4 +4,5 @@ package target
 type HasWorker interface {
 	AddWorker()
 	RemoveWorker()
+	AmountWorkers() int
 }
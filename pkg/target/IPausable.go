@@ -0,0 +1,8 @@
+package target
+
+// Pausable is a interface to represent a target which can hold back its data while it is paused.
+type Pausable interface {
+	Pause()
+	Resume()
+	IsPaused() bool
+}
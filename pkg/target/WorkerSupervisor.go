@@ -0,0 +1,176 @@
+package target
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/kdar/factorlog"
+)
+
+// WorkerSupervisor scales the workers of the targets by the fill level of their queue and the time their workers spend sending.
+type WorkerSupervisor struct {
+	quit           chan bool
+	log            *factorlog.FactorLog
+	mutex          sync.Mutex
+	watched        []*watchedTarget
+	minWorkers     int
+	maxWorkers     int
+	interval       time.Duration
+	highWatermark  float64
+	lowWatermark   float64
+	maxBusy        float64
+	scaleDownAfter int
+	// returns the summed up send latency in seconds and the amount of sent batches
+	latency func(target data.Target) (float64, uint64)
+}
+
+type watchedTarget struct {
+	target       data.Target
+	queue        chan collector.Printable
+	connector    HasWorker
+	latencySum   float64
+	latencyCount uint64
+	calmTicks    int
+}
+
+// isAliveChecker is implemented by connectors which know if their target is reachable.
+type isAliveChecker interface {
+	IsAlive() bool
+}
+
+// NewWorkerSupervisor creates a supervisor which keeps the amount of workers between minWorkers and maxWorkers and starts it.
+// The thresholds are read from the WorkerScaling section.
+func NewWorkerSupervisor(minWorkers, maxWorkers int) *WorkerSupervisor {
+	cfg := config.GetConfig().WorkerScaling
+	s := &WorkerSupervisor{
+		quit:           make(chan bool),
+		log:            logging.GetLogger(),
+		minWorkers:     max(minWorkers, 1),
+		maxWorkers:     maxWorkers,
+		interval:       time.Duration(10) * time.Second,
+		highWatermark:  0.5,
+		lowWatermark:   0.1,
+		maxBusy:        0.8,
+		scaleDownAfter: 6,
+		latency: func(target data.Target) (float64, uint64) {
+			return statistics.GetPrometheusServer().SendLatencyTotals(target.String())
+		},
+	}
+	if cfg.Interval > 0 {
+		s.interval = time.Duration(cfg.Interval) * time.Second
+	}
+	if cfg.HighWatermark > 0 {
+		s.highWatermark = cfg.HighWatermark
+	}
+	if cfg.LowWatermark > 0 {
+		s.lowWatermark = cfg.LowWatermark
+	}
+	if cfg.MaxBusy > 0 {
+		s.maxBusy = cfg.MaxBusy
+	}
+	if cfg.ScaleDownAfter > 0 {
+		s.scaleDownAfter = cfg.ScaleDownAfter
+	}
+	if s.lowWatermark >= s.highWatermark {
+		s.log.Warnf("WorkerScaling LowWatermark %f has to be below HighWatermark %f, using %f", s.lowWatermark, s.highWatermark, s.highWatermark/5)
+		s.lowWatermark = s.highWatermark / 5
+	}
+	go s.run()
+	return s
+}
+
+// Watch adds a target, queue has to be the channel its workers are reading from. Does nothing if the supervisor is nil.
+func (s *WorkerSupervisor) Watch(target data.Target, queue chan collector.Printable, connector HasWorker) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	w := &watchedTarget{target: target, queue: queue, connector: connector}
+	w.latencySum, w.latencyCount = s.latency(target)
+	s.watched = append(s.watched, w)
+	statistics.GetPrometheusServer().Workers.WithLabelValues(target.String()).Set(float64(connector.AmountWorkers()))
+}
+
+// Unwatch removes a target. Does nothing if the supervisor is nil.
+func (s *WorkerSupervisor) Unwatch(target data.Target) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, w := range s.watched {
+		if w.target == target {
+			s.watched = append(s.watched[:i], s.watched[i+1:]...)
+			return
+		}
+	}
+}
+
+// Stop stops the supervisor, the workers are left as they are.
+func (s *WorkerSupervisor) Stop() {
+	s.quit <- true
+	<-s.quit
+	s.log.Debug("WorkerSupervisor stopped")
+}
+
+func (s *WorkerSupervisor) run() {
+	for {
+		select {
+		case <-s.quit:
+			s.quit <- true
+			return
+		case <-time.After(s.interval):
+			s.mutex.Lock()
+			for _, w := range s.watched {
+				s.scale(w)
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// Adds a worker if the queue is filling up or the workers are busy, removes one if both calmed down for scaleDownAfter intervals.
+func (s *WorkerSupervisor) scale(w *watchedTarget) {
+	workers := w.connector.AmountWorkers()
+	if workers == 0 || cap(w.queue) == 0 {
+		return
+	}
+	fill := float64(len(w.queue)) / float64(cap(w.queue))
+
+	// share of the interval the workers spent sending
+	latencySum, latencyCount := s.latency(w.target)
+	busy := 0.0
+	if latencyCount > w.latencyCount {
+		busy = (latencySum - w.latencySum) / (s.interval.Seconds() * float64(workers))
+	}
+	w.latencySum, w.latencyCount = latencySum, latencyCount
+
+	switch {
+	case fill >= s.highWatermark || busy >= s.maxBusy:
+		w.calmTicks = 0
+		if alive, ok := w.connector.(isAliveChecker); ok && !alive.IsAlive() {
+			// more workers would not help a target which is down
+			return
+		}
+		if workers < s.maxWorkers {
+			s.log.Infof("Scaling up %s: queue %.0f%%, busy %.0f%%", w.target, fill*100, busy*100)
+			w.connector.AddWorker()
+		}
+	case fill <= s.lowWatermark && busy < s.maxBusy/2:
+		w.calmTicks++
+		if w.calmTicks >= s.scaleDownAfter && workers > s.minWorkers {
+			s.log.Infof("Scaling down %s: queue %.0f%%, busy %.0f%%", w.target, fill*100, busy*100)
+			w.connector.RemoveWorker()
+			w.calmTicks = 0
+		}
+	default:
+		w.calmTicks = 0
+	}
+	statistics.GetPrometheusServer().Workers.WithLabelValues(w.target.String()).Set(float64(w.connector.AmountWorkers()))
+}
@@ -0,0 +1,106 @@
+package target
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeConnector struct {
+	workers int
+	alive   bool
+}
+
+func (f *fakeConnector) AddWorker()         { f.workers++ }
+func (f *fakeConnector) RemoveWorker()      { f.workers-- }
+func (f *fakeConnector) AmountWorkers() int { return f.workers }
+func (f *fakeConnector) IsAlive() bool      { return f.alive }
+
+func TestMain(m *testing.M) {
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+func newTestSupervisor() (*WorkerSupervisor, *float64, *uint64) {
+	latencySum := 0.0
+	latencyCount := uint64(0)
+	s := &WorkerSupervisor{
+		log: logging.GetLogger(), minWorkers: 2, maxWorkers: 4, interval: 10 * time.Second,
+		highWatermark: 0.5, lowWatermark: 0.1, maxBusy: 0.8, scaleDownAfter: 3,
+		latency: func(data.Target) (float64, uint64) { return latencySum, latencyCount },
+	}
+	return s, &latencySum, &latencyCount
+}
+
+func fillQueue(queue chan collector.Printable, amount int) {
+	for len(queue) > 0 {
+		<-queue
+	}
+	for range amount {
+		queue <- nil
+	}
+}
+
+func TestWorkerSupervisorQueueFill(t *testing.T) {
+	logging.InitTestLogger()
+	s, _, _ := newTestSupervisor()
+	connector := &fakeConnector{workers: 2, alive: true}
+	queue := make(chan collector.Printable, 10)
+	s.Watch(data.Target{Name: "test", Datatype: data.InfluxDB}, queue, connector)
+	w := s.watched[0]
+
+	fillQueue(queue, 6)
+	for range 5 {
+		s.scale(w)
+	}
+	assert.Equal(t, 4, connector.workers, "should scale up to maxWorkers")
+
+	fillQueue(queue, 3)
+	s.scale(w)
+	assert.Equal(t, 4, connector.workers, "should keep the workers between the watermarks")
+
+	fillQueue(queue, 0)
+	s.scale(w)
+	s.scale(w)
+	assert.Equal(t, 4, connector.workers, "should wait scaleDownAfter intervals")
+	s.scale(w)
+	assert.Equal(t, 3, connector.workers)
+	for range 10 {
+		s.scale(w)
+	}
+	assert.Equal(t, 2, connector.workers, "should not scale down below minWorkers")
+
+	connector.alive = false
+	fillQueue(queue, 10)
+	s.scale(w)
+	assert.Equal(t, 2, connector.workers, "should not scale up if the target is down")
+}
+
+func TestWorkerSupervisorBusy(t *testing.T) {
+	logging.InitTestLogger()
+	s, latencySum, latencyCount := newTestSupervisor()
+	connector := &fakeConnector{workers: 2, alive: true}
+	queue := make(chan collector.Printable, 10)
+	s.Watch(data.Target{Name: "test", Datatype: data.Elasticsearch}, queue, connector)
+	w := s.watched[0]
+
+	// two workers spent 18 of 20 seconds sending
+	*latencySum += 18
+	*latencyCount += 4
+	s.scale(w)
+	assert.Equal(t, 3, connector.workers)
+
+	// three workers spent 6 of 30 seconds sending, afterwards nothing is sent
+	*latencySum += 6
+	*latencyCount += 2
+	for range 3 {
+		s.scale(w)
+	}
+	assert.Equal(t, 2, connector.workers)
+}
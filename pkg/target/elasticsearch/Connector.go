@@ -1,47 +1,65 @@
 package elasticsearch
 
 import (
+	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
 	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
 	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
 	"github.com/kdar/factorlog"
 )
 
 // Connector makes the basic connection to an influxdb.
 type Connector struct {
-	connectionHost string
-	index          string
-	dumpFile       string
-	workers        []*Worker
-	maxWorkers     int
-	jobs           chan collector.Printable
-	quit           chan bool
-	log            *factorlog.FactorLog
-	version        string
-	isAlive        bool
-	templateExists bool
-	httpClient     http.Client
+	connectionHost  string
+	bulk            helper.ElasticsearchBulk
+	lifecyclePolicy string
+	dumpFile        string
+	workers         []*Worker
+	maxWorkers      int
+	jobs            chan collector.Printable
+	quit            chan bool
+	log             *factorlog.FactorLog
+	isAlive         bool
+	templateExists  bool
+	httpClient      http.Client
+	target          data.Target
+	retryPolicy     retry.Policy
+	breaker         *retry.Breaker
+	workersMutex    sync.Mutex
 }
 
 // ConnectorFactory Constructor which will create some workers if the connection is established.
-func ConnectorFactory(jobs chan collector.Printable, connectionHost, index, dumpFile, version string, workerAmount, maxWorkers int, createDatabaseIfNotExists bool) *Connector {
+// The authorization is the value of the Authorization header of every request, see Authorization.
+func ConnectorFactory(jobs chan collector.Printable, connectionHost, dumpFile string, bulk helper.ElasticsearchBulk, lifecyclePolicy, authorization string,
+	workerAmount, maxWorkers int, createDatabaseIfNotExists bool, target data.Target, tlsConfig *tls.Config,
+) *Connector {
 	if connectionHost[len(connectionHost)-1] != '/' {
 		connectionHost += "/"
 	}
+	var transport http.RoundTripper = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}
+	if authorization != "" {
+		transport = authTransport{base: transport, authorization: authorization}
+	}
 	s := &Connector{
-		connectionHost, index, dumpFile, make([]*Worker, workerAmount), maxWorkers,
-		jobs, make(chan bool), logging.GetLogger(), version,
+		connectionHost, bulk, lifecyclePolicy, dumpFile, make([]*Worker, workerAmount), maxWorkers,
+		jobs, make(chan bool), logging.GetLogger(),
 		false, false,
-		http.Client{Timeout: 5 * time.Second},
+		http.Client{Timeout: 5 * time.Second, Transport: transport},
+		target, retry.PolicyFromConfig(), nil, sync.Mutex{},
 	}
+	s.breaker = retry.NewBreaker(target, s.retryPolicy, s.TestIfIsAlive)
 
-	gen := WorkerGenerator(jobs, connectionHost+"_bulk", index, dumpFile, version, s)
+	gen := WorkerGenerator(jobs, connectionHost+"_bulk", dumpFile, bulk, s)
 
 	s.TestIfIsAlive()
 	for i := 0; i < 5 && !s.isAlive; i++ {
@@ -62,6 +80,9 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, index, dump
 	if !s.templateExists {
 		s.log.Panic("Template does not exists and was not able to created")
 	}
+	if bulk.OpenSearch && lifecyclePolicy != "" {
+		s.addISMPolicy()
+	}
 
 	for w := range workerAmount {
 		s.workers[w] = gen(w)
@@ -72,30 +93,49 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, index, dump
 
 // AddWorker creates a new worker
 func (connector *Connector) AddWorker() {
-	oldLength := connector.AmountWorkers()
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
+	oldLength := len(connector.workers)
 	if oldLength < connector.maxWorkers {
-		gen := WorkerGenerator(connector.jobs, connector.connectionHost+"/_bulk", connector.index, connector.dumpFile, connector.version, connector)
+		gen := WorkerGenerator(connector.jobs, connector.connectionHost+"_bulk", connector.dumpFile, connector.bulk, connector)
 		connector.workers = append(connector.workers, gen(oldLength+2))
-		connector.log.Infof("Starting Worker: %d -> %d", oldLength, connector.AmountWorkers())
+		connector.log.Infof("Starting Worker: %d -> %d", oldLength, len(connector.workers))
 	}
 }
 
 // RemoveWorker stops a worker
 func (connector *Connector) RemoveWorker() {
-	oldLength := connector.AmountWorkers()
-	if oldLength > 1 {
-		lastWorkerIndex := oldLength - 1
-		connector.workers[lastWorkerIndex].Stop()
-		connector.workers = connector.workers[:lastWorkerIndex]
-		connector.log.Infof("Stopping Worker: %d -> %d", oldLength, connector.AmountWorkers())
+	connector.workersMutex.Lock()
+	oldLength := len(connector.workers)
+	if oldLength <= 1 {
+		connector.workersMutex.Unlock()
+		return
 	}
+	// the worker is detached under the lock and stopped afterwards, stopping blocks until its data is sent
+	lastWorkerIndex := oldLength - 1
+	worker := connector.workers[lastWorkerIndex]
+	connector.workers = connector.workers[:lastWorkerIndex]
+	connector.workersMutex.Unlock()
+	connector.log.Infof("Stopping Worker: %d -> %d", oldLength, lastWorkerIndex)
+	worker.Stop()
 }
 
 // AmountWorkers current amount of workers.
 func (connector *Connector) AmountWorkers() int {
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
 	return len(connector.workers)
 }
 
+// Flush makes the workers send their buffered data.
+func (connector *Connector) Flush() {
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
+	for _, worker := range connector.workers {
+		worker.Flush()
+	}
+}
+
 // IsAlive is the database system alive.
 func (connector *Connector) IsAlive() bool {
 	return connector.isAlive
@@ -116,19 +156,15 @@ func (connector *Connector) Stop() {
 // Waits just for the end.
 func (connector *Connector) run() {
 	<-connector.quit
-	for _, worker := range connector.workers {
-		go worker.Stop()
-	}
-	for len(connector.workers) > 0 {
-		for connector.workers[0].IsRunning {
-			time.Sleep(time.Duration(100) * time.Millisecond)
-		}
-		if len(connector.workers) > 1 {
-			connector.workers = connector.workers[1:]
-		} else {
-			connector.workers = connector.workers[:0]
-		}
+	connector.workersMutex.Lock()
+	workers := connector.workers
+	connector.workers = nil
+	connector.workersMutex.Unlock()
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Go(worker.Stop)
 	}
+	wg.Wait()
 	connector.quit <- true
 }
 
@@ -141,146 +177,68 @@ func (connector *Connector) TestIfIsAlive() bool {
 
 // TestTemplateExists test active if the template exists.
 func (connector *Connector) TestTemplateExists() bool {
-	result, body := helper.SentReturnCodeIsOK(connector.httpClient, connector.connectionHost+"_template", "GET", "")
-	if result && strings.Contains(body, fmt.Sprintf(`"%s":`, connector.index)) {
-		connector.templateExists = true
-	} else {
-		connector.templateExists = false
-	}
+	result, body := helper.SentReturnCodeIsOK(connector.httpClient, connector.templateURL(), "GET", "")
+	// old versions answer with an empty object if the template is missing
+	connector.templateExists = result && strings.Contains(body, fmt.Sprintf(`"%s"`, connector.bulk.Index))
 	return connector.templateExists
 }
 
 // createTemplate creates the nagflux template.
 func (connector *Connector) createTemplate() bool {
-	mapping := fmt.Sprintf(NagfluxTemplate,
-		connector.index,
-		config.GetConfig().ElasticsearchGlobal.NumberOfShards,
-		config.GetConfig().ElasticsearchGlobal.NumberOfReplicas,
-	)
-	createIndex, _ := helper.SentReturnCodeIsOK(connector.httpClient, connector.connectionHost+"_template/"+connector.index, "PUT", mapping)
-
+	mapping, err := GenTemplate(connector.bulk, TemplateSettings{
+		NumberOfShards:   config.GetConfig().ElasticsearchGlobal.NumberOfShards,
+		NumberOfReplicas: config.GetConfig().ElasticsearchGlobal.NumberOfReplicas,
+		LifecyclePolicy:  connector.lifecyclePolicy,
+	})
+	if err != nil {
+		connector.log.Warn(err)
+		return false
+	}
+	createIndex, body := helper.SentReturnCodeIsOK(connector.httpClient, connector.templateURL(), "PUT", mapping)
+	if !createIndex {
+		connector.log.Warnf("Elasticsearch(%s) could not create the template: %s", connector.target.Name, body)
+	}
 	return createIndex
 }
 
-// NagfluxTemplate creates a template for settings and mapping for nagflux indices.
-const NagfluxTemplate = `{
-  "template": "%s-*",
-  "settings": {
-    "index": {
-      "number_of_shards": "%d",
-      "number_of_replicas": "%d",
-      "refresh_interval": "60s"
-    }
-  },
-  "mappings": {
-    "messages": {
-      "properties": {
-        "service": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "author": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "host": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "type": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "message": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "timestamp": {
-          "format": "strict_date_optional_time||epoch_millis",
-          "type": "date"
-        }
-      }
-    },
-    "metrics": {
-      "properties": {
-        "max": {
-          "type": "float"
-        },
-        "performanceLabel": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "warn-max": {
-          "type": "float"
-        },
-        "warn-fill": {
-          "type": "string"
-        },
-        "command": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "warn": {
-          "type": "float"
-        },
-        "crit-max": {
-          "type": "float"
-        },
-        "crit-fill": {
-          "type": "string"
-        },
-        "min": {
-          "type": "float"
-        },
-        "crit": {
-          "type": "float"
-        },
-        "service": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "host": {
-          "index": "not_analyzed",
-          "type": "string"
-        },
-        "value": {
-          "type": "float"
-        },
-        "timestamp": {
-          "format": "strict_date_optional_time||epoch_millis",
-          "type": "date"
-        },
-        "warn-min": {
-          "type": "float"
-        },
-        "crit-min": {
-          "type": "float"
-        },
-        "downtime": {
-          "type": "boolean"
-        }
-      }
-    },
-    "_default_": {
-      "_source": {
-        "enabled": false
-      },
-      "dynamic_templates": [
-        {
-          "strings": {
-            "mapping": {
-              "index": "not_analyzed",
-              "type": "string"
-            },
-            "match_mapping_type": "string",
-            "match": "*"
-          }
-        }
-      ],
-      "_all": {
-        "enabled": false
-      }
-    }
-  },
-  "aliases": {}
-}`
+func (connector *Connector) templateURL() string {
+	return connector.connectionHost + templatePath(connector.bulk) + connector.bulk.Index
+}
+
+// addISMPolicy adds the policy to the existing indices. New indices get it by the ism_template of the policy.
+func (connector *Connector) addISMPolicy() {
+	pattern := indexPattern(connector.bulk)
+	if connector.bulk.DataStream {
+		pattern = ".ds-" + pattern + "-*"
+	}
+	body := fmt.Sprintf(`{"policy_id":"%s"}`, helper.SanitizeElasicInput(connector.lifecyclePolicy))
+	if ok, result := helper.SentReturnCodeIsOK(connector.httpClient, connector.connectionHost+"_plugins/_ism/add/"+pattern, "POST", body); !ok {
+		connector.log.Warnf("Elasticsearch(%s) could not add the ISM policy %s: %s", connector.target.Name, connector.lifecyclePolicy, result)
+	}
+}
+
+// Authorization returns the value of the Authorization header, the API key is the encoded one
+// and takes precedence over the bearer token and the basic auth. It's empty without credentials.
+func Authorization(username, password, apiKey, bearerToken string) string {
+	switch {
+	case apiKey != "":
+		return "ApiKey " + apiKey
+	case bearerToken != "":
+		return "Bearer " + bearerToken
+	case username != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+	return ""
+}
+
+// authTransport adds the Authorization header to every request.
+type authTransport struct {
+	base          http.RoundTripper
+	authorization string
+}
+
+func (t authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", t.authorization)
+	return t.base.RoundTrip(req)
+}
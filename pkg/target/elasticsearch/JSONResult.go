@@ -1,29 +1,56 @@
 package elasticsearch
 
+import (
+	"net/http"
+	"strings"
+)
+
 // JSONResult is the JSON object returned from an bulk request
 type JSONResult struct {
 	Errors bool `json:"errors"`
-	Items  []struct {
-		Create struct {
-			ID    string `json:"_id"`
-			Index string `json:"_index"`
-			Type  string `json:"_type"`
-			Error struct {
-				CausedBy struct {
-					Reason string `json:"reason"`
-					Type   string `json:"type"`
-				} `json:"caused_by"`
-				Reason string `json:"reason"`
-				Type   string `json:"type"`
-			} `json:"error"`
-			Shards struct {
-				Failed     int `json:"failed"`
-				Successful int `json:"successful"`
-				Total      int `json:"total"`
-			} `json:"_shards"`
-			Version int `json:"_version"`
-			Status  int `json:"status"`
-		} `json:"create"`
-	} `json:"items"`
-	Took int `json:"took"`
+	// every item has its action as key, index or create, in the order of the documents
+	Items []map[string]JSONResultItem `json:"items"`
+	Took  int                         `json:"took"`
+}
+
+// JSONResultItem is the result of a single document of a bulk request.
+type JSONResultItem struct {
+	ID     string           `json:"_id"`
+	Index  string           `json:"_index"`
+	Status int              `json:"status"`
+	Error  *JSONResultError `json:"error,omitempty"`
+}
+
+// JSONResultError is the reason why a document was rejected.
+type JSONResultError struct {
+	Type     string           `json:"type"`
+	Reason   string           `json:"reason"`
+	CausedBy *JSONResultError `json:"caused_by,omitempty"`
+}
+
+func (e *JSONResultError) String() string {
+	if e.CausedBy != nil {
+		return e.Type + ": " + e.Reason + " (" + e.CausedBy.String() + ")"
+	}
+	return e.Type + ": " + e.Reason
+}
+
+// isRetryable is true if the item or the request was rejected because the cluster is overloaded.
+func isRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// splitDocuments splits bulk data into the documents, each is an action line followed by its source line.
+func splitDocuments(bulk string) []string {
+	var lines []string
+	for line := range strings.SplitSeq(bulk, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	documents := make([]string, 0, len(lines)/2)
+	for i := 0; i+1 < len(lines); i += 2 {
+		documents = append(documents, lines[i]+"\n"+lines[i+1]+"\n")
+	}
+	return documents
 }
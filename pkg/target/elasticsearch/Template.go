@@ -0,0 +1,228 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+)
+
+// Distributions of the clusters.
+const (
+	DistributionElasticsearch = "elasticsearch"
+	DistributionOpenSearch    = "opensearch"
+)
+
+// TemplateSettings are the parts of the template which are not given by the bulk encoder.
+type TemplateSettings struct {
+	NumberOfShards   int
+	NumberOfReplicas int
+	// LifecyclePolicy is set as ILM policy, OpenSearch attaches ISM policies by itself
+	LifecyclePolicy string
+}
+
+// templatePath returns the API of the templates, _index_template or the legacy _template.
+func templatePath(bulk helper.ElasticsearchBulk) string {
+	if bulk.HasComposableTemplates() {
+		return "_index_template/"
+	}
+	return "_template/"
+}
+
+// indexPattern returns the pattern of the indices, a data stream is matched by its name.
+func indexPattern(bulk helper.ElasticsearchBulk) string {
+	if bulk.DataStream {
+		return bulk.Index
+	}
+	return bulk.Index + "-*"
+}
+
+// GenTemplate generates the template of the nagflux indices which fits the version of the cluster.
+func GenTemplate(bulk helper.ElasticsearchBulk, settings TemplateSettings) (string, error) {
+	if bulk.HasMappingTypes() {
+		return genLegacyTemplate(bulk.Index, settings), nil
+	}
+	indexSettings := map[string]any{
+		"number_of_shards":   settings.NumberOfShards,
+		"number_of_replicas": settings.NumberOfReplicas,
+		"refresh_interval":   "60s",
+	}
+	if settings.LifecyclePolicy != "" && !bulk.OpenSearch {
+		indexSettings["lifecycle"] = map[string]any{"name": settings.LifecyclePolicy}
+	}
+	body := map[string]any{
+		"index_patterns": []string{indexPattern(bulk)},
+	}
+	content := map[string]any{
+		"settings": map[string]any{"index": indexSettings},
+		"mappings": typelessMappings(bulk.TimestampField()),
+	}
+	if bulk.HasComposableTemplates() {
+		// above the priority 100 of the builtin templates for logs-*-* and metrics-*-*
+		body["priority"] = 200
+		body["template"] = content
+		if bulk.DataStream {
+			body["data_stream"] = map[string]any{}
+		}
+	} else {
+		body["settings"] = content["settings"]
+		body["mappings"] = content["mappings"]
+	}
+	out, err := json.MarshalIndent(body, "", "  ")
+	return string(out), err
+}
+
+// The mappings of the metrics and the messages which share one index since 7.0, strings are keywords.
+func typelessMappings(timestampField string) map[string]any {
+	keyword := map[string]any{"type": "keyword"}
+	float := map[string]any{"type": "float"}
+	properties := map[string]any{
+		timestampField:     map[string]any{"type": "date", "format": "strict_date_optional_time||epoch_millis"},
+		"host":             keyword,
+		"service":          keyword,
+		"command":          keyword,
+		"performanceLabel": keyword,
+		"unit":             keyword,
+		"author":           keyword,
+		"type":             keyword,
+		"message":          map[string]any{"type": "text"},
+		"downtime":         map[string]any{"type": "boolean"},
+	}
+	for _, field := range []string{"value", "warn", "warn-min", "warn-max", "crit", "crit-min", "crit-max", "min", "max"} {
+		properties[field] = float
+	}
+	return map[string]any{
+		"dynamic_templates": []any{
+			map[string]any{"strings": map[string]any{"match_mapping_type": "string", "mapping": keyword}},
+		},
+		"properties": properties,
+	}
+}
+
+// Generates the template of the clusters with mapping types.
+func genLegacyTemplate(index string, settings TemplateSettings) string {
+	return fmt.Sprintf(NagfluxTemplate, index, settings.NumberOfShards, settings.NumberOfReplicas)
+}
+
+// NagfluxTemplate creates a template for settings and mapping for nagflux indices.
+const NagfluxTemplate = `{
+  "template": "%s-*",
+  "settings": {
+    "index": {
+      "number_of_shards": "%d",
+      "number_of_replicas": "%d",
+      "refresh_interval": "60s"
+    }
+  },
+  "mappings": {
+    "messages": {
+      "properties": {
+        "service": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "author": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "host": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "type": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "message": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "timestamp": {
+          "format": "strict_date_optional_time||epoch_millis",
+          "type": "date"
+        }
+      }
+    },
+    "metrics": {
+      "properties": {
+        "max": {
+          "type": "float"
+        },
+        "performanceLabel": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "warn-max": {
+          "type": "float"
+        },
+        "warn-fill": {
+          "type": "string"
+        },
+        "command": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "warn": {
+          "type": "float"
+        },
+        "crit-max": {
+          "type": "float"
+        },
+        "crit-fill": {
+          "type": "string"
+        },
+        "min": {
+          "type": "float"
+        },
+        "crit": {
+          "type": "float"
+        },
+        "service": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "host": {
+          "index": "not_analyzed",
+          "type": "string"
+        },
+        "value": {
+          "type": "float"
+        },
+        "timestamp": {
+          "format": "strict_date_optional_time||epoch_millis",
+          "type": "date"
+        },
+        "warn-min": {
+          "type": "float"
+        },
+        "crit-min": {
+          "type": "float"
+        },
+        "downtime": {
+          "type": "boolean"
+        }
+      }
+    },
+    "_default_": {
+      "_source": {
+        "enabled": false
+      },
+      "dynamic_templates": [
+        {
+          "strings": {
+            "mapping": {
+              "index": "not_analyzed",
+              "type": "string"
+            },
+            "match_mapping_type": "string",
+            "match": "*"
+          }
+        }
+      ],
+      "_all": {
+        "enabled": false
+      }
+    }
+  },
+  "aliases": {}
+}`
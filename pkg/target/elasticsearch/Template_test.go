@@ -0,0 +1,66 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenTemplate(t *testing.T) {
+	settings := TemplateSettings{NumberOfShards: 1, NumberOfReplicas: 2, LifecyclePolicy: "nagflux"}
+
+	legacy, err := GenTemplate(helper.ElasticsearchBulk{Version: "6.8", Index: "nagflux"}, settings)
+	require.NoError(t, err)
+	assert.Contains(t, legacy, `"template": "nagflux-*"`)
+	assert.Equal(t, "_template/", templatePath(helper.ElasticsearchBulk{Version: "7.5"}))
+
+	out, err := GenTemplate(helper.ElasticsearchBulk{Version: "8.13", Index: "nagflux", DataStream: true}, settings)
+	require.NoError(t, err)
+	type indexTemplate struct {
+		IndexPatterns []string       `json:"index_patterns"`
+		DataStream    map[string]any `json:"data_stream"`
+		Template      struct {
+			Settings struct {
+				Index map[string]any `json:"index"`
+			} `json:"settings"`
+			Mappings struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"mappings"`
+		} `json:"template"`
+	}
+	var template indexTemplate
+	require.NoError(t, json.Unmarshal([]byte(out), &template))
+	assert.Equal(t, []string{"nagflux"}, template.IndexPatterns)
+	assert.NotNil(t, template.DataStream)
+	assert.Equal(t, map[string]any{"name": "nagflux"}, template.Template.Settings.Index["lifecycle"])
+	assert.Contains(t, template.Template.Mappings.Properties, "@timestamp")
+	assert.NotContains(t, template.Template.Mappings.Properties, "timestamp")
+
+	out, err = GenTemplate(helper.ElasticsearchBulk{Version: "2.11", OpenSearch: true, Index: "nagflux"}, settings)
+	require.NoError(t, err)
+	template = indexTemplate{}
+	require.NoError(t, json.Unmarshal([]byte(out), &template))
+	assert.Equal(t, []string{"nagflux-*"}, template.IndexPatterns)
+	assert.NotContains(t, template.Template.Settings.Index, "lifecycle", "ISM policies are not set by the template")
+}
+
+func TestAuthorization(t *testing.T) {
+	assert.Equal(t, "", Authorization("", "", "", ""))
+	assert.Equal(t, "Basic dXNlcjpwYXNz", Authorization("user", "pass", "", ""))
+	assert.Equal(t, "Bearer token", Authorization("", "", "", "token"))
+	assert.Equal(t, "ApiKey key", Authorization("user", "pass", "key", "token"))
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+	client := http.Client{Transport: authTransport{base: http.DefaultTransport, authorization: "ApiKey key"}}
+	assert.True(t, helper.RequestedReturnCodeIsOK(client, server.URL, "HEAD"))
+	assert.Equal(t, "ApiKey key", received)
+}
@@ -7,15 +7,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
 	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
 	"github.com/kdar/factorlog"
 )
 
@@ -24,37 +25,38 @@ type Worker struct {
 	workerID     int
 	quit         chan bool
 	quitInternal chan bool
+	flush        chan bool
 	jobs         chan collector.Printable
 	connection   string
 	dumpFile     string
 	log          *factorlog.FactorLog
-	version      string
+	bulk         helper.ElasticsearchBulk
 	connector    *Connector
 	httpClient   http.Client
 	IsRunning    bool
-	index        string
 	promServer   statistics.PrometheusServer
 }
 
 const dataTimeout = time.Duration(20) * time.Second
 
 var (
-	errorInterrupted = errors.New("got interrupted")
-	errorBadRequest  = errors.New("400 Bad Request")
-	errorHTTPClient  = errors.New("http client got an error")
+	errorInterrupted    = errors.New("got interrupted")
+	errorBadRequest     = errors.New("400 Bad Request")
+	errorHTTPClient     = errors.New("http client got an error")
+	errorRetryableItems = errors.New("documents were rejected because the cluster is overloaded")
 )
 
 // WorkerGenerator generates a new Worker and starts it.
-func WorkerGenerator(jobs chan collector.Printable, connection, index, dumpFile, version string, connector *Connector) func(workerId int) *Worker {
+func WorkerGenerator(jobs chan collector.Printable, connection, dumpFile string, bulk helper.ElasticsearchBulk, connector *Connector) func(workerId int) *Worker {
 	return func(workerId int) *Worker {
 		worker := &Worker{
 			workerId, make(chan bool),
-			make(chan bool, 1), jobs,
-			connection, dumpFile,
-			logging.GetLogger(), version,
+			make(chan bool, 1), make(chan bool, 1), jobs,
+			connection, nagflux.GenDumpfileName(dumpFile, connector.target),
+			logging.GetLogger(), bulk,
 			connector,
-			http.Client{},
-			true, index,
+			http.Client{Transport: connector.httpClient.Transport},
+			true,
 			statistics.GetPrometheusServer(),
 		}
 		go worker.run()
@@ -71,6 +73,14 @@ func (worker *Worker) Stop() {
 	worker.log.Debug("InfluxWorker stopped")
 }
 
+// Flush makes the worker send its buffered data, it does not wait for it.
+func (worker *Worker) Flush() {
+	select {
+	case worker.flush <- true:
+	default:
+	}
+}
+
 // Tries to send data all the time.
 func (worker *Worker) run() {
 	var queries []collector.Printable
@@ -91,6 +101,9 @@ func (worker *Worker) run() {
 						worker.sendBuffer(queries)
 						queries = queries[:0]
 					}
+				case <-worker.flush:
+					worker.sendBuffer(queries)
+					queries = queries[:0]
 				case <-time.After(dataTimeout):
 					worker.sendBuffer(queries)
 					queries = queries[:0]
@@ -142,43 +155,90 @@ func (worker *Worker) sendBuffer(queries []collector.Printable) {
 		dataToSend = append(dataToSend, []byte(lineQuery)...)
 	}
 
+	var documents []string
+	for _, lineQuery := range lineQueries {
+		documents = append(documents, splitDocuments(lineQuery)...)
+	}
+
 	startTime := time.Now()
-	sendErr := worker.sendData(dataToSend, true)
-	if sendErr != nil {
-		for range 3 {
-			switch sendErr {
-			case errorBadRequest:
-				// Maybe just a few queries are wrong, so send them one by one and find the bad one
-				var badQueries []string
-				for _, lineQuery := range lineQueries {
-					queryErr := worker.sendData([]byte(lineQuery), false)
-					if queryErr != nil {
-						badQueries = append(badQueries, lineQuery)
-					}
-				}
-				worker.dumpErrorQueries("\n\nOne of the values is not clean..\n", badQueries)
-				sendErr = nil
-			case nil:
-				// Single point of exit
-				break
-			default:
-				if err := worker.waitForQuitOrGoOn(); err != nil {
-					// No error handling, because it's time to terminate
-					worker.dumpRemainingQueries(lineQueries)
-				}
-				// Resend Data
-				sendErr = worker.sendData(dataToSend, true)
+	// only the documents which were rejected as retryable are sent again
+	pending := documents
+	answered := false
+	sendErr := worker.connector.retryPolicy.Do(worker.connector.breaker, worker.waitForQuitOrGoOn, func() error {
+		retryable, err := worker.sendDocuments(pending, true)
+		if err == errorBadRequest {
+			return retry.Permanent(err)
+		} else if err != nil {
+			return err
+		}
+		answered = true
+		pending = retryable
+		if len(pending) > 0 {
+			return errorRetryableItems
+		}
+		return nil
+	})
+	switch {
+	case sendErr == nil:
+	case errors.Is(sendErr, errorBadRequest):
+		// Maybe just a few queries are wrong, so send them one by one and find the bad one
+		var badQueries, retryable []string
+		for _, lineQuery := range lineQueries {
+			rejected, queryErr := worker.sendDocuments(splitDocuments(lineQuery), false)
+			if queryErr != nil {
+				badQueries = append(badQueries, lineQuery)
 			}
+			retryable = append(retryable, rejected...)
+		}
+		worker.dumpErrorQueries("\n\nOne of the values is not clean..\n", badQueries)
+		sendErr = worker.keepRetryable(queries, lineQueries, retryable, errorRetryableItems)
+	case answered:
+		// the cluster took the other documents, so only the rejected ones are kept
+		if errors.Is(sendErr, errorInterrupted) {
+			worker.dumpRemainingQueries(pending)
+			sendErr = nil
+		} else {
+			sendErr = worker.keepRetryable(queries, lineQueries, pending, sendErr)
 		}
-		if sendErr != nil {
-			// if there is still an error dump the queries and go on
-			worker.dumpErrorQueries("\n\n"+sendErr.Error()+"\n", lineQueries)
+	case errors.Is(sendErr, errorInterrupted):
+		// No error handling, because it's time to terminate
+		worker.dumpRemainingQueries(collector.NackAll(queries, lineQueries))
+	default:
+		// hand the queries back to the write-ahead log or dump them and go on
+		if dumpQueries := collector.NackAll(queries, lineQueries); len(dumpQueries) > 0 {
+			worker.dumpErrorQueries("\n\n"+sendErr.Error()+"\n", dumpQueries)
 		}
 	}
+	if sendErr == nil {
+		collector.AckAll(queries)
+	}
+	worker.promServer.SendLatency.WithLabelValues(worker.connector.target.String()).Observe(time.Since(startTime).Seconds())
 	worker.promServer.BytesSend.WithLabelValues("Elasticsearch").Add(float64(len(lineQueries)))
 	worker.promServer.SendDuration.WithLabelValues("Elasticsearch").Add(float64(time.Since(startTime).Seconds() * 1000))
 }
 
+// Keeps the documents which were rejected as retryable in the dumpfile, which is replayed on the next start.
+// The records of the write-ahead log are handed back if it can't be written, the error is returned then.
+func (worker *Worker) keepRetryable(
+	queries []collector.Printable, lineQueries, retryable []string, reason error,
+) error {
+	if len(retryable) == 0 {
+		return nil
+	}
+	worker.log.Warnf("Elasticsearch(%s) could not index %d documents, they are kept in %s",
+		worker.connector.target.Name, len(retryable), worker.dumpFile)
+	mutex.Lock()
+	defer mutex.Unlock()
+	err := worker.dumpQueries(worker.dumpFile, retryable)
+	if err == nil {
+		return nil
+	}
+	if dumpQueries := collector.NackAll(queries, lineQueries); len(dumpQueries) > 0 {
+		worker.dumpErrorQueries("\n\n"+reason.Error()+"\n", retryable)
+	}
+	return err
+}
+
 // Writes the bad queries to a dumpfile.
 func (worker *Worker) dumpErrorQueries(messageForLog string, errorQueries []string) {
 	errorFile := worker.dumpFile + "-errors"
@@ -212,6 +272,10 @@ func (worker *Worker) readQueriesFromQueue() []string {
 	for !stop {
 		select {
 		case query = <-worker.jobs:
+			// records of the write-ahead log stay on disk and will be replayed
+			if _, ok := query.(collector.Acknowledger); ok {
+				continue
+			}
 			cast := worker.castJobToString(query)
 			queries = append(queries, cast)
 		case <-time.After(time.Duration(200) * time.Millisecond):
@@ -221,50 +285,108 @@ func (worker *Worker) readQueriesFromQueue() []string {
 	return queries
 }
 
-// sends the raw data to influxdb and returns an err if given.
-func (worker *Worker) sendData(rawData []byte, log bool) error {
+// Sends the documents and returns the ones which were rejected as retryable.
+// The permanently rejected documents are written to the dead-letter file.
+func (worker *Worker) sendDocuments(documents []string, log bool) ([]string, error) {
+	rawData := []byte(strings.Join(documents, ""))
 	worker.log.Debug(string(rawData))
 	req, err := http.NewRequest(http.MethodPost, worker.connection, bytes.NewBuffer(rawData))
 	if err != nil {
 		worker.log.Warn(err)
+		return nil, err
 	}
 	req.Header.Set("User-Agent", "Nagflux")
+	req.Header.Set("Content-Type", "application/x-ndjson")
 	resp, err := worker.httpClient.Do(req)
 	if err != nil {
 		worker.log.Warn(err)
-		return errorHTTPClient
+		return nil, errorHTTPClient
 	}
 	defer resp.Body.Close()
 	jsonSrc, _ := io.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusBadRequest:
+		if log {
+			worker.log.Warnf("Elasticsearch(%s) rejected the bulk request: %s", worker.connector.target.Name, jsonSrc)
+		}
+		return nil, errorBadRequest
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, fmt.Errorf("bulk request failed: %s", resp.Status)
+	}
 	var result JSONResult
-	json.Unmarshal(jsonSrc, &result)
+	if err := json.Unmarshal(jsonSrc, &result); err != nil {
+		return nil, fmt.Errorf("could not parse the bulk response: %w", err)
+	}
 	if !result.Errors {
-		// OK
-		return nil
+		worker.countItems("ok", len(documents))
+		return nil, nil
+	}
+	if len(result.Items) != len(documents) {
+		return nil, fmt.Errorf("the bulk response has %d items for %d documents", len(result.Items), len(documents))
+	}
+	var retryable []string
+	var deadLetters []deadLetter
+	for i, actions := range result.Items {
+		for _, item := range actions {
+			switch {
+			case item.Error == nil:
+				worker.countItems("ok", 1)
+			case isRetryable(item.Status):
+				worker.countItems("retryable", 1)
+				retryable = append(retryable, documents[i])
+			default:
+				worker.countItems("permanent", 1)
+				deadLetters = append(deadLetters, newDeadLetter(documents[i], item))
+			}
+		}
 	}
-	// Bad Request
-	if log {
-		worker.printErrors(result, rawData)
-		worker.log.Warn(result.Items)
+	if len(deadLetters) > 0 {
+		worker.log.Warnf("Elasticsearch(%s) rejected %d documents, e.g. %s, they are written to %s",
+			worker.connector.target.Name, len(deadLetters), deadLetters[0].Error, worker.deadLetterFile())
+		worker.writeDeadLetters(deadLetters)
 	}
-	return nil // maybe return error?
+	return retryable, nil
+}
+
+func (worker *Worker) countItems(result string, amount int) {
+	worker.promServer.BulkItems.WithLabelValues(worker.connector.target.String(), result).Add(float64(amount))
+}
+
+// deadLetter is a permanently rejected document with the reason, it's written as a line of JSON.
+type deadLetter struct {
+	Time   int64            `json:"time"`
+	Status int              `json:"status"`
+	Error  *JSONResultError `json:"error"`
+	// the action and the source of the document as sent
+	Document string `json:"document"`
+}
+
+func newDeadLetter(document string, item JSONResultItem) deadLetter {
+	return deadLetter{Time: time.Now().UnixMilli(), Status: item.Status, Error: item.Error, Document: document}
+}
+
+func (worker *Worker) deadLetterFile() string {
+	return worker.dumpFile + "-deadletter"
 }
 
-func (worker *Worker) printErrors(result JSONResult, rawData []byte) {
-	errors := []map[int]string{{}}
-	for index, item := range result.Items {
-		if item.Create.Status != 201 {
-			errors = append(errors, map[int]string{index * 2: strings.ReplaceAll(item.Create.Error.CausedBy.Reason, "\n", "") + "\n"})
+// Appends the dead letters to the dead-letter file.
+func (worker *Worker) writeDeadLetters(deadLetters []deadLetter) {
+	var lines []string
+	for _, letter := range deadLetters {
+		line, err := json.Marshal(letter)
+		if err != nil {
+			worker.log.Critical(err)
+			continue
 		}
+		lines = append(lines, string(line)+"\n")
 	}
-
-	os.WriteFile(worker.dumpFile+".currupt.json", rawData, 0o644)
-	os.WriteFile(worker.dumpFile+".error.json", []byte(fmt.Sprintf("%v", errors)), 0o644)
-	panic("")
+	mutex.Lock()
+	worker.dumpQueries(worker.deadLetterFile(), lines)
+	mutex.Unlock()
 }
 
-// Waits on an internal quit signal.
-func (worker *Worker) waitForQuitOrGoOn() error {
+// Waits on an internal quit signal or the given backoff.
+func (worker *Worker) waitForQuitOrGoOn(backoff time.Duration) error {
 	select {
 	// Got stop signal
 	case <-worker.quitInternal:
@@ -272,38 +394,28 @@ func (worker *Worker) waitForQuitOrGoOn() error {
 		worker.quitInternal <- true
 		return errorInterrupted
 	// Timeout and retry
-	case <-time.After(time.Duration(10) * time.Second):
+	case <-time.After(backoff):
 		return nil
 	}
 }
 
-// Writes queries to a dumpfile.
-func (worker *Worker) dumpQueries(filename string, queries []string) {
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		if _, err := os.Create(filename); err != nil {
-			worker.log.Critical(err)
-		}
-	}
-	if f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0o600); err != nil {
+// Writes queries to a dumpfile and syncs it to disk, returns the first error.
+func (worker *Worker) dumpQueries(filename string, queries []string) error {
+	err := nagflux.AppendDumpfile(filename, queries)
+	if err != nil {
 		worker.log.Critical(err)
-	} else {
-		defer f.Close()
-		for _, query := range queries {
-			if _, err = f.WriteString(query); err != nil {
-				worker.log.Critical(err)
-			}
-		}
 	}
+	return err
 }
 
 // Converts an collector.Printable to a string. Can exit the program if Elasticsearch version is not supported
 func (worker *Worker) castJobToString(job collector.Printable) string {
 	var result string
 
-	if helper.VersionOrdinal(worker.version) >= helper.VersionOrdinal("2.0") {
-		result = job.PrintForElasticsearch(worker.version, worker.index)
+	if worker.bulk.IsSupported() {
+		result = job.PrintForElasticsearch(worker.bulk)
 	} else {
-		worker.log.Fatalf("This elasticsearch version [%s] given in the config is not supported", worker.version)
+		worker.log.Fatalf("This elasticsearch version [%s] given in the config is not supported", worker.bulk.Version)
 	}
 
 	if len(result) > 1 && result[len(result)-1:] != "\n" {
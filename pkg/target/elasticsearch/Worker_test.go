@@ -0,0 +1,164 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitTestLogger()
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+func document(id string) string {
+	return `{"index":{"_index":"nagflux"}}` + "\n" + `{"host":"` + id + `"}` + "\n"
+}
+
+// a Printable of a write-ahead log
+type testRecord struct {
+	collector.SimplePrintable
+	acked, nacked int
+}
+
+func (r *testRecord) Ack()  { r.acked++ }
+func (r *testRecord) Nack() { r.nacked++ }
+
+func TestSplitDocuments(t *testing.T) {
+	downtime := document("a") + "\n" + document("b")
+	assert.Equal(t, []string{document("a"), document("b")}, splitDocuments(downtime))
+	assert.Empty(t, splitDocuments(""))
+}
+
+func TestPartialBulkFailure(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, string(body))
+		first := len(requests) == 1
+		mutex.Unlock()
+		if first {
+			io.WriteString(w, `{"took":1,"errors":true,"items":[
+				{"index":{"_index":"nagflux","status":201}},
+				{"index":{"_index":"nagflux","status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue is full"}}},
+				{"index":{"_index":"nagflux","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [value]",
+					"caused_by":{"type":"number_format_exception","reason":"For input string: \"x\""}}}}]}`)
+			return
+		}
+		io.WriteString(w, `{"took":1,"errors":false,"items":[{"index":{"_index":"nagflux","status":201}}]}`)
+	}))
+	defer server.Close()
+
+	dumpFile := filepath.Join(t.TempDir(), "dump")
+	target := data.Target{Name: "es", Datatype: data.Elasticsearch}
+	connector := &Connector{target: target, retryPolicy: retry.Policy{MaxAttempts: 3}, log: logging.GetLogger()}
+	worker := &Worker{
+		quitInternal: make(chan bool, 1), connection: server.URL, dumpFile: dumpFile, bulk: helper.ElasticsearchBulk{Version: "8.13"},
+		log: logging.GetLogger(), connector: connector, promServer: statistics.GetPrometheusServer(),
+	}
+	queries := []collector.Printable{
+		&collector.SimplePrintable{Text: document("ok") + document("retry") + document("mapping"), Datatype: data.Elasticsearch},
+	}
+	worker.sendBuffer(queries)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, document("retry"), requests[1], "only the retryable document is sent again")
+
+	content, err := os.ReadFile(dumpFile + "-deadletter")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 1)
+	var letter deadLetter
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &letter))
+	assert.Equal(t, 400, letter.Status)
+	assert.Equal(t, document("mapping"), letter.Document)
+	assert.Equal(t, `mapper_parsing_exception: failed to parse field [value] (number_format_exception: For input string: "x")`, letter.Error.String())
+	_, err = os.Stat(dumpFile + "-errors")
+	assert.True(t, os.IsNotExist(err), "nothing is dumped")
+}
+
+func TestRetryableItemsExhausted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, `{"errors":true,"items":[{"create":{"status":503,"error":{"type":"unavailable_shards_exception","reason":"primary shard is not active"}}}]}`)
+	}))
+	defer server.Close()
+
+	dumpFile := filepath.Join(t.TempDir(), "dump")
+	connector := &Connector{target: data.Target{Name: "es", Datatype: data.Elasticsearch}, retryPolicy: retry.Policy{MaxAttempts: 2}, log: logging.GetLogger()}
+	worker := &Worker{
+		quitInternal: make(chan bool, 1), connection: server.URL, dumpFile: dumpFile, bulk: helper.ElasticsearchBulk{Version: "8.13"},
+		log: logging.GetLogger(), connector: connector, promServer: statistics.GetPrometheusServer(),
+	}
+	record := &testRecord{SimplePrintable: collector.SimplePrintable{Text: document("retry"), Datatype: data.Elasticsearch}}
+	worker.sendBuffer([]collector.Printable{record})
+
+	// the dumpfile is replayed on the next start
+	content, err := os.ReadFile(dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, document("retry"), string(content))
+	assert.Equal(t, 1, record.acked, "the record is kept in the dumpfile")
+	for _, suffix := range []string{"-errors", "-deadletter"} {
+		_, err = os.Stat(dumpFile + suffix)
+		assert.True(t, os.IsNotExist(err), "retryable documents are no errors or dead letters")
+	}
+
+	// the write-ahead log has to deliver the record again, if the dumpfile can't be written
+	worker.dumpFile = filepath.Join(t.TempDir(), "missing", "dump")
+	record = &testRecord{SimplePrintable: collector.SimplePrintable{Text: document("retry"), Datatype: data.Elasticsearch}}
+	worker.sendBuffer([]collector.Printable{record})
+	assert.Equal(t, 1, record.nacked)
+	assert.Zero(t, record.acked)
+}
+
+func TestBadRequestKeepsRetryableItems(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch string(body) {
+		case document("ok"):
+			io.WriteString(w, `{"errors":false,"items":[{"create":{"status":201}}]}`)
+		case document("retry"):
+			io.WriteString(w, `{"errors":true,"items":[{"create":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue is full"}}}]}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	dumpFile := filepath.Join(t.TempDir(), "dump")
+	connector := &Connector{target: data.Target{Name: "es", Datatype: data.Elasticsearch}, retryPolicy: retry.Policy{MaxAttempts: 2}, log: logging.GetLogger()}
+	worker := &Worker{
+		quitInternal: make(chan bool, 1), connection: server.URL, dumpFile: dumpFile, bulk: helper.ElasticsearchBulk{Version: "8.13"},
+		log: logging.GetLogger(), connector: connector, promServer: statistics.GetPrometheusServer(),
+	}
+	records := []collector.Printable{
+		&testRecord{SimplePrintable: collector.SimplePrintable{Text: document("ok"), Datatype: data.Elasticsearch}},
+		&testRecord{SimplePrintable: collector.SimplePrintable{Text: document("retry"), Datatype: data.Elasticsearch}},
+	}
+	worker.sendBuffer(records)
+
+	// the documents which are sent one by one after the bad request can be rejected as retryable as well
+	content, err := os.ReadFile(dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, document("retry"), string(content))
+	for _, record := range records {
+		assert.Equal(t, 1, record.(*testRecord).acked)
+	}
+}
@@ -0,0 +1,95 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+)
+
+// The Influx line protocol is written like for InfluxDB 1.x.
+const influxVersion = "1.0"
+
+// CSVHeader is the first line of every CSV file, every field of a metric is an own line.
+var CSVHeader = []string{
+	"timestamp", "type", "host", "service", "command", "performance_label", "unit", "tags",
+	"field", "value", "kind", "level", "author", "message", "end_timestamp",
+}
+
+// Encode returns the printable in the given format, every line ends with a newline.
+// The CSV lines do not contain the header. JSON events which can't be encoded are skipped and returned as error.
+func Encode(p collector.Printable, format string) ([]byte, error) {
+	switch format {
+	case FormatInflux:
+		lines := strings.TrimRight(p.PrintForInfluxDB(influxVersion), "\n")
+		if lines == "" {
+			return nil, nil
+		}
+		return []byte(lines + "\n"), nil
+	case FormatCSV:
+		return encodeCSV(p.PrintForJSON())
+	}
+	var buffer bytes.Buffer
+	var errs []error
+	for _, event := range p.PrintForJSON() {
+		out, err := json.Marshal(event)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		buffer.Write(out)
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes(), errors.Join(errs...)
+}
+
+// EncodeCSVHeader returns the CSVHeader as CSV line.
+func EncodeCSVHeader() []byte {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.Write(CSVHeader)
+	writer.Flush()
+	return buffer.Bytes()
+}
+
+func encodeCSV(events []collector.JSONEvent) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	for _, event := range events {
+		var tags []string
+		for _, key := range slices.Sorted(maps.Keys(event.Tags)) {
+			tags = append(tags, key+"="+event.Tags[key])
+		}
+		endTimestamp := ""
+		if event.EndTimestamp != 0 {
+			endTimestamp = strconv.FormatInt(event.EndTimestamp, 10)
+		}
+		record := func(field, value string) []string {
+			return []string{
+				strconv.FormatInt(event.Timestamp, 10), event.Type, event.Host, event.Service, event.Command,
+				event.PerformanceLabel, event.Unit, strings.Join(tags, ","),
+				field, value, event.Kind, event.Level, event.Author, event.Message, endTimestamp,
+			}
+		}
+		if len(event.Fields) == 0 {
+			if err := writer.Write(record("", "")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, field := range slices.Sorted(maps.Keys(event.Fields)) {
+			if err := writer.Write(record(field, fmt.Sprint(event.Fields[field]))); err != nil {
+				return nil, err
+			}
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
@@ -0,0 +1,91 @@
+package archive
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+)
+
+// The formats of the archived files.
+const (
+	FormatJSON   = "json"
+	FormatInflux = "influx"
+	FormatCSV    = "csv"
+)
+
+// The compressions of the archived files.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// A file is rotated after this time, if neither a time nor a size is configured.
+const defaultRotateInterval = time.Hour
+
+// Settings configure a Worker.
+type Settings struct {
+	Path        string
+	Format      string
+	Compression string
+	// 0 disables the rotation by time
+	RotateInterval time.Duration
+	// in bytes of the uncompressed data, 0 disables the rotation by size
+	RotateSize int64
+	// 0 keeps every file
+	MaxFiles int
+	// 0 keeps every file
+	MaxAge time.Duration
+}
+
+// SettingsFromConfig returns the settings of a FileExport section.
+func SettingsFromConfig(name string, cfg config.Config) (Settings, error) {
+	section := cfg.FileExport[name]
+	if section == nil {
+		return Settings{}, fmt.Errorf("FileExport %q is not configured", name)
+	}
+	settings := Settings{
+		Path: section.Path, Format: section.Format, Compression: section.Compression,
+		RotateInterval: time.Duration(section.RotateInterval) * time.Second,
+		RotateSize:     int64(section.RotateSize) << 20,
+		MaxFiles:       section.MaxFiles,
+		MaxAge:         time.Duration(section.MaxAge) * time.Second,
+	}
+	if settings.Path == "" {
+		return settings, fmt.Errorf("the path must not be empty")
+	}
+	switch settings.Format {
+	case "":
+		settings.Format = FormatJSON
+	case FormatJSON, FormatInflux, FormatCSV:
+	default:
+		return settings, fmt.Errorf("the format %q is not supported, use %s, %s or %s", settings.Format, FormatJSON, FormatInflux, FormatCSV)
+	}
+	switch settings.Compression {
+	case "":
+		settings.Compression = CompressionNone
+	case CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return settings, fmt.Errorf("the compression %q is not supported, use %s, %s or %s", settings.Compression, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+	if settings.RotateInterval < 0 || settings.RotateSize < 0 || settings.MaxFiles < 0 || settings.MaxAge < 0 {
+		return settings, fmt.Errorf("RotateInterval, RotateSize, MaxFiles and MaxAge must not be negative")
+	}
+	if settings.RotateInterval == 0 && settings.RotateSize == 0 {
+		settings.RotateInterval = defaultRotateInterval
+	}
+	return settings, nil
+}
+
+// Returns the extension of the files, like .csv.gz.
+func (s Settings) extension() string {
+	extension := map[string]string{FormatJSON: ".ndjson", FormatInflux: ".lp", FormatCSV: ".csv"}[s.Format]
+	switch s.Compression {
+	case CompressionGzip:
+		extension += ".gz"
+	case CompressionZstd:
+		extension += ".zst"
+	}
+	return extension
+}
@@ -0,0 +1,267 @@
+package archive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/kdar/factorlog"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// the files are named perfdata-<time>.<extension>
+	filePrefix = "perfdata-"
+	// the file is written with this suffix and renamed after it's closed
+	tempSuffix = ".tmp"
+	timeFormat = "20060102T150405.000Z"
+	// how often the age of the current file is checked
+	rotationCheckInterval = time.Second
+)
+
+// Worker writes the data into files which are rotated by time and size and compressed.
+// A file is written under a temporary name and renamed as soon as it's complete.
+type Worker struct {
+	quit       chan bool
+	jobs       chan collector.Printable
+	target     data.Target
+	settings   Settings
+	log        *factorlog.FactorLog
+	IsRunning  bool
+	promServer statistics.PrometheusServer
+
+	// the current file, nil if none is open
+	file       *os.File
+	buffer     *bufio.Writer
+	compressor io.WriteCloser
+	out        io.Writer
+	opened     time.Time
+	written    int64
+}
+
+// NewWorker creates a worker for the settings and starts it.
+func NewWorker(jobs chan collector.Printable, target data.Target, settings Settings) *Worker {
+	w := &Worker{
+		quit:       make(chan bool),
+		jobs:       jobs,
+		target:     target,
+		settings:   settings,
+		log:        logging.GetLogger(),
+		IsRunning:  true,
+		promServer: statistics.GetPrometheusServer(),
+	}
+	if err := os.MkdirAll(settings.Path, 0o755); err != nil {
+		w.log.Criticalf("File(%s) could not create the folder: %s", target.Name, err)
+		return nil
+	}
+	w.finalizeStaleFiles()
+	w.applyRetention(time.Now())
+	go w.run()
+	return w
+}
+
+// Stop stops the worker, the current file is closed.
+func (w *Worker) Stop() {
+	if w.IsRunning {
+		w.quit <- true
+		<-w.quit
+		w.IsRunning = false
+		w.log.Debug("FileWorker(" + w.target.Name + ") stopped")
+	}
+}
+
+func (w *Worker) run() {
+	ticker := time.NewTicker(rotationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.quit:
+			w.closeFile()
+			w.quit <- true
+			return
+		case query := <-w.jobs:
+			if query.TestTargetFilter(w.target.Name) {
+				w.write(query)
+			}
+		case now := <-ticker.C:
+			if w.file != nil && w.settings.RotateInterval > 0 && now.Sub(w.opened) >= w.settings.RotateInterval {
+				w.closeFile()
+			}
+		}
+	}
+}
+
+// Writes the printable into the current file, the file is rotated if it reached its size.
+func (w *Worker) write(p collector.Printable) {
+	out, err := Encode(p, w.settings.Format)
+	if err != nil {
+		w.log.Warnf("File(%s) could not encode the data: %s", w.target.Name, err)
+	}
+	if len(out) == 0 {
+		return
+	}
+	if w.file == nil {
+		if err := w.openFile(time.Now()); err != nil {
+			w.log.Criticalf("File(%s) dropping data, could not open a file: %s", w.target.Name, err)
+			return
+		}
+	}
+	if _, err := w.out.Write(out); err != nil {
+		// the writers keep failing after an error, the next data goes to a new file
+		w.log.Criticalf("File(%s) dropping data, could not write to %s: %s", w.target.Name, w.file.Name(), err)
+		w.closeFile()
+		return
+	}
+	w.written += int64(len(out))
+	w.promServer.BytesSend.WithLabelValues("File").Add(float64(len(out)))
+	if w.settings.RotateSize > 0 && w.written >= w.settings.RotateSize {
+		w.closeFile()
+	}
+}
+
+func (w *Worker) openFile(now time.Time) error {
+	path := w.freePath(filePrefix + now.UTC().Format(timeFormat))
+	file, err := os.OpenFile(path+tempSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.buffer = bufio.NewWriter(file)
+	w.out = w.buffer
+	w.compressor = nil
+	switch w.settings.Compression {
+	case CompressionGzip:
+		w.compressor = gzip.NewWriter(w.buffer)
+	case CompressionZstd:
+		if w.compressor, err = zstd.NewWriter(w.buffer); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			w.file = nil
+			return err
+		}
+	}
+	if w.compressor != nil {
+		w.out = w.compressor
+	}
+	w.opened = now
+	w.written = 0
+	if w.settings.Format == FormatCSV {
+		if _, err := w.out.Write(EncodeCSVHeader()); err != nil {
+			w.closeFile()
+			return err
+		}
+	}
+	w.log.Debugf("File(%s) opened %s", w.target.Name, file.Name())
+	return nil
+}
+
+// Closes the current file and renames it to its final name, afterwards the retention is applied.
+func (w *Worker) closeFile() {
+	if w.file == nil {
+		return
+	}
+	tempPath := w.file.Name()
+	var errs []error
+	if w.compressor != nil {
+		errs = append(errs, w.compressor.Close())
+	}
+	errs = append(errs, w.buffer.Flush(), w.file.Sync(), w.file.Close())
+	w.file = nil
+	for _, err := range errs {
+		if err != nil {
+			w.log.Criticalf("File(%s) could not close %s, it's kept under this name: %s", w.target.Name, tempPath, err)
+			return
+		}
+	}
+	if err := os.Rename(tempPath, strings.TrimSuffix(tempPath, tempSuffix)); err != nil {
+		w.log.Criticalf("File(%s) could not rename %s: %s", w.target.Name, tempPath, err)
+		return
+	}
+	w.applyRetention(time.Now())
+}
+
+// Returns the path of the file with the name, which is neither used by a complete nor a temporary file.
+func (w *Worker) freePath(name string) string {
+	path := filepath.Join(w.settings.Path, name+w.settings.extension())
+	// two files can be opened within a millisecond if the size limit is small
+	for i := 1; fileExists(path) || fileExists(path+tempSuffix); i++ {
+		path = filepath.Join(w.settings.Path, fmt.Sprintf("%s_%d%s", name, i, w.settings.extension()))
+	}
+	return path
+}
+
+// Renames the temporary files which were left by a crash or a failed close, so their data is kept and the retention
+// applies to them. The last record of such a file may be incomplete.
+func (w *Worker) finalizeStaleFiles() {
+	entries, err := os.ReadDir(w.settings.Path)
+	if err != nil {
+		w.log.Warnf("File(%s) could not look for temporary files: %s", w.target.Name, err)
+		return
+	}
+	suffix := w.settings.extension() + tempSuffix
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), filePrefix) || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+		tempPath := filepath.Join(w.settings.Path, entry.Name())
+		path := strings.TrimSuffix(tempPath, tempSuffix)
+		if fileExists(path) {
+			path = w.freePath(strings.TrimSuffix(entry.Name(), suffix))
+		}
+		if err := os.Rename(tempPath, path); err != nil {
+			w.log.Warnf("File(%s) could not rename the temporary file %s: %s", w.target.Name, entry.Name(), err)
+			continue
+		}
+		w.log.Warnf("File(%s) renamed the temporary file %s of an unclean shutdown, its end may be incomplete", w.target.Name, entry.Name())
+	}
+}
+
+// Removes the oldest files above MaxFiles and the files older than MaxAge.
+// Only the complete files of this worker are touched, not the temporary ones.
+func (w *Worker) applyRetention(now time.Time) {
+	if w.settings.MaxFiles == 0 && w.settings.MaxAge == 0 {
+		return
+	}
+	entries, err := os.ReadDir(w.settings.Path)
+	if err != nil {
+		w.log.Warnf("File(%s) could not apply the retention: %s", w.target.Name, err)
+		return
+	}
+	var files []os.DirEntry
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasPrefix(entry.Name(), filePrefix) && strings.HasSuffix(entry.Name(), w.settings.extension()) {
+			files = append(files, entry)
+		}
+	}
+	// the names start with the time, so they are sorted from old to new
+	slices.SortFunc(files, func(a, b os.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	for i, entry := range files {
+		remove := w.settings.MaxFiles > 0 && len(files)-i > w.settings.MaxFiles
+		if !remove && w.settings.MaxAge > 0 {
+			info, err := entry.Info()
+			remove = err == nil && now.Sub(info.ModTime()) > w.settings.MaxAge
+		}
+		if remove {
+			if err := os.Remove(filepath.Join(w.settings.Path, entry.Name())); err != nil {
+				w.log.Warnf("File(%s) could not remove %s: %s", w.target.Name, entry.Name(), err)
+			} else {
+				w.log.Debugf("File(%s) removed %s", w.target.Name, entry.Name())
+			}
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,257 @@
+package archive
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitTestLogger()
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+func testPerfdata() *spoolfile.PerformanceData {
+	return &spoolfile.PerformanceData{
+		Filterable: collector.AllFilterable,
+		Hostname:   "host", Service: "service", Command: "check", PerformanceLabel: "rta", Unit: "ms", Time: "1458988932000",
+		Tags: map[string]string{"b": "2", "a": "1"}, Fields: map[string]string{"value": "1.5", "warn": "10"},
+	}
+}
+
+func TestSettingsFromConfig(t *testing.T) {
+	cfg := config.Config{}
+	cfg.FileExport = map[string]*struct {
+		Enabled        bool
+		Path           string
+		Format         string
+		Compression    string
+		RotateInterval int
+		RotateSize     int
+		MaxFiles       int
+		MaxAge         int
+	}{
+		"default":  {Path: "/tmp"},
+		"size":     {Path: "/tmp", Format: FormatCSV, Compression: CompressionZstd, RotateSize: 2, MaxFiles: 3},
+		"format":   {Path: "/tmp", Format: "xml"},
+		"negative": {Path: "/tmp", MaxAge: -1},
+	}
+	settings, err := SettingsFromConfig("default", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, Settings{Path: "/tmp", Format: FormatJSON, Compression: CompressionNone, RotateInterval: time.Hour}, settings)
+	assert.Equal(t, ".ndjson", settings.extension())
+
+	settings, err = SettingsFromConfig("size", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, Settings{Path: "/tmp", Format: FormatCSV, Compression: CompressionZstd, RotateSize: 2 << 20, MaxFiles: 3}, settings)
+	assert.Equal(t, ".csv.zst", settings.extension())
+
+	_, err = SettingsFromConfig("format", cfg)
+	assert.EqualError(t, err, `the format "xml" is not supported, use json, influx or csv`)
+	_, err = SettingsFromConfig("negative", cfg)
+	assert.Error(t, err)
+	_, err = SettingsFromConfig("missing", cfg)
+	assert.Error(t, err)
+}
+
+func TestEncode(t *testing.T) {
+	perf := testPerfdata()
+	out, err := Encode(perf, FormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, `{"schema_version":1,"type":"metric","timestamp":1458988932000,"host":"host","service":"service","command":"check","performance_label":"rta","unit":"ms","tags":{"a":"1","b":"2"},"fields":{"value":1.5,"warn":10}}`+"\n", string(out))
+
+	out, err = Encode(perf, FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, `1458988932000,metric,host,service,check,rta,ms,"a=1,b=2",value,1.5,,,,,
+1458988932000,metric,host,service,check,rta,ms,"a=1,b=2",warn,10,,,,,
+`, string(out))
+
+	downtime := livestatus.NewDowntimeData("host", "", "update", "100", "admin", "200")
+	out, err = Encode(downtime, FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, "100000,downtime,host,,,,,,,,,,admin,update,200000\n", string(out))
+
+	out, err = Encode(perf, FormatInflux)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "metrics,host=host,service=service,command=check,performanceLabel=rta,"), string(out))
+	assert.True(t, strings.HasSuffix(string(out), " 1458988932000\n"), string(out))
+
+	assert.Equal(t, "timestamp,type,host,service,command,performance_label,unit,tags,field,value,kind,level,author,message,end_timestamp\n", string(EncodeCSVHeader()))
+
+	// non-finite values are kept as strings
+	perf.Fields = map[string]string{"value": "NaN", "warn": "+Inf"}
+	out, err = Encode(perf, FormatJSON)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"fields":{"value":"NaN","warn":"+Inf"}`)
+	out, err = Encode(perf, FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, `1458988932000,metric,host,service,check,rta,ms,"a=1,b=2",value,NaN,,,,,
+1458988932000,metric,host,service,check,rta,ms,"a=1,b=2",warn,+Inf,,,,,
+`, string(out))
+}
+
+func readArchive(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var reader io.Reader = file
+	switch filepath.Ext(path) {
+	case ".gz":
+		gzipReader, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		reader = gzipReader
+	case ".zst":
+		zstdReader, err := zstd.NewReader(file)
+		require.NoError(t, err)
+		defer zstdReader.Close()
+		reader = zstdReader
+	}
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestWorkerRotation(t *testing.T) {
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			folder := t.TempDir()
+			jobs := make(chan collector.Printable)
+			settings := Settings{Path: folder, Format: FormatCSV, Compression: compression, RotateSize: 1}
+			w := NewWorker(jobs, data.Target{Name: "archive", Datatype: data.File}, settings)
+			require.NotNil(t, w)
+
+			// every line exceeds the size, so every perfdata gets its own file
+			for range 3 {
+				jobs <- testPerfdata()
+			}
+			w.Stop()
+
+			files, err := filepath.Glob(filepath.Join(folder, "*"))
+			require.NoError(t, err)
+			require.Len(t, files, 3, "no temporary file is left")
+			for _, file := range files {
+				assert.True(t, strings.HasSuffix(file, settings.extension()), file)
+				content := readArchive(t, file)
+				assert.True(t, strings.HasPrefix(content, string(EncodeCSVHeader())), content)
+				assert.Equal(t, 3, strings.Count(content, "\n"), content)
+			}
+		})
+	}
+}
+
+func TestWorkerRotationByTime(t *testing.T) {
+	folder := t.TempDir()
+	jobs := make(chan collector.Printable)
+	w := NewWorker(jobs, data.Target{Name: "archive", Datatype: data.File}, Settings{Path: folder, Format: FormatJSON, RotateInterval: time.Millisecond})
+	require.NotNil(t, w)
+	jobs <- testPerfdata()
+	assert.Eventually(t, func() bool {
+		files, _ := filepath.Glob(filepath.Join(folder, "*.ndjson"))
+		return len(files) == 1
+	}, 5*time.Second, 10*time.Millisecond, "the file is renamed after the interval")
+	w.Stop()
+}
+
+func TestStaleFilesAreFinalized(t *testing.T) {
+	folder := t.TempDir()
+	names := []string{
+		"perfdata-20260101T000000.000Z.ndjson.tmp",
+		// the complete file must not be overwritten
+		"perfdata-20260102T000000.000Z.ndjson", "perfdata-20260102T000000.000Z.ndjson.tmp",
+		// files of other formats are left alone
+		"perfdata-20260103T000000.000Z.csv.tmp",
+	}
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(folder, name), []byte(name), 0o600))
+	}
+	w := NewWorker(make(chan collector.Printable), data.Target{Name: "archive", Datatype: data.File}, Settings{Path: folder, Format: FormatJSON})
+	require.NotNil(t, w)
+	w.Stop()
+
+	var left []string
+	entries, err := os.ReadDir(folder)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	assert.Equal(t, []string{
+		"perfdata-20260101T000000.000Z.ndjson", "perfdata-20260102T000000.000Z.ndjson",
+		"perfdata-20260102T000000.000Z_1.ndjson", "perfdata-20260103T000000.000Z.csv.tmp",
+	}, left)
+	assert.Equal(t, names[2], readArchive(t, filepath.Join(folder, "perfdata-20260102T000000.000Z_1.ndjson")))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrShortWrite }
+
+func TestWriteErrorRotatesFile(t *testing.T) {
+	folder := t.TempDir()
+	w := &Worker{
+		target: data.Target{Name: "archive", Datatype: data.File}, settings: Settings{Path: folder, Format: FormatJSON},
+		log: logging.GetLogger(), promServer: statistics.GetPrometheusServer(),
+	}
+	require.NoError(t, w.openFile(time.Now()))
+	broken := w.file.Name()
+	w.out = failingWriter{}
+	w.write(testPerfdata())
+	assert.Nil(t, w.file, "the broken file has to be closed")
+
+	w.write(testPerfdata())
+	require.NotNil(t, w.file)
+	assert.NotEqual(t, broken, w.file.Name())
+	w.closeFile()
+}
+
+func TestRetention(t *testing.T) {
+	folder := t.TempDir()
+	names := []string{
+		"perfdata-20260101T000000.000Z.ndjson", "perfdata-20260102T000000.000Z.ndjson",
+		"perfdata-20260103T000000.000Z.ndjson", "perfdata-20260104T000000.000Z.ndjson",
+		"perfdata-20260105T000000.000Z.ndjson.tmp", "other.ndjson", "perfdata-20260101T000000.000Z.csv",
+	}
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(folder, name), nil, 0o600))
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(folder, names[3]), old, old))
+
+	w := &Worker{settings: Settings{Path: folder, Format: FormatJSON, MaxFiles: 3, MaxAge: 24 * time.Hour}, log: logging.GetLogger()}
+	w.applyRetention(time.Now())
+
+	var left []string
+	entries, err := os.ReadDir(folder)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	// the oldest is above MaxFiles, the newest is older than MaxAge
+	assert.Equal(t, []string{
+		"other.ndjson", "perfdata-20260101T000000.000Z.csv",
+		"perfdata-20260102T000000.000Z.ndjson", "perfdata-20260103T000000.000Z.ndjson",
+		"perfdata-20260105T000000.000Z.ndjson.tmp",
+	}, left)
+}
+
+func TestEncodeInfluxSkipsEmpty(t *testing.T) {
+	out, err := Encode(&collector.SimplePrintable{Text: "x", Datatype: data.Elasticsearch}, FormatInflux)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
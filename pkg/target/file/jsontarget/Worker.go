@@ -1,8 +1,11 @@
 package jsontarget
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"time"
@@ -12,9 +15,21 @@ import (
 	"github.com/kdar/factorlog"
 )
 
+// The formats of the exported files.
+const (
+	// FormatNDJSON writes one event per line.
+	FormatNDJSON = "ndjson"
+	// FormatArray writes the events as a single JSON array.
+	FormatArray = "array"
+)
+
+// The end of an array file, new events are inserted before it.
+const arrayEnd = "\n]\n"
+
 type FileWorker struct {
 	rotationDuration time.Duration
 	rotation         bool
+	format           string
 	jobs             chan collector.Printable
 	target           data.Target
 	path             string
@@ -23,8 +38,22 @@ type FileWorker struct {
 	quit             chan bool
 }
 
+// FormatOrDefault returns the format, an empty format is array with rotation and ndjson without.
+func FormatOrDefault(format string, rotation int) (string, error) {
+	switch format {
+	case "":
+		if rotation > 0 {
+			return FormatArray, nil
+		}
+		return FormatNDJSON, nil
+	case FormatNDJSON, FormatArray:
+		return format, nil
+	}
+	return "", fmt.Errorf("the format %q is not supported, use %s or %s", format, FormatNDJSON, FormatArray)
+}
+
 // NewJSONFileWorker creates a new JSONFileWorker
-func NewJSONFileWorker(log *factorlog.FactorLog, rotation int, jobs chan collector.Printable, target data.Target, path string) *FileWorker {
+func NewJSONFileWorker(log *factorlog.FactorLog, rotation int, format string, jobs chan collector.Printable, target data.Target, path string) *FileWorker {
 	w := &FileWorker{
 		jobs:      jobs,
 		target:    target,
@@ -33,12 +62,9 @@ func NewJSONFileWorker(log *factorlog.FactorLog, rotation int, jobs chan collect
 		IsRunning: true,
 		quit:      make(chan bool, 2),
 	}
-	if _, err := os.Stat(path); err != nil {
-		err = os.Mkdir(path, os.ModeDir)
-		if err != nil {
-			log.Panic("Creating JSON Folder err:", err)
-			return nil
-		}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		log.Criticalf("JSONFile(%s) could not create the folder: %s", target, err)
+		return nil
 	}
 	if rotation < 0 {
 		log.Criticalf("JSONFile(%s) rotation mussn't below zero %d", target, rotation)
@@ -50,6 +76,11 @@ func NewJSONFileWorker(log *factorlog.FactorLog, rotation int, jobs chan collect
 		w.rotationDuration = time.Duration(rotation) * time.Second
 		w.rotation = true
 	}
+	var err error
+	if w.format, err = FormatOrDefault(format, rotation); err != nil {
+		log.Criticalf("JSONFile(%s): %s", target, err)
+		return nil
+	}
 	go w.run()
 	return w
 }
@@ -65,82 +96,113 @@ func (t *FileWorker) Stop() {
 }
 
 func (t *FileWorker) run() {
-	var queries []collector.Printable
-	var query collector.Printable
-	go func() {
-		for t.IsRunning {
-			<-time.After(t.rotationDuration)
-			t.writeData(queries)
-			queries = queries[:0]
-		}
-	}()
+	var events []collector.JSONEvent
+	ticker := time.NewTicker(t.rotationDuration)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-t.quit:
+			t.writeData(events)
 			t.IsRunning = false
 			t.quit <- true
 			return
-		case query = <-t.jobs:
+		case query := <-t.jobs:
 			if query.TestTargetFilter(t.target.Name) {
-				queries = append(queries, query)
+				events = append(events, query.PrintForJSON()...)
 			}
-		case <-time.After(time.Duration(20) * time.Second):
+		case <-ticker.C:
+			t.writeData(events)
+			events = events[:0]
 		}
 	}
 }
 
-func (t *FileWorker) writeData(data []collector.Printable) {
-	if len(data) == 0 {
+func (t *FileWorker) writeData(events []collector.JSONEvent) {
+	if len(events) == 0 {
+		return
+	}
+	out, err := Encode(events, t.format)
+	if err != nil {
+		t.log.Warn("Skipping events: ", err)
+	}
+	if len(out) == 0 {
 		return
 	}
 	filePath := t.getFilename()
 	if t.rotation {
-		if _, err := os.Stat(filePath); err == nil {
-			t.log.Debugf("JSON file(%s) already exists, waiting for an second", filePath)
-			time.Sleep(time.Duration(1) * time.Second)
-			t.writeData(data)
-		}
-		out, err := json.Marshal(data)
-		if err != nil {
-			t.log.Critical("JSON rotation marshal err:", err)
-			return
+		// the file of this second exists if the worker was restarted
+		base := filePath
+		for i := 1; ; i++ {
+			if _, err := os.Stat(filePath); err != nil {
+				break
+			}
+			filePath = fmt.Sprintf("%s_%d", base, i)
 		}
-		err = os.WriteFile(filePath, out, 0o644)
+	}
+	if err := appendToFile(filePath, out, t.format); err != nil {
+		t.log.Critical("JSON write err:", err)
+	}
+}
+
+// Encode returns the events in the given format, arrays end with a newline as well.
+// Events which can't be encoded are skipped and returned as error.
+func Encode(events []collector.JSONEvent, format string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var errs []error
+	written := 0
+	for _, event := range events {
+		out, err := json.Marshal(event)
 		if err != nil {
-			t.log.Critical("JSON rotation write err:", err)
+			errs = append(errs, err)
+			continue
 		}
-	} else {
-		dataToWrite := []byte("")
-		for _, d := range data {
-			out, err := json.Marshal(d)
-			if err != nil {
-				t.log.Critical("JSON no rotation marshal err:", err)
-				return
+		if format == FormatArray {
+			if written == 0 {
+				buffer.WriteString("[\n")
+			} else {
+				buffer.WriteString(",\n")
 			}
-			dataToWrite = append(dataToWrite, out...)
-			dataToWrite = append(dataToWrite, []byte("\n")...)
 		}
+		buffer.Write(out)
+		if format == FormatNDJSON {
+			buffer.WriteByte('\n')
+		}
+		written++
+	}
+	if format == FormatArray && written > 0 {
+		buffer.WriteString(arrayEnd)
+	}
+	return buffer.Bytes(), errors.Join(errs...)
+}
 
-		if _, err := os.Stat(filePath); err == nil {
-			if f, err2 := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0o600); err2 != nil {
-				t.log.Critical(err2)
-			} else {
-				_, err2 = f.Write(dataToWrite)
-				if err2 != nil {
-					t.log.Critical(err2)
-				}
-				err2 = f.Close()
-				if err2 != nil {
-					t.log.Critical(err2)
-				}
-			}
-		} else {
-			err = os.WriteFile(filePath, dataToWrite, 0o644)
-			if err != nil {
-				t.log.Critical("JSON no rotation write err:", err)
-			}
+// Appends the encoded events to the file, arrays are merged so that the file stays a single valid array.
+func appendToFile(filePath string, out []byte, format string) error {
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if format == FormatArray && size > 0 {
+		end := make([]byte, len(arrayEnd))
+		if size < int64(len(arrayEnd)) {
+			return fmt.Errorf("%s is not an array written by nagflux", filePath)
+		}
+		if _, err := f.ReadAt(end, size-int64(len(arrayEnd))); err != nil {
+			return err
+		}
+		if string(end) != arrayEnd {
+			return fmt.Errorf("%s is not an array written by nagflux", filePath)
 		}
+		// replace the end of the old array by the separator and the new events
+		out = append([]byte(",\n"), out[len("[\n"):]...)
+		size -= int64(len(arrayEnd))
 	}
+	_, err = f.WriteAt(out, size)
+	return err
 }
 
 func (t *FileWorker) getFilename() string {
@@ -0,0 +1,105 @@
+package jsontarget
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvents() []collector.JSONEvent {
+	perf := &spoolfile.PerformanceData{
+		Hostname: "host", Service: "service", Command: "check", PerformanceLabel: "rta", Unit: "ms", Time: "1458988932000",
+		Tags: map[string]string{}, Fields: map[string]string{"value": "1.5", "warn": "10"},
+	}
+	return append(perf.PrintForJSON(), collector.NewJSONEvent(collector.JSONTypeComment, 1458988933000))
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+	events := testEvents()
+	ndjson, err := Encode(events, FormatNDJSON)
+	require.NoError(t, err)
+	assert.Equal(t, `{"schema_version":1,"type":"metric","timestamp":1458988932000,"host":"host","service":"service","command":"check","performance_label":"rta","unit":"ms","fields":{"value":1.5,"warn":10}}
+{"schema_version":1,"type":"comment","timestamp":1458988933000}
+`, string(ndjson))
+
+	array, err := Encode(events, FormatArray)
+	require.NoError(t, err)
+	var decoded []collector.JSONEvent
+	require.NoError(t, json.Unmarshal(array, &decoded))
+	assert.Len(t, decoded, 2)
+}
+
+func TestEncodeNaN(t *testing.T) {
+	t.Parallel()
+	perf := &spoolfile.PerformanceData{
+		Hostname: "host", Time: "1458988932000", Tags: map[string]string{}, Fields: map[string]string{"value": "NaN"},
+	}
+	out, err := Encode(perf.PrintForJSON(), FormatNDJSON)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"fields":{"value":"NaN"}`)
+
+	// an event which can't be encoded is skipped, the others are kept
+	broken := collector.NewJSONEvent(collector.JSONTypeMetric, 1458988932000)
+	broken.Fields = map[string]any{"value": math.NaN()}
+	for _, format := range []string{FormatNDJSON, FormatArray} {
+		out, err = Encode(append([]collector.JSONEvent{broken}, testEvents()...), format)
+		require.Error(t, err)
+		expected, err := Encode(testEvents(), format)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(out), format)
+	}
+}
+
+func TestAppendToFile(t *testing.T) {
+	t.Parallel()
+	events := testEvents()
+	for _, format := range []string{FormatNDJSON, FormatArray} {
+		filePath := filepath.Join(t.TempDir(), "perfdata")
+		for range 3 {
+			out, err := Encode(events, format)
+			require.NoError(t, err)
+			require.NoError(t, appendToFile(filePath, out, format))
+		}
+		content, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		var decoded []collector.JSONEvent
+		if format == FormatArray {
+			require.NoError(t, json.Unmarshal(content, &decoded))
+		} else {
+			decoder := json.NewDecoder(bytes.NewReader(content))
+			for decoder.More() {
+				var event collector.JSONEvent
+				require.NoError(t, decoder.Decode(&event))
+				decoded = append(decoded, event)
+			}
+		}
+		assert.Len(t, decoded, 6, format)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "perfdata")
+	require.NoError(t, os.WriteFile(filePath, []byte("{}"), 0o600))
+	out, err := Encode(events, FormatArray)
+	require.NoError(t, err)
+	assert.Error(t, appendToFile(filePath, out, FormatArray), "a file which is no array must not be changed")
+}
+
+func TestFormatOrDefault(t *testing.T) {
+	t.Parallel()
+	format, err := FormatOrDefault("", 0)
+	require.NoError(t, err)
+	assert.Equal(t, FormatNDJSON, format)
+	format, err = FormatOrDefault("", 10)
+	require.NoError(t, err)
+	assert.Equal(t, FormatArray, format)
+	_, err = FormatOrDefault("csv", 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package graphite
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+)
+
+// DefaultPathTemplate is used if no template is configured.
+const DefaultPathTemplate = "{prefix}.{host}.{service}.{label}.{field}"
+
+// every char which is not allowed within a single path element
+var regexUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+// Metric is a single datapoint in Carbon's format.
+type Metric struct {
+	Path      string
+	Value     float64
+	Timestamp int64
+}
+
+// PathBuilder generates the metric paths out of the template.
+type PathBuilder struct {
+	template       string
+	prefix         string
+	hostcheckAlias string
+}
+
+// NewPathBuilder creates a PathBuilder, the template may contain the placeholders {prefix}, {host}, {service}, {command}, {label}, {unit} and {field}.
+// The prefix is used as it is and may contain dots, every other placeholder is sanitized.
+func NewPathBuilder(template, prefix, hostcheckAlias string) PathBuilder {
+	if template == "" {
+		template = DefaultPathTemplate
+	}
+	return PathBuilder{template: template, prefix: prefix, hostcheckAlias: hostcheckAlias}
+}
+
+// SanitizePathElement replaces dots, whitespace and every other char which would break the path by an underscore.
+func SanitizePathElement(input string) string {
+	input = strings.Trim(input, `'`)
+	return strings.Trim(regexUnsafeChars.ReplaceAllString(input, "_"), "_")
+}
+
+// Metrics creates one metric for each numeric field of the perfdata.
+func (b PathBuilder) Metrics(p *spoolfile.PerformanceData) []Metric {
+	timestamp, err := strconv.ParseInt(p.Time, 10, 64)
+	if err != nil {
+		return nil
+	}
+	service := p.Service
+	if service == "" {
+		service = b.hostcheckAlias
+	}
+	fields := make([]string, 0, len(p.Fields))
+	for field := range p.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	result := []Metric{}
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(p.Fields[field], 64)
+		if err != nil {
+			continue
+		}
+		replacer := strings.NewReplacer(
+			"{prefix}", b.prefix,
+			"{host}", SanitizePathElement(p.Hostname),
+			"{service}", SanitizePathElement(service),
+			"{command}", SanitizePathElement(p.Command),
+			"{label}", SanitizePathElement(p.PerformanceLabel),
+			"{unit}", SanitizePathElement(p.Unit),
+			"{field}", SanitizePathElement(field),
+		)
+		result = append(result, Metric{
+			Path:      joinPath(replacer.Replace(b.template)),
+			Value:     value,
+			Timestamp: timestamp / 1000,
+		})
+	}
+	return result
+}
+
+// Removes empty elements, which occur if a placeholder is empty.
+func joinPath(path string) string {
+	elements := []string{}
+	for element := range strings.SplitSeq(path, ".") {
+		if element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return strings.Join(elements, ".")
+}
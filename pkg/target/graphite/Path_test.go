@@ -0,0 +1,29 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathBuilderMetrics(t *testing.T) {
+	perf := &spoolfile.PerformanceData{
+		Hostname:         "web01.example.com",
+		Service:          "Disk Usage",
+		Command:          "check_disk",
+		PerformanceLabel: "'/var/log'",
+		Time:             "1441791000000",
+		Fields:           map[string]string{"value": "42.5", "warn-min": "10.0", "unknown": "true"},
+	}
+
+	metrics := NewPathBuilder("", "nagflux", "hostcheck").Metrics(perf)
+	assert.Equal(t, []Metric{
+		{Path: "nagflux.web01_example_com.Disk_Usage.var_log.value", Value: 42.5, Timestamp: 1441791000},
+		{Path: "nagflux.web01_example_com.Disk_Usage.var_log.warn-min", Value: 10, Timestamp: 1441791000},
+	}, metrics)
+
+	perf.Service = ""
+	metrics = NewPathBuilder("{prefix}.{command}.{host}.{service}.{field}", "", "hostcheck").Metrics(perf)
+	assert.Equal(t, "check_disk.web01_example_com.hostcheck.value", metrics[0].Path)
+}
@@ -0,0 +1,55 @@
+package graphite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Plaintext is Carbon's line protocol, usually on port 2003
+	Plaintext = "plaintext"
+	// Pickle is Carbon's batch protocol, usually on port 2004
+	Pickle = "pickle"
+)
+
+// EncodePlaintext renders the metrics as "path value timestamp" lines.
+func EncodePlaintext(metrics []Metric) []byte {
+	str := strings.Builder{}
+	for _, m := range metrics {
+		fmt.Fprintf(&str, "%s %s %d\n", m.Path, strconv.FormatFloat(m.Value, 'f', -1, 64), m.Timestamp)
+	}
+	return []byte(str.String())
+}
+
+// EncodePickle renders the metrics as a length prefixed pickle (protocol 2) of [(path, (timestamp, value)), ...].
+func EncodePickle(metrics []Metric) []byte {
+	payload := []byte{0x80, 0x02, ']', '('}
+	for _, m := range metrics {
+		// BINUNICODE path
+		payload = append(payload, 'X')
+		payload = binary.LittleEndian.AppendUint32(payload, uint32(len(m.Path)))
+		payload = append(payload, m.Path...)
+		if m.Timestamp >= math.MinInt32 && m.Timestamp <= math.MaxInt32 {
+			// BININT timestamp
+			payload = append(payload, 'J')
+			payload = binary.LittleEndian.AppendUint32(payload, uint32(int32(m.Timestamp)))
+		} else {
+			// BINFLOAT timestamp
+			payload = append(payload, 'G')
+			payload = binary.BigEndian.AppendUint64(payload, math.Float64bits(float64(m.Timestamp)))
+		}
+		// BINFLOAT value
+		payload = append(payload, 'G')
+		payload = binary.BigEndian.AppendUint64(payload, math.Float64bits(m.Value))
+		// TUPLE2 (timestamp, value), TUPLE2 (path, (...))
+		payload = append(payload, 0x86, 0x86)
+	}
+	// APPENDS, STOP
+	payload = append(payload, 'e', '.')
+
+	result := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	return append(result, payload...)
+}
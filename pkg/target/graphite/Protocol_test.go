@@ -0,0 +1,34 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodePlaintext(t *testing.T) {
+	assert.Equal(t, "a.b 1.5 10\n", string(EncodePlaintext([]Metric{{Path: "a.b", Value: 1.5, Timestamp: 10}})))
+}
+
+func TestEncodePickle(t *testing.T) {
+	encoded := EncodePickle([]Metric{
+		{Path: "a.b", Value: 1.5, Timestamp: 10},
+		// a timestamp beyond int32 is encoded as float
+		{Path: "c", Value: -2, Timestamp: 1 << 32},
+	})
+	assert.Equal(t, []byte{
+		0, 0, 0, 56, // length of the pickle
+		0x80, 0x02, // PROTO 2
+		']', '(', // EMPTY_LIST, MARK
+		'X', 3, 0, 0, 0, 'a', '.', 'b', // BINUNICODE "a.b"
+		'J', 10, 0, 0, 0, // BININT 10
+		'G', 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, // BINFLOAT 1.5
+		0x86, 0x86, // TUPLE2, TUPLE2
+		'X', 1, 0, 0, 0, 'c', // BINUNICODE "c"
+		'G', 0x41, 0xf0, 0, 0, 0, 0, 0, 0, // BINFLOAT 4294967296
+		'G', 0xc0, 0, 0, 0, 0, 0, 0, 0, // BINFLOAT -2
+		0x86, 0x86, // TUPLE2, TUPLE2
+		'e', '.', // APPENDS, STOP
+	}, encoded)
+	assert.Equal(t, []byte{0, 0, 0, 6, 0x80, 0x02, ']', '(', 'e', '.'}, EncodePickle(nil))
+}
@@ -0,0 +1,241 @@
+package graphite
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
+	"github.com/kdar/factorlog"
+)
+
+// Worker reads the perfdata from the queue and sends it to Carbon.
+type Worker struct {
+	quit        chan bool
+	flush       chan bool
+	jobs        chan collector.Printable
+	address     string
+	protocol    string
+	pathBuilder PathBuilder
+	dumpFile    string
+	conn        net.Conn
+	retryPolicy retry.Policy
+	breaker     *retry.Breaker
+	log         *factorlog.FactorLog
+	IsRunning   bool
+	promServer  statistics.PrometheusServer
+	target      data.Target
+}
+
+const (
+	dataTimeout = time.Duration(5) * time.Second
+	// amount of metrics which are sent at once
+	maxMetricsPerBatch = 500
+	dialTimeout        = time.Duration(10) * time.Second
+)
+
+var errorInterrupted = errors.New("got interrupted")
+
+// NewGraphiteWorker creates a worker for the Carbon daemon at the given address and starts it.
+// The protocol has to be plaintext or pickle. Metrics which couldn't be sent are written to the dumpFile if they are not part of a write-ahead log.
+func NewGraphiteWorker(jobs chan collector.Printable, target data.Target, address, protocol, dumpFile string, pathBuilder PathBuilder) *Worker {
+	w := &Worker{
+		quit:        make(chan bool),
+		flush:       make(chan bool, 1),
+		jobs:        jobs,
+		address:     address,
+		protocol:    protocol,
+		pathBuilder: pathBuilder,
+		dumpFile:    nagflux.GenDumpfileName(dumpFile, target),
+		retryPolicy: retry.PolicyFromConfig(),
+		log:         logging.GetLogger(),
+		IsRunning:   true,
+		promServer:  statistics.GetPrometheusServer(),
+		target:      target,
+	}
+	if protocol != Plaintext && protocol != Pickle {
+		w.log.Criticalf("Graphite(%s) protocol %s is not supported, use %s or %s", target.Name, protocol, Plaintext, Pickle)
+		return nil
+	}
+	w.breaker = retry.NewBreaker(target, w.retryPolicy, w.TestIfIsAlive)
+	go w.run()
+	return w
+}
+
+// Stop stops the worker
+func (w *Worker) Stop() {
+	if w.IsRunning {
+		w.quit <- true
+		<-w.quit
+		w.IsRunning = false
+		w.log.Debug("GraphiteWorker(" + w.target.Name + ") stopped")
+	}
+}
+
+// Flush makes the worker send its buffered metrics, it does not wait for it.
+func (w *Worker) Flush() {
+	select {
+	case w.flush <- true:
+	default:
+	}
+}
+
+// Collects the metrics and sends them in batches.
+func (w *Worker) run() {
+	// the queries the metrics were created of, to acknowledge or keep them
+	var queries []collector.Printable
+	var metrics []Metric
+	send := func(withRetry bool) bool {
+		ok := w.sendBuffer(queries, metrics, withRetry)
+		queries, metrics = queries[:0], metrics[:0]
+		return ok
+	}
+	for {
+		select {
+		case <-w.quit:
+			send(false)
+			w.closeConnection()
+			w.quit <- true
+			return
+		case query := <-w.jobs:
+			if !query.TestTargetFilter(w.target.Name) {
+				continue
+			}
+			// only perfdata can be converted to metrics
+			perfs, err := spoolfile.PerformanceDataFor(query, w.target.Datatype)
+			if err != nil {
+				w.log.Warnf("Graphite(%s) skipping records which can't be decoded: %s", w.target.Name, err)
+			}
+			if _, ok := query.(collector.Acknowledger); !ok && len(perfs) == 0 {
+				continue
+			}
+			queries = append(queries, query)
+			for _, perf := range perfs {
+				metrics = append(metrics, w.pathBuilder.Metrics(perf)...)
+			}
+			if len(metrics) >= maxMetricsPerBatch && !send(true) {
+				w.closeConnection()
+				w.quit <- true
+				return
+			}
+		case <-w.flush:
+			if !send(true) {
+				w.closeConnection()
+				w.quit <- true
+				return
+			}
+		case <-time.After(dataTimeout):
+			if !send(true) {
+				w.closeConnection()
+				w.quit <- true
+				return
+			}
+		}
+	}
+}
+
+// Sends the metrics of the given queries, if withRetry is set it reconnects as the retry policy allows.
+// If they can't be sent, the queries are handed back to the write-ahead log or dumped.
+// Returns false if the worker got a quit signal while waiting.
+func (w *Worker) sendBuffer(queries []collector.Printable, metrics []Metric, withRetry bool) bool {
+	if len(metrics) == 0 {
+		collector.AckAll(queries)
+		return true
+	}
+	var payload []byte
+	if w.protocol == Pickle {
+		payload = EncodePickle(metrics)
+	} else {
+		payload = EncodePlaintext(metrics)
+	}
+	policy := w.retryPolicy
+	if !withRetry {
+		policy.MaxAttempts = 1
+	}
+	startTime := time.Now()
+	err := policy.Do(w.breaker, w.waitForQuitOrGoOn, func() error {
+		err := w.write(payload)
+		if err != nil {
+			w.log.Warnf("Graphite(%s) could not send %d metrics: %s", w.target.Name, len(metrics), err)
+			w.closeConnection()
+		}
+		return err
+	})
+	if err != nil {
+		w.log.Warnf("Graphite(%s) keeping %d metrics which couldn't be sent: %s", w.target.Name, len(metrics), err)
+		w.keep(queries)
+		return !errors.Is(err, errorInterrupted)
+	}
+	collector.AckAll(queries)
+	w.promServer.BytesSend.WithLabelValues("Graphite").Add(float64(len(payload)))
+	timeDiff := float64(time.Since(startTime).Seconds() * 1000)
+	if timeDiff >= 0 {
+		w.promServer.SendDuration.WithLabelValues("Graphite").Add(timeDiff)
+	}
+	return true
+}
+
+// Hands the queries back to the write-ahead log, the others are written to the dumpfile.
+func (w *Worker) keep(queries []collector.Printable) {
+	lines := make([]string, len(queries))
+	for i, query := range queries {
+		lines[i] = spoolfile.RenderRecord(query, w.target.Datatype)
+	}
+	if dumpQueries := collector.NackAll(queries, lines); len(dumpQueries) > 0 {
+		w.log.Infof("Dumping metrics which couldn't be sent to: %s", w.dumpFile)
+		if err := nagflux.AppendDumpfile(w.dumpFile, dumpQueries); err != nil {
+			w.log.Critical(err)
+		}
+	}
+}
+
+// Waits on the quit signal or the given backoff.
+func (w *Worker) waitForQuitOrGoOn(backoff time.Duration) error {
+	select {
+	case <-w.quit:
+		return errorInterrupted
+	case <-time.After(backoff):
+		return nil
+	}
+}
+
+// TestIfIsAlive tests if the Carbon daemon accepts connections.
+func (w *Worker) TestIfIsAlive() bool {
+	conn, err := net.DialTimeout("tcp", w.address, dialTimeout)
+	if err != nil {
+		w.log.Infof("Is Graphite(%s) running: false", w.target.Name)
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Writes the payload, the connection is established if needed.
+func (w *Worker) write(payload []byte) error {
+	if w.conn == nil {
+		conn, err := net.DialTimeout("tcp", w.address, dialTimeout)
+		if err != nil {
+			return err
+		}
+		w.log.Debugf("Graphite(%s) connected to %s", w.target.Name, w.address)
+		w.conn = conn
+	}
+	if err := w.conn.SetWriteDeadline(time.Now().Add(dialTimeout)); err != nil {
+		return err
+	}
+	_, err := w.conn.Write(payload)
+	return err
+}
+
+func (w *Worker) closeConnection() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
@@ -0,0 +1,62 @@
+package graphite
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitTestLogger()
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+func TestWorkerDumpsAndReplays(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	// nothing listens anymore
+	listener.Close()
+
+	dumpFile := filepath.Join(t.TempDir(), "nagflux.dump")
+	target := data.Target{Name: "carbon", Datatype: data.Graphite}
+	pathBuilder := NewPathBuilder("{host}.{label}.{field}", "", "")
+	perf := &spoolfile.PerformanceData{
+		Filterable: collector.AllFilterable, Hostname: "host", Service: "load", Command: "check_load",
+		PerformanceLabel: "load1", Time: "1441791000000", Fields: map[string]string{"value": "1.5"},
+	}
+	jobs := make(chan collector.Printable)
+	worker := NewGraphiteWorker(jobs, target, address, Plaintext, dumpFile, pathBuilder)
+	jobs <- perf
+	// the last batch is tried once on shutdown
+	worker.Stop()
+	content, err := os.ReadFile(nagflux.GenDumpfileName(dumpFile, target))
+	require.NoError(t, err)
+	assert.Equal(t, perf.MarshalRecord(), string(content))
+
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	worker = NewGraphiteWorker(jobs, target, listener.Addr().String(), Plaintext, dumpFile, pathBuilder)
+	defer worker.Stop()
+	jobs <- &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: string(content), Datatype: data.Graphite}
+	worker.Flush()
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "host.load1.value 1.5 1441791000\n", line)
+}
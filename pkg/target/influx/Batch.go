@@ -0,0 +1,80 @@
+package influx
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+)
+
+// The defaults of the batch settings.
+const (
+	DefaultBatchMaxLines = 500
+	DefaultBatchMaxDelay = time.Duration(5) * time.Second
+)
+
+// BatchSettings limit the batches of a worker, a batch is sent as soon as one of the limits is reached.
+type BatchSettings struct {
+	MaxLines int
+	// MaxBytes is the uncompressed size of the lines, 0 is unlimited
+	MaxBytes int
+	// MaxDelay is the time the first line of a batch waits at most
+	MaxDelay time.Duration
+	// Gzip compresses the body with Content-Encoding: gzip
+	Gzip bool
+}
+
+// NewBatchSettings creates the settings of the config values, the size is in KB and the delay in seconds.
+// Values of zero or below are replaced by the defaults.
+func NewBatchSettings(maxLines, maxKB int, maxDelay float64, gzip bool) BatchSettings {
+	settings := BatchSettings{MaxLines: DefaultBatchMaxLines, MaxDelay: DefaultBatchMaxDelay, Gzip: gzip}
+	if maxLines > 0 {
+		settings.MaxLines = maxLines
+	}
+	if maxKB > 0 {
+		settings.MaxBytes = maxKB * 1024
+	}
+	if maxDelay > 0 {
+		settings.MaxDelay = time.Duration(maxDelay * float64(time.Second))
+	}
+	return settings
+}
+
+var (
+	bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	gzipPool   = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+)
+
+// encodeBatch writes the lines into a pooled buffer, compressed if gzip is set. The buffer has to be handed back by releaseBuffer.
+func encodeBatch(lines []string, compress bool) (*bytes.Buffer, error) {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	if !compress {
+		for _, line := range lines {
+			buffer.WriteString(line)
+		}
+		return buffer, nil
+	}
+	writer := gzipPool.Get().(*gzip.Writer)
+	defer gzipPool.Put(writer)
+	writer.Reset(buffer)
+	for _, line := range lines {
+		if _, err := writer.Write([]byte(line)); err != nil {
+			releaseBuffer(buffer)
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		releaseBuffer(buffer)
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// releaseBuffer puts the buffer back into the pool, huge buffers are left to the garbage collector.
+func releaseBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() <= 16<<20 {
+		bufferPool.Put(buffer)
+	}
+}
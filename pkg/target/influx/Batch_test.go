@@ -0,0 +1,87 @@
+package influx
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBatchSettings(t *testing.T) {
+	assert.Equal(t, BatchSettings{MaxLines: DefaultBatchMaxLines, MaxDelay: DefaultBatchMaxDelay}, NewBatchSettings(0, 0, 0, false))
+	assert.Equal(t, BatchSettings{MaxLines: 10, MaxBytes: 2048, MaxDelay: 1500 * time.Millisecond, Gzip: true}, NewBatchSettings(10, 2, 1.5, true))
+}
+
+func TestEncodeBatch(t *testing.T) {
+	lines := []string{"m v=1 1\n", "m v=2 2\n"}
+	body, err := encodeBatch(lines, false)
+	require.NoError(t, err)
+	assert.Equal(t, "m v=1 1\nm v=2 2\n", body.String())
+	releaseBuffer(body)
+
+	body, err = encodeBatch(lines, true)
+	require.NoError(t, err)
+	defer releaseBuffer(body)
+	reader, err := gzip.NewReader(body)
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "m v=1 1\nm v=2 2\n", string(decoded))
+}
+
+func TestWorkerBatches(t *testing.T) {
+	var mutex sync.Mutex
+	var batches []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		reader, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		mutex.Lock()
+		batches = append(batches, string(body))
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dumpFile := filepath.Join(t.TempDir(), "nagflux.dump")
+	target := data.Target{Name: "batch", Datatype: data.InfluxDB}
+	jobs := make(chan collector.Printable)
+	// two lines fit into 20 bytes, the third one starts a new batch
+	batch := BatchSettings{MaxLines: 10, MaxBytes: 20, MaxDelay: 200 * time.Millisecond, Gzip: true}
+	connector := &Connector{jobs: jobs, dumpFile: dumpFile, target: target, log: logging.GetLogger(), batch: batch}
+	worker := &Worker{
+		quit: make(chan bool), quitInternal: make(chan bool, 1), flush: make(chan bool, 1), jobs: jobs,
+		connection: server.URL, dumpFile: nagflux.GenDumpfileName(dumpFile, target),
+		spillFile: nagflux.GenDumpfileName(dumpFile+spillSuffix, target), log: logging.GetLogger(),
+		version: "1.0", connector: connector, promServer: statistics.GetPrometheusServer(), target: target,
+	}
+	go worker.run()
+	defer worker.Stop()
+
+	for _, line := range []string{"m v=1 1", "m v=2 2", "m v=3 3"} {
+		jobs <- &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: line, Datatype: data.InfluxDB}
+	}
+	// the last line is sent after the max delay without further jobs
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(batches) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"m v=1 1\nm v=2 2\n", "m v=3 3\n"}, batches)
+}
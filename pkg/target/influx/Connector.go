@@ -6,14 +6,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
 	"github.com/ConSol-Monitoring/nagflux/pkg/config"
 	"github.com/ConSol-Monitoring/nagflux/pkg/data"
 	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
 	"github.com/kdar/factorlog"
 )
 
@@ -23,8 +28,10 @@ type Connector struct {
 	connectionArgs            string
 	dumpFile                  string
 	workers                   []*Worker
+	workersMutex              sync.Mutex
 	maxWorkers                int
 	jobs                      chan collector.Printable
+	replayQueue               chan collector.Printable
 	quit                      chan bool
 	log                       *factorlog.FactorLog
 	version                   string
@@ -38,11 +45,33 @@ type Connector struct {
 	createDatabaseIfNotExists bool
 	healthURL                 string
 	authToken                 string
+	loginData                 string
+	recoveryMutex             sync.Mutex
+	replayCollector           *nagflux.DumpfileCollector
+	retryPolicy               retry.Policy
+	breaker                   *retry.Breaker
+	paused                    atomic.Bool
+	writePermissionCheck      sync.Once
+	tlsConfig                 *tls.Config
+
+	// the org and orgID arguments of InfluxDB v2, the orgID is resolved if only the org is given
+	org   string
+	orgID string
+	// the rp argument of InfluxDB v1
+	retentionPolicy string
+	// retention of a created bucket or retention policy in seconds, 0 is infinite
+	retentionPeriod int
+	batch           BatchSettings
 }
 
+// the data of a paused target is written to this file
+const spillSuffix = "-spill"
+
 // ConnectorFactory Constructor which will create some workers if the connection is established.
-func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionArgs, dumpFile, version string,
+// The spilled data is replayed into the replayQueue, which is the input of the write-ahead log if there is one.
+func ConnectorFactory(jobs, replayQueue chan collector.Printable, connectionHost, connectionArgs, dumpFile, version string,
 	workerAmount, maxWorkers int, createDatabaseIfNotExists, stopReadingDataIfDown bool, target data.Target, clientTimeout int, healthURL string, authToken string,
+	retentionPeriod int, batch BatchSettings, tlsConfig *tls.Config,
 ) *Connector {
 	parsedArgs := helper.StringToMap(connectionArgs, "&", "=")
 	var databaseName string
@@ -51,15 +80,20 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionA
 	}
 
 	timeout := time.Duration(clientTimeout) * time.Second
-	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}
 	client := http.Client{Timeout: timeout, Transport: transport}
 	s := &Connector{
 		connectionHost: connectionHost, connectionArgs: connectionArgs, dumpFile: dumpFile,
-		workers: make([]*Worker, workerAmount), maxWorkers: maxWorkers, jobs: jobs, quit: make(chan bool),
+		workers: make([]*Worker, workerAmount), maxWorkers: maxWorkers, jobs: jobs, replayQueue: replayQueue, quit: make(chan bool),
 		log: logging.GetLogger(), version: version, isAlive: false, databaseExists: false, databaseName: databaseName,
 		httpClient: client, target: target, stopReadingDataIfDown: stopReadingDataIfDown, clientTimeout: clientTimeout, createDatabaseIfNotExists: createDatabaseIfNotExists, healthURL: healthURL,
-		authToken: authToken,
+		authToken: authToken, retryPolicy: retry.PolicyFromConfig(), tlsConfig: tlsConfig,
+		org: parsedArgs["org"], orgID: parsedArgs["orgID"], retentionPolicy: parsedArgs["rp"], retentionPeriod: retentionPeriod,
+		batch: batch,
 	}
+	s.breaker = retry.NewBreaker(target, s.retryPolicy, func() bool {
+		return s.TestIfIsAlive(s.stopReadingDataIfDown)
+	})
 
 	// InfluxDB v2
 	if version == "2.0" {
@@ -76,12 +110,14 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionA
 			if len(result) != 0 && result["X-Influxdb-Build"] == "OSS" {
 				s.log.Critical("InfluxDB OSS requires Orgranization Details. Please provide either orgID or org")
 				s.isAlive = false
+				s.workers = nil
+				go s.run()
 				return s
 			}
 		}
 		// In InfluxDB 2.0 or later versions, databases no longer exist, they are replaced by buckets.
-		s.createDatabaseIfNotExists = false
-		createDatabaseIfNotExists = false
+		s.databaseName = parsedArgs["bucket"]
+		databaseName = s.databaseName
 	}
 
 	if createDatabaseIfNotExists && databaseName == "" {
@@ -108,10 +144,9 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionA
 		s.databaseExists = true
 	}
 
-	loginData := ""
 	if pw, foundPW := parsedArgs["p"]; foundPW {
 		if login, foundLogin := parsedArgs["u"]; foundLogin {
-			loginData = fmt.Sprintf("p=%s&u=%s", pw, login)
+			s.loginData = fmt.Sprintf("p=%s&u=%s", pw, login)
 		}
 	}
 
@@ -119,36 +154,13 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionA
 	if s.version == "2.0" {
 		gen = WorkerGenerator(jobs, connectionHost+"/api/v2/write?"+connectionArgs, dumpFile, version, s, target, stopReadingDataIfDown)
 	}
+	// the database is created as soon as the target is alive, now or later
 	s.TestIfIsAlive(stopReadingDataIfDown)
 	if !s.isAlive && !stopReadingDataIfDown {
 		s.log.Warnf("InfluxDB server(%s) is down but starting anyway due to 'stopReadingDataIfDown' = %t", target.Name, stopReadingDataIfDown)
-	} else {
-		if !s.isAlive {
-			s.log.Info("Waiting for InfluxDB server(" + target.Name + ")")
-		}
-		for !s.isAlive {
-			s.TestIfIsAlive(stopReadingDataIfDown)
-			time.Sleep(time.Duration(5) * time.Second)
-			s.log.Debugln("Waiting for InfluxDB server (" + target.Name + ")")
-		}
-		if s.isAlive {
-			s.log.Debug("Influxdb(" + target.Name + ") is running")
-		}
-		if createDatabaseIfNotExists {
-			s.TestDatabaseExists()
-			for i := 0; i < 5 && !s.databaseExists; i++ {
-				time.Sleep(time.Duration(2) * time.Second)
-				if createDatabaseIfNotExists {
-					s.CreateDatabase(loginData)
-				}
-				s.TestDatabaseExists()
-			}
-			if !s.databaseExists {
-				s.log.Critical("InfluxDB Database(" + databaseName + ") does not exists and Nagflux was not able to create it")
-			}
-		}
+	} else if !s.isAlive {
+		s.log.Warnf("InfluxDB server(%s) is down, its data is buffered on disk until it is back", target.Name)
 	}
-
 	for w := range workerAmount {
 		s.workers[w] = gen(w)
 	}
@@ -158,7 +170,9 @@ func ConnectorFactory(jobs chan collector.Printable, connectionHost, connectionA
 
 // AddWorker creates a new worker
 func (connector *Connector) AddWorker() {
-	oldLength := connector.AmountWorkers()
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
+	oldLength := len(connector.workers)
 	if oldLength < connector.maxWorkers {
 		gen := WorkerGenerator(
 			connector.jobs, connector.connectionHost+"/write?"+connector.connectionArgs,
@@ -171,26 +185,63 @@ func (connector *Connector) AddWorker() {
 			)
 		}
 		connector.workers = append(connector.workers, gen(oldLength+2))
-		connector.log.Infof("Starting Worker: %d -> %d", oldLength, connector.AmountWorkers())
+		connector.log.Infof("Starting Worker: %d -> %d", oldLength, len(connector.workers))
 	}
 }
 
 // RemoveWorker stops a worker
 func (connector *Connector) RemoveWorker() {
-	oldLength := connector.AmountWorkers()
-	if oldLength > 1 {
-		lastWorkerIndex := oldLength - 1
-		connector.workers[lastWorkerIndex].Stop()
-		connector.workers = connector.workers[:lastWorkerIndex]
-		connector.log.Infof("Stopping Worker: %d -> %d", oldLength, connector.AmountWorkers())
+	connector.workersMutex.Lock()
+	oldLength := len(connector.workers)
+	if oldLength <= 1 {
+		connector.workersMutex.Unlock()
+		return
 	}
+	// the worker is detached under the lock and stopped afterwards, stopping blocks until its data is sent
+	lastWorkerIndex := oldLength - 1
+	worker := connector.workers[lastWorkerIndex]
+	connector.workers = connector.workers[:lastWorkerIndex]
+	connector.workersMutex.Unlock()
+	connector.log.Infof("Stopping Worker: %d -> %d", oldLength, lastWorkerIndex)
+	worker.Stop()
 }
 
 // AmountWorkers current amount of workers.
 func (connector *Connector) AmountWorkers() int {
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
 	return len(connector.workers)
 }
 
+// Flush makes the workers send their buffered queries.
+func (connector *Connector) Flush() {
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
+	for _, worker := range connector.workers {
+		worker.Flush()
+	}
+}
+
+// Pause buffers the data of the target on disk until Resume is called.
+func (connector *Connector) Pause() {
+	connector.paused.Store(true)
+	config.StoreValue(connector.target, true)
+	connector.log.Infof("InfluxDB(%s) paused", connector.target.Name)
+}
+
+// Resume sends the data again and replays the data which was buffered meanwhile.
+func (connector *Connector) Resume() {
+	connector.paused.Store(false)
+	config.StoreValue(connector.target, connector.stopReadingDataIfDown && !connector.isAlive)
+	connector.log.Infof("InfluxDB(%s) resumed", connector.target.Name)
+	connector.recover()
+}
+
+// IsPaused returns true if the target was paused by Pause.
+func (connector *Connector) IsPaused() bool {
+	return connector.paused.Load()
+}
+
 // IsAlive is the database system alive.
 func (connector *Connector) IsAlive() bool {
 	return connector.isAlive
@@ -211,39 +262,109 @@ func (connector *Connector) Stop() {
 // Waits just for the end.
 func (connector *Connector) run() {
 	<-connector.quit
-	for _, worker := range connector.workers {
-		go worker.Stop()
+	connector.recoveryMutex.Lock()
+	if connector.replayCollector != nil {
+		connector.replayCollector.Stop()
 	}
-	for len(connector.workers) > 0 {
-		for connector.workers[0].IsRunning {
-			time.Sleep(time.Duration(100) * time.Millisecond)
-		}
-		if len(connector.workers) > 1 {
-			connector.workers = connector.workers[1:]
-		} else {
-			connector.workers = connector.workers[:0]
-		}
+	connector.recoveryMutex.Unlock()
+	connector.workersMutex.Lock()
+	workers := connector.workers
+	connector.workers = nil
+	connector.workersMutex.Unlock()
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Go(worker.Stop)
 	}
+	wg.Wait()
 	connector.quit <- true
 }
 
 // TestIfIsAlive test active if the database system is alive.
 func (connector *Connector) TestIfIsAlive(stopReadingDataIfDown bool) bool {
 	result := helper.RequestedReturnCodeIsOK(connector.httpClient, connector.healthURL, "GET")
+	wasAlive := connector.isAlive
 	connector.isAlive = result
 	connector.log.Infof("Is InfluxDB(%s) running: %t", connector.target.Name, result)
 	if stopReadingDataIfDown {
-		config.StoreValue(connector.target, !result)
+		config.StoreValue(connector.target, !result || connector.IsPaused())
+		if result && !wasAlive {
+			connector.recover()
+		}
+	} else if result && !wasAlive {
+		// it may have been down on start
+		connector.recoveryMutex.Lock()
+		connector.ensureDatabase()
+		connector.recoveryMutex.Unlock()
 	}
 	return result
 }
 
+// Is called if the target is back, creates the database if needed and replays the data which was buffered while it was down.
+func (connector *Connector) recover() {
+	connector.recoveryMutex.Lock()
+	defer connector.recoveryMutex.Unlock()
+	connector.ensureDatabase()
+
+	if connector.replayCollector != nil && connector.replayCollector.IsRunning {
+		// the new spill file will be replayed on the next recovery
+		return
+	}
+	// the replayed data goes through the write-ahead log again, it was acked when it was spilled.
+	// The collector moves the spill file aside, the data spilled meanwhile is replayed on the next recovery.
+	connector.replayCollector = nagflux.NewDumpfileCollector(
+		connector.replayQueue, connector.dumpFile+spillSuffix, connector.target, config.GetConfig().Main.FileBufferSize,
+	)
+}
+
+// Creates the database if it does not exist and createDatabaseIfNotExists is set. The retention policy of the rp
+// argument is created with it, nagflux doesn't change a database it did not offer to create.
+// Afterwards the write permission of an InfluxDB 2.0 is checked, once it was reachable.
+func (connector *Connector) ensureDatabase() {
+	defer connector.checkWritePermissionOnce()
+	if !connector.createDatabaseIfNotExists {
+		return
+	}
+	connector.TestDatabaseExists()
+	for i := 0; i < 5 && !connector.databaseExists; i++ {
+		time.Sleep(time.Duration(2) * time.Second)
+		connector.CreateDatabase(connector.loginData)
+		connector.TestDatabaseExists()
+	}
+	if !connector.databaseExists {
+		connector.log.Critical("InfluxDB Database(" + connector.databaseName + ") does not exists and Nagflux was not able to create it")
+	} else if connector.version != "2.0" && connector.retentionPolicy != "" {
+		connector.ensureRetentionPolicy()
+	}
+}
+
+// Logs if the AuthToken of an InfluxDB 2.0 may not write, it's checked the first time the target is reachable.
+func (connector *Connector) checkWritePermissionOnce() {
+	if connector.version != "2.0" {
+		return
+	}
+	connector.writePermissionCheck.Do(func() {
+		if err := connector.checkWritePermission(); err != nil {
+			connector.log.Criticalf("InfluxDB(%s) can't write: %s", connector.target.Name, err)
+		}
+	})
+}
+
 // TestDatabaseExists test active if the database exists.
 func (connector *Connector) TestDatabaseExists() bool {
 	if !connector.createDatabaseIfNotExists {
 		connector.log.Debug("Skipped TestDatabaseExists:" + connector.databaseName)
 		return true
 	}
+	if connector.version == "2.0" {
+		exists, err := connector.bucketExists()
+		if err != nil {
+			// a token may write without the permission to read orgs and buckets, the write permission is checked on start
+			connector.log.Warnf("InfluxDB(%s) could not test the bucket, assuming it exists: %s", connector.target.Name, err)
+			exists = true
+		}
+		connector.databaseExists = exists
+		return exists
+	}
 	resp, err := connector.httpClient.Get(connector.connectionHost + "/query?q=show%20databases&" + connector.connectionArgs)
 	if err != nil {
 		return false
@@ -269,15 +390,22 @@ func (connector *Connector) TestDatabaseExists() bool {
 	return false
 }
 
-// CreateDatabase creates the database.
+// CreateDatabase creates the database, or the bucket for InfluxDB v2.
 func (connector *Connector) CreateDatabase(loginData string) bool {
+	if connector.version == "2.0" {
+		if err := connector.createBucket(); err != nil {
+			connector.log.Warnf("Could not create bucket %s: %s", connector.databaseName, err)
+			return false
+		}
+		return true
+	}
 	host := connector.connectionHost + "/query"
 	if loginData != "" {
 		host += "?" + loginData + "&"
 	} else {
 		host += "?"
 	}
-	host += "q=CREATE%20DATABASE%20" + connector.databaseName
+	host += "q=" + url.QueryEscape("CREATE DATABASE "+quoteIdentifier(connector.databaseName))
 
 	result := helper.RequestedReturnCodeIsOK(connector.httpClient, host, "GET")
 	if !result {
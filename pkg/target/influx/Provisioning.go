@@ -0,0 +1,224 @@
+package influx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// statusError is an answer of the v2 API which is not 2xx.
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// Returns the status code of a statusError or 0 for other errors.
+func statusCode(err error) int {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code
+	}
+	return 0
+}
+
+// Sends a request to the v2 API with the AuthToken and decodes the JSON answer into result, if it's not nil.
+func (connector *Connector) requestV2(method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, connector.connectionHost+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Nagflux")
+	req.Header.Set("Authorization", "Token "+connector.authToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := connector.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return &statusError{resp.StatusCode, "the AuthToken is not valid: " + strings.TrimSpace(string(answer))}
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return &statusError{resp.StatusCode, fmt.Sprintf("%s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(answer)))}
+	}
+	if result != nil {
+		return json.Unmarshal(answer, result)
+	}
+	return nil
+}
+
+// Returns the orgID argument or resolves the org argument to its ID.
+func (connector *Connector) resolveOrgID() (string, error) {
+	if connector.orgID != "" {
+		return connector.orgID, nil
+	}
+	if connector.org == "" {
+		return "", errors.New("the Arguments contain neither org nor orgID")
+	}
+	var result struct {
+		Orgs []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"orgs"`
+	}
+	err := connector.requestV2(http.MethodGet, "/api/v2/orgs?org="+url.QueryEscape(connector.org), nil, &result)
+	if statusCode(err) == http.StatusNotFound || err == nil && len(result.Orgs) == 0 {
+		return "", fmt.Errorf("the org %q does not exist or the AuthToken can't read it", connector.org)
+	} else if err != nil {
+		return "", err
+	}
+	connector.orgID = result.Orgs[0].ID
+	return connector.orgID, nil
+}
+
+// Tests if the bucket exists in the org.
+func (connector *Connector) bucketExists() (bool, error) {
+	orgID, err := connector.resolveOrgID()
+	if err != nil {
+		return false, err
+	}
+	var result struct {
+		Buckets []struct {
+			Name string `json:"name"`
+		} `json:"buckets"`
+	}
+	err = connector.requestV2(http.MethodGet, "/api/v2/buckets?orgID="+url.QueryEscape(orgID)+"&name="+url.QueryEscape(connector.databaseName), nil, &result)
+	if statusCode(err) == http.StatusNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	for _, bucket := range result.Buckets {
+		if bucket.Name == connector.databaseName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Creates the bucket with the retention period, 0 keeps the data forever.
+func (connector *Connector) createBucket() error {
+	orgID, err := connector.resolveOrgID()
+	if err != nil {
+		return err
+	}
+	type retentionRule struct {
+		Type         string `json:"type"`
+		EverySeconds int    `json:"everySeconds"`
+	}
+	bucket := struct {
+		OrgID          string          `json:"orgID"`
+		Name           string          `json:"name"`
+		RetentionRules []retentionRule `json:"retentionRules"`
+	}{OrgID: orgID, Name: connector.databaseName, RetentionRules: []retentionRule{}}
+	if connector.retentionPeriod > 0 {
+		bucket.RetentionRules = append(bucket.RetentionRules, retentionRule{Type: "expire", EverySeconds: connector.retentionPeriod})
+	}
+	return connector.requestV2(http.MethodPost, "/api/v2/buckets", bucket, nil)
+}
+
+// Tests if the AuthToken may write into the bucket by writing no data.
+func (connector *Connector) checkWritePermission() error {
+	err := connector.requestV2(http.MethodPost, "/api/v2/write?"+connector.connectionArgs, nil, nil)
+	switch statusCode(err) {
+	case http.StatusNotFound:
+		return fmt.Errorf("the bucket %q does not exist or the AuthToken has no write permission for it", connector.databaseName)
+	case http.StatusForbidden:
+		return fmt.Errorf("the AuthToken has no write permission for the bucket %q", connector.databaseName)
+	case http.StatusBadRequest:
+		// the request was authorized, just the empty data is rejected
+		return nil
+	}
+	return err
+}
+
+// Creates the retention policy of the rp argument in the database if it does not exist, it is not made the default.
+// Like the database it's only created if createDatabaseIfNotExists is set, see ensureDatabase.
+func (connector *Connector) ensureRetentionPolicy() {
+	rp := connector.retentionPolicy
+	exists, err := connector.retentionPolicyExists(rp)
+	if err != nil {
+		connector.log.Warnf("InfluxDB(%s) could not list the retention policies: %s", connector.target.Name, err)
+		return
+	}
+	if exists {
+		return
+	}
+	duration := "INF"
+	if connector.retentionPeriod > 0 {
+		duration = fmt.Sprintf("%ds", connector.retentionPeriod)
+	}
+	query := fmt.Sprintf(`CREATE RETENTION POLICY %s ON %s DURATION %s REPLICATION 1`,
+		quoteIdentifier(rp), quoteIdentifier(connector.databaseName), duration)
+	if !connector.query(query) {
+		connector.log.Warnf("InfluxDB(%s) could not create the retention policy %s", connector.target.Name, rp)
+	}
+}
+
+func (connector *Connector) retentionPolicyExists(rp string) (bool, error) {
+	resp, err := connector.httpClient.Get(connector.queryURL("SHOW RETENTION POLICIES ON " + quoteIdentifier(connector.databaseName)))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, errors.New(resp.Status)
+	}
+	var result ShowSeriesResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	for _, results := range result.Results {
+		for _, series := range results.Series {
+			for _, value := range series.Values {
+				if len(value) > 0 && value[0] == rp {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// Runs the query against the v1 API with the login data of the Arguments.
+func (connector *Connector) query(query string) bool {
+	resp, err := connector.httpClient.Post(connector.queryURL(query), "", nil)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// Returns the name as double quoted identifier of InfluxQL.
+func quoteIdentifier(name string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+}
+
+func (connector *Connector) queryURL(query string) string {
+	host := connector.connectionHost + "/query?"
+	if connector.loginData != "" {
+		host += connector.loginData + "&"
+	}
+	return host + "q=" + url.QueryEscape(query)
+}
@@ -0,0 +1,147 @@
+package influx
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisionBucket(t *testing.T) {
+	var mutex sync.Mutex
+	var created map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.URL.Path != "/ping" && r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /ping":
+			w.WriteHeader(http.StatusNoContent)
+		case "GET /api/v2/orgs":
+			assert.Equal(t, "monitoring", r.URL.Query().Get("org"))
+			io.WriteString(w, `{"orgs":[{"id":"0123","name":"monitoring"}]}`)
+		case "GET /api/v2/buckets":
+			assert.Equal(t, "0123", r.URL.Query().Get("orgID"))
+			if created == nil {
+				io.WriteString(w, `{"buckets":[]}`)
+			} else {
+				io.WriteString(w, `{"buckets":[{"name":"nagflux"}]}`)
+			}
+		case "POST /api/v2/buckets":
+			body, _ := io.ReadAll(r.Body)
+			require.NoError(t, json.Unmarshal(body, &created))
+			w.WriteHeader(http.StatusCreated)
+		case "POST /api/v2/write":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	target := data.Target{Name: "v2", Datatype: data.InfluxDB}
+	connector := ConnectorFactory(make(chan collector.Printable), make(chan collector.Printable), server.URL, "org=monitoring&bucket=nagflux&precision=ms",
+		filepath.Join(t.TempDir(), "dump"), "2.0", 0, 1, true, false, target, 5, "", "secret", 86400, NewBatchSettings(0, 0, 0, false), nil)
+	defer connector.Stop()
+
+	assert.True(t, connector.DatabaseExists())
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, map[string]any{
+		"orgID": "0123", "name": "nagflux",
+		"retentionRules": []any{map[string]any{"type": "expire", "everySeconds": float64(86400)}},
+	}, created)
+}
+
+func TestCheckWritePermission(t *testing.T) {
+	status := http.StatusForbidden
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	connector := &Connector{connectionHost: server.URL, connectionArgs: "org=a&bucket=b", databaseName: "b", log: logging.GetLogger()}
+
+	assert.EqualError(t, connector.checkWritePermission(), `the AuthToken has no write permission for the bucket "b"`)
+	status = http.StatusUnauthorized
+	assert.ErrorContains(t, connector.checkWritePermission(), "the AuthToken is not valid")
+	status = http.StatusBadRequest
+	assert.NoError(t, connector.checkWritePermission(), "an authorized write of no data")
+}
+
+func TestWritePermissionIsCheckedOnceAlive(t *testing.T) {
+	var alive, writes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ping":
+			if alive.Load() == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/write":
+			writes.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	target := data.Target{Name: "v2", Datatype: data.InfluxDB}
+	connector := ConnectorFactory(make(chan collector.Printable), make(chan collector.Printable), server.URL, "orgID=0123&bucket=nagflux",
+		filepath.Join(t.TempDir(), "dump"), "2.0", 0, 1, false, false, target, 5, "", "secret", 0, NewBatchSettings(0, 0, 0, false), nil)
+	defer connector.Stop()
+	assert.Zero(t, writes.Load(), "the target is down on start")
+
+	alive.Store(1)
+	assert.True(t, connector.TestIfIsAlive(false))
+	assert.True(t, connector.TestIfIsAlive(false))
+	assert.Equal(t, int32(1), writes.Load(), "the permission is checked the first time the target is alive")
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, `"nagflux"`, quoteIdentifier("nagflux"))
+	assert.Equal(t, `"a\"b\\c"`, quoteIdentifier(`a"b\c`))
+}
+
+func TestEnsureRetentionPolicy(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		if query == "" {
+			// the health check
+			return
+		}
+		queries = append(queries, r.Method+" "+query)
+		switch query {
+		case "show databases":
+			io.WriteString(w, `{"results":[{"series":[{"name":"databases","columns":["name"],"values":[["nagflux"]]}]}]}`)
+		case `SHOW RETENTION POLICIES ON "nagflux"`:
+			io.WriteString(w, `{"results":[{"series":[{"columns":["name","duration"],"values":[["autogen","0s"]]}]}]}`)
+		}
+	}))
+	defer server.Close()
+
+	target := data.Target{Name: "v1", Datatype: data.InfluxDB}
+	connector := ConnectorFactory(make(chan collector.Printable), make(chan collector.Printable), server.URL, "db=nagflux&rp=week&u=root&p=pw",
+		filepath.Join(t.TempDir(), "dump"), "1.0", 0, 1, true, false, target, 5, "", "", 604800, NewBatchSettings(0, 0, 0, false), nil)
+	defer connector.Stop()
+
+	assert.Equal(t, []string{
+		"GET show databases",
+		`GET SHOW RETENTION POLICIES ON "nagflux"`,
+		`POST CREATE RETENTION POLICY "week" ON "nagflux" DURATION 604800s REPLICATION 1`,
+	}, queries)
+}
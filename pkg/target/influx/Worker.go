@@ -2,12 +2,11 @@ package influx
 
 import (
 	"bytes"
-	"crypto/tls"
 	"errors"
 	"io"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +16,7 @@ import (
 	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
 	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
 	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
 	"github.com/kdar/factorlog"
 )
 
@@ -25,9 +25,11 @@ type Worker struct {
 	workerID              int
 	quit                  chan bool
 	quitInternal          chan bool
+	flush                 chan bool
 	jobs                  chan collector.Printable
 	connection            string
 	dumpFile              string
+	spillFile             string
 	log                   *factorlog.FactorLog
 	version               string
 	connector             *Connector
@@ -38,8 +40,6 @@ type Worker struct {
 	stopReadingDataIfDown bool
 }
 
-const dataTimeout = time.Duration(5) * time.Second
-
 var (
 	errorInterrupted  = errors.New("got interrupted")
 	errorBadRequest   = errors.New("400 Bad Request")
@@ -57,17 +57,14 @@ func WorkerGenerator(jobs chan collector.Printable, connection, dumpFile, versio
 	return func(workerId int) *Worker {
 		// timeout := time.Duration(5 * time.Second)
 		timeout := connector.httpClient.Timeout
-		transport := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		}
+		transport := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: connector.tlsConfig}
 		client := http.Client{Timeout: timeout, Transport: transport}
 		worker := &Worker{
 			workerID: workerId, quit: make(chan bool),
-			quitInternal: make(chan bool, 1), jobs: jobs,
+			quitInternal: make(chan bool, 1), flush: make(chan bool, 1), jobs: jobs,
 			connection: connection, dumpFile: nagflux.GenDumpfileName(dumpFile, target),
-			log: logging.GetLogger(), version: version,
+			spillFile: nagflux.GenDumpfileName(dumpFile+spillSuffix, target),
+			log:       logging.GetLogger(), version: version,
 			connector: connector, httpClient: client, IsRunning: true, promServer: statistics.GetPrometheusServer(),
 			target: target, stopReadingDataIfDown: stopReadingDataIfDown,
 		}
@@ -85,24 +82,69 @@ func (worker *Worker) Stop() {
 	worker.log.Debug("InfluxWorker(" + worker.target.Name + ") stopped")
 }
 
+// Flush makes the worker send its buffered queries, it does not wait for it.
+func (worker *Worker) Flush() {
+	select {
+	case worker.flush <- true:
+	default:
+	}
+}
+
 // Tries to send data all the time.
 func (worker *Worker) run() {
 	var queries []collector.Printable
 	var query collector.Printable
+	var nextTest time.Time
+	// the rendered queries, their size and when the first one was added
+	var lines []string
+	var size int
+	var batchStart time.Time
+	batch := worker.connector.batch
+	send := func() {
+		worker.sendBuffer(queries, lines)
+		queries, lines, size = queries[:0], lines[:0], 0
+	}
 	for {
 		testConnector := false
 		switch {
+		case worker.connector.IsPaused():
+			// buffer the data on disk, it's replayed when the target is resumed
+			if len(queries) > 0 {
+				worker.spill(queries)
+				queries, lines, size = queries[:0], lines[:0], 0
+			}
+			select {
+			case <-worker.quit:
+				worker.log.Debug("InfluxWorker(" + worker.target.Name + ") quitting...")
+				worker.quit <- true
+				return
+			case query = <-worker.jobs:
+				worker.spill([]collector.Printable{query})
+			case <-time.After(time.Duration(1) * time.Second):
+			}
+
 		case worker.stopReadingDataIfDown && !worker.connector.IsAlive():
 			testConnector = true
 			fallthrough
 		case worker.stopReadingDataIfDown && !worker.connector.DatabaseExists():
+			// buffer the data of this target on disk, so the collectors can go on feeding the other targets
+			if len(queries) > 0 {
+				worker.spill(queries)
+				queries, lines, size = queries[:0], lines[:0], 0
+			}
+			if nextTest.IsZero() {
+				nextTest = time.Now().Add(time.Duration(10) * time.Second)
+			}
 			// wait for quit or test connector / database every 10 seconds
 			select {
 			case <-worker.quit:
 				worker.log.Debug("InfluxWorker(" + worker.target.Name + ") quitting...")
 				worker.quit <- true
 				return
-			case <-time.After(time.Duration(10) * time.Second):
+			case query = <-worker.jobs:
+				worker.spill([]collector.Printable{query})
+			case <-time.After(time.Until(nextTest)):
+				nextTest = time.Time{}
 				if testConnector {
 					// Test Influxdb
 					test := worker.connector.TestIfIsAlive(worker.stopReadingDataIfDown)
@@ -115,85 +157,101 @@ func (worker *Worker) run() {
 			}
 
 		default:
+			// the batch is sent at the latest MaxDelay after its first query arrived
+			wait := batch.MaxDelay
+			if len(queries) > 0 {
+				wait = time.Until(batchStart.Add(batch.MaxDelay))
+			}
 			// wait for quit or incoming jobs
 			select {
 			case <-worker.quit:
 				worker.log.Debug("InfluxWorker(" + worker.target.Name + ") quitting...")
-				worker.sendBuffer(queries)
+				send()
 				worker.quit <- true
 				return
 			case query = <-worker.jobs:
 				test := query.TestTargetFilter(worker.target.Name)
 				worker.log.Trace("TestTargetFilter (" + worker.target.Name + "): " + strconv.FormatBool(test))
 				if test {
+					line := worker.castJobToString(query)
+					if batch.MaxBytes > 0 && len(queries) > 0 && size+len(line) > batch.MaxBytes {
+						send()
+					}
+					if len(queries) == 0 {
+						batchStart = time.Now()
+					}
 					queries = append(queries, query)
-					if len(queries) == 500 {
-						worker.sendBuffer(queries)
-						queries = queries[:0]
+					lines = append(lines, line)
+					size += len(line)
+					if len(queries) >= batch.MaxLines {
+						send()
 					}
 				}
-			case <-time.After(dataTimeout):
-				worker.sendBuffer(queries)
-				queries = queries[:0]
+			case <-worker.flush:
+				send()
+			case <-time.After(wait):
+				send()
 			}
 		}
 	}
 }
 
-// Sends the given queries to the influxdb.
-func (worker *Worker) sendBuffer(queries []collector.Printable) {
+// Sends the given queries to the influxdb, lineQueries are the rendered queries.
+func (worker *Worker) sendBuffer(queries []collector.Printable, lineQueries []string) {
 	if len(queries) == 0 {
 		return
 	}
 
-	var lineQueries []string
-	for _, query := range queries {
-		cast := worker.castJobToString(query)
-		lineQueries = append(lineQueries, cast)
-	}
-
-	var dataToSend []byte
-	for _, lineQuery := range lineQueries {
-		dataToSend = append(dataToSend, []byte(lineQuery)...)
+	body, err := encodeBatch(lineQueries, worker.connector.batch.Gzip)
+	if err != nil {
+		worker.log.Critical(err)
+		return
 	}
+	defer releaseBuffer(body)
+	dataToSend := body.Bytes()
+	worker.log.Debug("sendData (" + worker.target.Name + ")\n" + strings.Join(lineQueries, ""))
 
 	startTime := time.Now()
-	sendErr := worker.sendData(dataToSend, true)
-	if sendErr != nil {
-		worker.connector.TestIfIsAlive(worker.stopReadingDataIfDown)
-		worker.connector.TestDatabaseExists()
-		for range 2 {
-			switch sendErr {
-			case errorBadRequest:
-				// Maybe just a few queries are wrong, so send them one by one and find the bad one
-				var badQueries []string
-				for _, lineQuery := range lineQueries {
-					queryErr := worker.sendData([]byte(lineQuery), false)
-					if queryErr != nil {
-						badQueries = append(badQueries, lineQuery)
-					}
-				}
-				worker.dumpErrorQueries("\n\nOne of the values is not clean..\n", badQueries)
-				sendErr = nil
-			case nil:
-				// Single point of exit
-				break
-			default:
-				if err := worker.waitForQuitOrGoOn(); err != nil {
-					// No error handling, because it's time to terminate
-					worker.dumpRemainingQueries(lineQueries)
-				}
-				// Resend Data
-				sendErr = worker.sendData(dataToSend, false)
+	log := true
+	sendErr := worker.connector.retryPolicy.Do(worker.connector.breaker, worker.waitForQuitOrGoOn, func() error {
+		err := worker.sendData(dataToSend, log)
+		log = false
+		if err == errorBadRequest {
+			return retry.Permanent(err)
+		}
+		return err
+	})
+	switch {
+	case sendErr == nil:
+	case errors.Is(sendErr, errorBadRequest):
+		// Maybe just a few queries are wrong, so send them one by one and find the bad one
+		var badQueries []string
+		for _, lineQuery := range lineQueries {
+			if queryErr := worker.sendQuery(lineQuery); queryErr != nil {
+				badQueries = append(badQueries, lineQuery)
 			}
 		}
-		if sendErr != nil {
-			// if there is still an error dump the queries and go on
+		worker.dumpErrorQueries("\n\nOne of the values is not clean..\n", badQueries)
+		sendErr = nil
+	case errors.Is(sendErr, errorInterrupted):
+		// No error handling, because it's time to terminate
+		worker.dumpRemainingQueries(collector.NackAll(queries, lineQueries))
+	default:
+		if !errors.Is(sendErr, retry.ErrCircuitOpen) {
+			worker.connector.TestIfIsAlive(worker.stopReadingDataIfDown)
+			worker.connector.TestDatabaseExists()
+		}
+		// if there is still an error hand the queries back to the write-ahead log or dump them and go on
+		if dumpQueries := collector.NackAll(queries, lineQueries); len(dumpQueries) > 0 {
 			worker.log.Infof("Dumping queries which couldn't be sent to: %s", worker.dumpFile)
-			worker.dumpQueries(worker.dumpFile, lineQueries)
+			worker.dumpQueries(worker.dumpFile, dumpQueries)
 		}
 	}
-	worker.promServer.BytesSend.WithLabelValues("InfluxDB").Add(float64(len(lineQueries)))
+	if sendErr == nil {
+		collector.AckAll(queries)
+	}
+	worker.promServer.SendLatency.WithLabelValues(worker.target.String()).Observe(time.Since(startTime).Seconds())
+	worker.promServer.BytesSend.WithLabelValues("InfluxDB").Add(float64(len(dataToSend)))
 	timeDiff := float64(time.Since(startTime).Seconds() * 1000)
 	if timeDiff >= 0 {
 		worker.promServer.SendDuration.WithLabelValues("InfluxDB").Add(timeDiff)
@@ -208,6 +266,10 @@ func (worker *Worker) readQueriesFromQueue() []string {
 	for !stop {
 		select {
 		case query = <-worker.jobs:
+			// records of the write-ahead log stay on disk and will be replayed
+			if _, ok := query.(collector.Acknowledger); ok {
+				continue
+			}
 			if query.TestTargetFilter(worker.target.Name) {
 				cast := worker.castJobToString(query)
 				queries = append(queries, cast)
@@ -219,16 +281,27 @@ func (worker *Worker) readQueriesFromQueue() []string {
 	return queries
 }
 
-// sends the raw data to influxdb and returns an err if given.
-func (worker *Worker) sendData(rawData []byte, log bool) error {
-	if log {
-		worker.log.Debug("sendData (" + worker.target.Name + ")\n" + string(rawData))
+// Sends a single query, encoded like the batches.
+func (worker *Worker) sendQuery(lineQuery string) error {
+	body, err := encodeBatch([]string{lineQuery}, worker.connector.batch.Gzip)
+	if err != nil {
+		return err
 	}
-	req, err := http.NewRequest(http.MethodPost, worker.connection, bytes.NewBuffer(rawData))
+	defer releaseBuffer(body)
+	return worker.sendData(body.Bytes(), false)
+}
+
+// sends the encoded data to influxdb and returns an err if given, log logs the response of a failure.
+func (worker *Worker) sendData(rawData []byte, log bool) error {
+	req, err := http.NewRequest(http.MethodPost, worker.connection, bytes.NewReader(rawData))
 	if err != nil {
 		worker.log.Warn(err)
+		return err
 	}
 	req.Header.Set("User-Agent", "Nagflux")
+	if worker.connector.batch.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	if worker.version == "2.0" {
 		req.Header.Set("Authorization", "Token "+worker.connector.authToken)
 	}
@@ -268,8 +341,8 @@ func (worker *Worker) logHTTPResponse(resp *http.Response) {
 	worker.log.Warnf("Influx status: %s - %s", resp.Status, string(body))
 }
 
-// Waits on an internal quit signal.
-func (worker *Worker) waitForQuitOrGoOn() error {
+// Waits on an internal quit signal or the given backoff.
+func (worker *Worker) waitForQuitOrGoOn(backoff time.Duration) error {
 	select {
 	// Got stop signal
 	case <-worker.quitInternal:
@@ -277,11 +350,39 @@ func (worker *Worker) waitForQuitOrGoOn() error {
 		worker.quitInternal <- true
 		return errorInterrupted
 	// Timeout and retry
-	case <-time.After(time.Duration(10) * time.Second):
+	case <-time.After(backoff):
 		return nil
 	}
 }
 
+// Writes the queries and everything which is already queued to the spill file, it is replayed when the target is back.
+func (worker *Worker) spill(queries []collector.Printable) {
+drain:
+	for len(queries) < 500 {
+		select {
+		case query := <-worker.jobs:
+			queries = append(queries, query)
+		default:
+			break drain
+		}
+	}
+	var lineQueries []string
+	for _, query := range queries {
+		if query.TestTargetFilter(worker.target.Name) {
+			lineQueries = append(lineQueries, worker.castJobToString(query))
+		}
+	}
+	if err := worker.dumpQueries(worker.spillFile, lineQueries); err != nil {
+		// the write-ahead log delivers its records again, the others are lost
+		worker.log.Criticalf("InfluxWorker(%s) could not spill the data: %s", worker.target.Name, err)
+		collector.NackAll(queries, make([]string, len(queries)))
+		return
+	}
+	// records of the write-ahead log are stored in the spill file now
+	collector.AckAll(queries)
+	worker.promServer.SpilledQueries.WithLabelValues(worker.target.Name).Add(float64(len(lineQueries)))
+}
+
 // Writes the bad queries to a dumpfile.
 func (worker *Worker) dumpErrorQueries(messageForLog string, errorQueries []string) {
 	errorFile := worker.dumpFile + "-errors"
@@ -301,25 +402,15 @@ func (worker *Worker) dumpRemainingQueries(remainingQueries []string) {
 	}
 }
 
-// Writes queries to a dumpfile.
-func (worker *Worker) dumpQueries(filename string, queries []string) {
+// Writes queries to a dumpfile and syncs it to disk, returns the first error.
+func (worker *Worker) dumpQueries(filename string, queries []string) error {
 	mutex.Lock()
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		if _, err := os.Create(filename); err != nil {
-			worker.log.Critical(err)
-		}
-	}
-	if f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0o600); err != nil {
+	defer mutex.Unlock()
+	err := nagflux.AppendDumpfile(filename, queries)
+	if err != nil {
 		worker.log.Critical(err)
-	} else {
-		defer f.Close()
-		for _, query := range queries {
-			if _, err = f.WriteString(query); err != nil {
-				worker.log.Critical(err)
-			}
-		}
 	}
-	mutex.Unlock()
+	return err
 }
 
 // Converts an collector.Printable to a string. Can exit the program if Influx version is not supported
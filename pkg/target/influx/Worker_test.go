@@ -0,0 +1,80 @@
+package influx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitTestLogger()
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+// a Printable of a write-ahead log
+type testRecord struct {
+	collector.SimplePrintable
+	acked, nacked int
+}
+
+func (r *testRecord) Ack()  { r.acked++ }
+func (r *testRecord) Nack() { r.nacked++ }
+
+func TestSpillAndRecover(t *testing.T) {
+	dumpFile := filepath.Join(t.TempDir(), "nagflux.dump")
+	target := data.Target{Name: "test", Datatype: data.InfluxDB}
+	jobs := make(chan collector.Printable, 10)
+	// the input of the write-ahead log
+	queue := make(chan collector.Printable, 10)
+	connector := &Connector{jobs: jobs, replayQueue: queue, dumpFile: dumpFile, target: target, log: logging.GetLogger()}
+	worker := &Worker{
+		jobs: jobs, spillFile: nagflux.GenDumpfileName(dumpFile+spillSuffix, target), log: logging.GetLogger(),
+		version: "1.0", connector: connector, promServer: statistics.GetPrometheusServer(), target: target,
+	}
+
+	jobs <- &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: "m v=2 2", Datatype: data.InfluxDB}
+	jobs <- &collector.SimplePrintable{Filterable: collector.Filterable{Filter: "other"}, Text: "m v=3 3", Datatype: data.InfluxDB}
+	worker.spill([]collector.Printable{&collector.SimplePrintable{Filterable: collector.AllFilterable, Text: "m v=1 1", Datatype: data.InfluxDB}})
+	assert.Empty(t, jobs)
+	content, err := os.ReadFile(worker.spillFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "m v=1 1\nm v=2 2\n", string(content))
+
+	connector.recover()
+	for _, expected := range []string{"m v=1 1", "m v=2 2"} {
+		select {
+		case query := <-queue:
+			assert.Equal(t, expected, query.PrintForInfluxDB("1.0"))
+		case <-time.After(5 * time.Second):
+			t.Fatal("spill file was not replayed")
+		}
+	}
+	assert.Empty(t, jobs, "the replayed data has to go through the write-ahead log")
+	assert.NoFileExists(t, worker.spillFile)
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(worker.spillFile + nagflux.ReplaySuffix)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSpillFailureKeepsRecords(t *testing.T) {
+	target := data.Target{Name: "test", Datatype: data.InfluxDB}
+	jobs := make(chan collector.Printable, 10)
+	worker := &Worker{
+		jobs: jobs, spillFile: filepath.Join(t.TempDir(), "missing", "nagflux.dump-spill"), log: logging.GetLogger(),
+		version: "1.0", connector: &Connector{target: target}, promServer: statistics.GetPrometheusServer(), target: target,
+	}
+	record := &testRecord{SimplePrintable: collector.SimplePrintable{Filterable: collector.AllFilterable, Text: "m v=1 1", Datatype: data.InfluxDB}}
+	worker.spill([]collector.Printable{record})
+	assert.Equal(t, 1, record.nacked, "the write-ahead log has to deliver the record again")
+	assert.Zero(t, record.acked)
+}
@@ -0,0 +1,191 @@
+package otlp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+)
+
+// ScopeName is the instrumentation scope of every metric and log record.
+const ScopeName = "nagflux"
+
+// EventSource is implemented by the livestatus data, like notifications, comments and downtimes.
+type EventSource interface {
+	Events() []livestatus.Event
+}
+
+type resourceKey struct {
+	host    string
+	service string
+}
+
+// Batch collects metrics and log records grouped by their resource, which is the host and service.
+type Batch struct {
+	metricPrefix    string
+	hostcheckAlias  string
+	metrics         MetricsRequest
+	metricResources map[resourceKey]int
+	logs            LogsRequest
+	logResources    map[resourceKey]int
+	dataPoints      int
+	logRecords      int
+}
+
+// NewBatch creates an empty batch.
+func NewBatch(metricPrefix, hostcheckAlias string) *Batch {
+	b := &Batch{metricPrefix: metricPrefix, hostcheckAlias: hostcheckAlias}
+	b.Reset()
+	return b
+}
+
+// Reset removes all collected data.
+func (b *Batch) Reset() {
+	b.metrics = MetricsRequest{ResourceMetrics: []ResourceMetrics{}}
+	b.metricResources = map[resourceKey]int{}
+	b.logs = LogsRequest{ResourceLogs: []ResourceLogs{}}
+	b.logResources = map[resourceKey]int{}
+	b.dataPoints = 0
+	b.logRecords = 0
+}
+
+// Len returns the amount of data points and log records.
+func (b *Batch) Len() int {
+	return b.dataPoints + b.logRecords
+}
+
+// Metrics returns the collected metrics.
+func (b *Batch) Metrics() (MetricsRequest, bool) {
+	return b.metrics, b.dataPoints > 0
+}
+
+// Logs returns the collected log records.
+func (b *Batch) Logs() (LogsRequest, bool) {
+	return b.logs, b.logRecords > 0
+}
+
+// AddPerformanceData adds one gauge per numeric field of the perfdata, like value, warn, crit, min and max.
+// The metric name is the prefix followed by the field, command, performanceLabel and the tags are added as attributes.
+func (b *Batch) AddPerformanceData(p *spoolfile.PerformanceData) {
+	timestamp, err := strconv.ParseUint(p.Time, 10, 64)
+	if err != nil {
+		return
+	}
+	attributes := []KeyValue{}
+	for k, v := range p.Tags {
+		attributes = append(attributes, KeyValue{Key: k, Value: AnyValue{StringValue: v}})
+	}
+	attributes = append(attributes,
+		KeyValue{Key: "command", Value: AnyValue{StringValue: p.Command}},
+		KeyValue{Key: "performanceLabel", Value: AnyValue{StringValue: strings.Trim(p.PerformanceLabel, `'`)}},
+	)
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Key < attributes[j].Key })
+
+	fields := make([]string, 0, len(p.Fields))
+	for field := range p.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var scope *ScopeMetrics
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(p.Fields[field], 64)
+		if err != nil {
+			continue
+		}
+		if scope == nil {
+			scope = b.scopeMetrics(p.Hostname, p.Service)
+		}
+		scope.Metrics = append(scope.Metrics, Metric{
+			Name: b.metricPrefix + "." + field,
+			Unit: p.Unit,
+			Gauge: Gauge{DataPoints: []NumberDataPoint{{
+				Attributes:   attributes,
+				TimeUnixNano: timestamp * uint64(time.Millisecond),
+				AsDouble:     value,
+			}}},
+		})
+		b.dataPoints++
+	}
+}
+
+// AddEvents adds one log record per event.
+func (b *Batch) AddEvents(events []livestatus.Event) {
+	observed := uint64(time.Now().UnixNano())
+	for _, event := range events {
+		timestamp, err := strconv.ParseUint(event.Time, 10, 64)
+		if err != nil {
+			continue
+		}
+		attributes := []KeyValue{}
+		if event.Author != "" {
+			attributes = append(attributes, KeyValue{Key: "author", Value: AnyValue{StringValue: event.Author}})
+		}
+		if event.Type != "" {
+			attributes = append(attributes, KeyValue{Key: "type", Value: AnyValue{StringValue: event.Type}})
+		}
+		severityNumber, severityText := severity(event.Level)
+		scope := b.scopeLogs(event.Host, event.Service)
+		scope.LogRecords = append(scope.LogRecords, LogRecord{
+			TimeUnixNano:         timestamp * uint64(time.Second),
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       severityNumber,
+			SeverityText:         severityText,
+			Body:                 AnyValue{StringValue: event.Message},
+			Attributes:           attributes,
+		})
+		b.logRecords++
+	}
+}
+
+// Maps the Nagios state to the OTLP severity.
+func severity(level string) (int, string) {
+	switch strings.ToUpper(level) {
+	case "CRITICAL", "DOWN", "UNREACHABLE":
+		return 17, "ERROR"
+	case "WARNING", "WARN", "UNKNOWN":
+		return 13, "WARN"
+	}
+	return 9, "INFO"
+}
+
+func (b *Batch) resource(host, service string) (resourceKey, Resource) {
+	if service == "" {
+		service = b.hostcheckAlias
+	}
+	return resourceKey{host: host, service: service}, Resource{Attributes: []KeyValue{
+		{Key: "host.name", Value: AnyValue{StringValue: host}},
+		{Key: "service.name", Value: AnyValue{StringValue: service}},
+	}}
+}
+
+func (b *Batch) scopeMetrics(host, service string) *ScopeMetrics {
+	key, resource := b.resource(host, service)
+	index, ok := b.metricResources[key]
+	if !ok {
+		index = len(b.metrics.ResourceMetrics)
+		b.metricResources[key] = index
+		b.metrics.ResourceMetrics = append(b.metrics.ResourceMetrics, ResourceMetrics{
+			Resource:     resource,
+			ScopeMetrics: []ScopeMetrics{{Scope: Scope{Name: ScopeName}, Metrics: []Metric{}}},
+		})
+	}
+	return &b.metrics.ResourceMetrics[index].ScopeMetrics[0]
+}
+
+func (b *Batch) scopeLogs(host, service string) *ScopeLogs {
+	key, resource := b.resource(host, service)
+	index, ok := b.logResources[key]
+	if !ok {
+		index = len(b.logs.ResourceLogs)
+		b.logResources[key] = index
+		b.logs.ResourceLogs = append(b.logs.ResourceLogs, ResourceLogs{
+			Resource:  resource,
+			ScopeLogs: []ScopeLogs{{Scope: Scope{Name: ScopeName}, LogRecords: []LogRecord{}}},
+		})
+	}
+	return &b.logs.ResourceLogs[index].ScopeLogs[0]
+}
@@ -0,0 +1,105 @@
+package otlp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestBatchAddPerformanceData(t *testing.T) {
+	batch := NewBatch("nagflux", "hostcheck")
+	perf := &spoolfile.PerformanceData{
+		Hostname: "host1", Command: "check_ping", PerformanceLabel: "'rta'", Unit: "ms", Time: "1000",
+		Fields: map[string]string{"value": "1.5", "warn": "100", "fill": "none"},
+		Tags:   map[string]string{"site": "a"},
+	}
+	batch.AddPerformanceData(perf)
+	perf.Service = "ping"
+	batch.AddPerformanceData(perf)
+	batch.AddPerformanceData(perf)
+
+	assert.Equal(t, 6, batch.Len())
+	metrics, ok := batch.Metrics()
+	assert.True(t, ok)
+	assert.Len(t, metrics.ResourceMetrics, 2)
+	assert.Equal(t, "hostcheck", metrics.ResourceMetrics[0].Resource.Attributes[1].Value.StringValue)
+	assert.Len(t, metrics.ResourceMetrics[1].ScopeMetrics[0].Metrics, 4)
+
+	metric := metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+	assert.Equal(t, "nagflux.value", metric.Name)
+	assert.Equal(t, "ms", metric.Unit)
+	assert.Equal(t, NumberDataPoint{
+		Attributes: []KeyValue{
+			{Key: "command", Value: AnyValue{StringValue: "check_ping"}},
+			{Key: "performanceLabel", Value: AnyValue{StringValue: "rta"}},
+			{Key: "site", Value: AnyValue{StringValue: "a"}},
+		},
+		TimeUnixNano: 1000000000,
+		AsDouble:     1.5,
+	}, metric.Gauge.DataPoints[0])
+
+	_, ok = batch.Logs()
+	assert.False(t, ok)
+	batch.Reset()
+	assert.Equal(t, 0, batch.Len())
+}
+
+func TestBatchAddEvents(t *testing.T) {
+	batch := NewBatch("nagflux", "hostcheck")
+	batch.AddEvents([]livestatus.Event{
+		{Host: "host1", Author: "admin", Type: "host_notification", Level: "DOWN", Message: "down", Time: "10"},
+		{Host: "host1", Message: "broken", Time: "x"},
+	})
+	logs, ok := batch.Logs()
+	assert.True(t, ok)
+	assert.Equal(t, 1, batch.Len())
+	record := logs.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, uint64(10000000000), record.TimeUnixNano)
+	assert.Equal(t, 17, record.SeverityNumber)
+	assert.Equal(t, "down", record.Body.StringValue)
+
+	encoded, err := json.Marshal(logs)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"timeUnixNano":"10000000000"`)
+	assert.Contains(t, string(encoded), `{"key":"host.name","value":{"stringValue":"host1"}}`)
+}
+
+func TestMarshalProto(t *testing.T) {
+	batch := NewBatch("nagflux", "hostcheck")
+	batch.AddPerformanceData(&spoolfile.PerformanceData{Hostname: "host1", Time: "1", Fields: map[string]string{"value": "1"}})
+	metrics, _ := batch.Metrics()
+
+	resourceMetrics := bytesFields(t, metrics.MarshalProto())[1][0]
+	resource := bytesFields(t, resourceMetrics)[1][0]
+	attribute := bytesFields(t, resource)[1][0]
+	assert.Equal(t, "host.name", string(bytesFields(t, attribute)[1][0]))
+	scopeMetrics := bytesFields(t, resourceMetrics)[2][0]
+	metric := bytesFields(t, scopeMetrics)[2][0]
+	assert.Equal(t, "nagflux.value", string(bytesFields(t, metric)[1][0]))
+	assert.Len(t, bytesFields(t, metric)[5], 1)
+}
+
+// Returns the length delimited fields of the message.
+func bytesFields(t *testing.T, message []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	result := map[protowire.Number][][]byte{}
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		assert.Positive(t, n)
+		message = message[n:]
+		if typ == protowire.BytesType {
+			value, n := protowire.ConsumeBytes(message)
+			result[num] = append(result[num], value)
+			message = message[n:]
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, message)
+			assert.Positive(t, n)
+			message = message[n:]
+		}
+	}
+	return result
+}
@@ -0,0 +1,190 @@
+package otlp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/helper"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
+	"github.com/kdar/factorlog"
+)
+
+// Connector pushes perfdata as metrics and livestatus data as logs to an OTLP/HTTP endpoint, like the OpenTelemetry Collector.
+type Connector struct {
+	endpoint       string
+	healthURL      string
+	encoding       string
+	headers        map[string]string
+	metricPrefix   string
+	hostcheckAlias string
+	dumpFile       string
+	workers        []*Worker
+	workersMutex   sync.Mutex
+	maxWorkers     int
+	jobs           chan collector.Printable
+	quit           chan bool
+	log            *factorlog.FactorLog
+	isAlive        bool
+	httpClient     http.Client
+	target         data.Target
+	retryPolicy    retry.Policy
+	breaker        *retry.Breaker
+}
+
+const (
+	// EncodingProtobuf sends binary protobuf
+	EncodingProtobuf = "protobuf"
+	// EncodingJSON sends OTLP/JSON
+	EncodingJSON = "json"
+	// DefaultMetricPrefix is used if no prefix is configured.
+	DefaultMetricPrefix = "nagflux"
+)
+
+// ConnectorFactory Constructor which will create some workers.
+// endpoint is the base url, the signal paths /v1/metrics and /v1/logs are appended. healthURL can be relative to it or empty to disable the health check.
+// headers are given as "Name: Value", returns nil if the encoding is not supported.
+// Data which couldn't be sent is written to the dumpFile if it's not part of a write-ahead log.
+func ConnectorFactory(jobs chan collector.Printable, endpoint, healthURL, encoding string, headers []string, metricPrefix, hostcheckAlias, dumpFile string,
+	workerAmount, maxWorkers int, target data.Target, clientTimeout int, tlsConfig *tls.Config,
+) *Connector {
+	log := logging.GetLogger()
+	if encoding != EncodingProtobuf && encoding != EncodingJSON {
+		log.Criticalf("OTLP(%s) encoding %s is not supported, use %s or %s", target.Name, encoding, EncodingProtobuf, EncodingJSON)
+		return nil
+	}
+	if metricPrefix == "" {
+		metricPrefix = DefaultMetricPrefix
+	}
+	s := &Connector{
+		endpoint: strings.TrimSuffix(endpoint, "/"), encoding: encoding, headers: map[string]string{},
+		metricPrefix: metricPrefix, hostcheckAlias: hostcheckAlias, dumpFile: nagflux.GenDumpfileName(dumpFile, target),
+		workers: make([]*Worker, workerAmount), maxWorkers: maxWorkers, jobs: jobs, quit: make(chan bool),
+		log: log, isAlive: false,
+		httpClient: http.Client{
+			Timeout:   time.Duration(clientTimeout) * time.Second,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		},
+		target:      target,
+		retryPolicy: retry.PolicyFromConfig(),
+	}
+	s.breaker = retry.NewBreaker(target, s.retryPolicy, s.TestIfIsAlive)
+	for _, header := range headers {
+		name, value, found := strings.Cut(header, ":")
+		if !found {
+			log.Warnf("OTLP(%s) ignoring header %q, expected \"Name: Value\"", target.Name, header)
+			continue
+		}
+		s.headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	// make local uri global
+	if matched, _ := regexp.MatchString("http.*://", healthURL); !matched && healthURL != "" {
+		if u, err := url.Parse(endpoint); err == nil {
+			healthURL = u.Scheme + "://" + u.Host + healthURL
+		}
+	}
+	s.healthURL = healthURL
+
+	if !s.TestIfIsAlive() {
+		s.log.Warnf("OTLP endpoint(%s) is down but starting anyway", target.Name)
+	}
+
+	gen := WorkerGenerator(jobs, s, target)
+	for w := range workerAmount {
+		s.workers[w] = gen(w)
+	}
+	go s.run()
+	return s
+}
+
+// AddWorker creates a new worker
+func (connector *Connector) AddWorker() {
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
+	oldLength := len(connector.workers)
+	if oldLength < connector.maxWorkers {
+		gen := WorkerGenerator(connector.jobs, connector, connector.target)
+		connector.workers = append(connector.workers, gen(oldLength+2))
+		connector.log.Infof("Starting Worker: %d -> %d", oldLength, len(connector.workers))
+	}
+}
+
+// RemoveWorker stops a worker
+func (connector *Connector) RemoveWorker() {
+	connector.workersMutex.Lock()
+	oldLength := len(connector.workers)
+	if oldLength <= 1 {
+		connector.workersMutex.Unlock()
+		return
+	}
+	// the worker is detached under the lock and stopped afterwards, stopping blocks until its data is sent
+	lastWorkerIndex := oldLength - 1
+	worker := connector.workers[lastWorkerIndex]
+	connector.workers = connector.workers[:lastWorkerIndex]
+	connector.workersMutex.Unlock()
+	connector.log.Infof("Stopping Worker: %d -> %d", oldLength, lastWorkerIndex)
+	worker.Stop()
+}
+
+// AmountWorkers current amount of workers.
+func (connector *Connector) AmountWorkers() int {
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
+	return len(connector.workers)
+}
+
+// Flush makes the workers send their buffered data.
+func (connector *Connector) Flush() {
+	connector.workersMutex.Lock()
+	defer connector.workersMutex.Unlock()
+	for _, worker := range connector.workers {
+		worker.Flush()
+	}
+}
+
+// IsAlive is the endpoint alive.
+func (connector *Connector) IsAlive() bool {
+	return connector.isAlive
+}
+
+// Stop the connector and its workers.
+func (connector *Connector) Stop() {
+	connector.quit <- true
+	<-connector.quit
+	connector.log.Debug("OTLPConnectorFactory stopped")
+}
+
+// Waits just for the end.
+func (connector *Connector) run() {
+	<-connector.quit
+	connector.workersMutex.Lock()
+	workers := connector.workers
+	connector.workers = nil
+	connector.workersMutex.Unlock()
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Go(worker.Stop)
+	}
+	wg.Wait()
+	connector.quit <- true
+}
+
+// TestIfIsAlive test active if the endpoint is alive, without health url it's always alive.
+func (connector *Connector) TestIfIsAlive() bool {
+	result := true
+	if connector.healthURL != "" {
+		result = helper.RequestedReturnCodeIsOK(connector.httpClient, connector.healthURL, "GET")
+	}
+	connector.isAlive = result
+	connector.log.Infof("Is OTLP endpoint(%s) running: %t", connector.target.Name, result)
+	return result
+}
@@ -0,0 +1,203 @@
+package otlp
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The types mirror the OTLP protobuf messages, the json tags follow the OTLP/JSON mapping.
+
+// AnyValue only supports strings, which is all nagflux needs.
+type AnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// KeyValue is a single attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// Resource describes the entity which produced the data.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// Scope is the instrumentation scope.
+type Scope struct {
+	Name string `json:"name"`
+}
+
+// NumberDataPoint is a single value of a gauge.
+type NumberDataPoint struct {
+	Attributes   []KeyValue `json:"attributes,omitempty"`
+	TimeUnixNano uint64     `json:"timeUnixNano,string"`
+	AsDouble     float64    `json:"asDouble"`
+}
+
+// Gauge contains the data points of a metric.
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+// Metric is a named gauge.
+type Metric struct {
+	Name  string `json:"name"`
+	Unit  string `json:"unit,omitempty"`
+	Gauge Gauge  `json:"gauge"`
+}
+
+// ScopeMetrics groups the metrics of a scope.
+type ScopeMetrics struct {
+	Scope   Scope    `json:"scope"`
+	Metrics []Metric `json:"metrics"`
+}
+
+// ResourceMetrics groups the metrics of a resource.
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+// MetricsRequest is the ExportMetricsServiceRequest.
+type MetricsRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+// LogRecord is a single log entry.
+type LogRecord struct {
+	TimeUnixNano         uint64     `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano uint64     `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 AnyValue   `json:"body"`
+	Attributes           []KeyValue `json:"attributes,omitempty"`
+}
+
+// ScopeLogs groups the log records of a scope.
+type ScopeLogs struct {
+	Scope      Scope       `json:"scope"`
+	LogRecords []LogRecord `json:"logRecords"`
+}
+
+// ResourceLogs groups the logs of a resource.
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+}
+
+// LogsRequest is the ExportLogsServiceRequest.
+type LogsRequest struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+// MarshalProto encodes the request in the protobuf wire format.
+func (r MetricsRequest) MarshalProto() []byte {
+	var b []byte
+	for _, rm := range r.ResourceMetrics {
+		var rmb []byte
+		rmb = appendMessage(rmb, 1, rm.Resource.marshal())
+		for _, sm := range rm.ScopeMetrics {
+			var smb []byte
+			smb = appendMessage(smb, 1, sm.Scope.marshal())
+			for _, m := range sm.Metrics {
+				smb = appendMessage(smb, 2, m.marshal())
+			}
+			rmb = appendMessage(rmb, 2, smb)
+		}
+		b = appendMessage(b, 1, rmb)
+	}
+	return b
+}
+
+// MarshalProto encodes the request in the protobuf wire format.
+func (r LogsRequest) MarshalProto() []byte {
+	var b []byte
+	for _, rl := range r.ResourceLogs {
+		var rlb []byte
+		rlb = appendMessage(rlb, 1, rl.Resource.marshal())
+		for _, sl := range rl.ScopeLogs {
+			var slb []byte
+			slb = appendMessage(slb, 1, sl.Scope.marshal())
+			for _, l := range sl.LogRecords {
+				slb = appendMessage(slb, 2, l.marshal())
+			}
+			rlb = appendMessage(rlb, 2, slb)
+		}
+		b = appendMessage(b, 1, rlb)
+	}
+	return b
+}
+
+func (v AnyValue) marshal() []byte {
+	return appendString(nil, 1, v.StringValue)
+}
+
+func (kv KeyValue) marshal() []byte {
+	b := appendString(nil, 1, kv.Key)
+	return appendMessage(b, 2, kv.Value.marshal())
+}
+
+func (r Resource) marshal() []byte {
+	return appendAttributes(nil, 1, r.Attributes)
+}
+
+func (s Scope) marshal() []byte {
+	return appendString(nil, 1, s.Name)
+}
+
+func (p NumberDataPoint) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 3, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, p.TimeUnixNano)
+	b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(p.AsDouble))
+	return appendAttributes(b, 7, p.Attributes)
+}
+
+func (m Metric) marshal() []byte {
+	b := appendString(nil, 1, m.Name)
+	b = appendString(b, 3, m.Unit)
+	var gauge []byte
+	for _, p := range m.Gauge.DataPoints {
+		gauge = appendMessage(gauge, 1, p.marshal())
+	}
+	return appendMessage(b, 5, gauge)
+}
+
+func (l LogRecord) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, l.TimeUnixNano)
+	if l.SeverityNumber != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(l.SeverityNumber))
+	}
+	b = appendString(b, 3, l.SeverityText)
+	b = appendMessage(b, 5, l.Body.marshal())
+	b = appendAttributes(b, 6, l.Attributes)
+	b = protowire.AppendTag(b, 11, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, l.ObservedTimeUnixNano)
+}
+
+func appendAttributes(b []byte, num protowire.Number, attributes []KeyValue) []byte {
+	for _, kv := range attributes {
+		b = appendMessage(b, num, kv.marshal())
+	}
+	return b
+}
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+// Empty strings are the default value and are not encoded.
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
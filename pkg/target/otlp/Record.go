@@ -0,0 +1,77 @@
+package otlp
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+)
+
+// record is a line of the write-ahead log or the dumpfile of an OTLP target, it holds either perfdata or events.
+type record struct {
+	PerformanceData *spoolfile.PerformanceData `json:"perfdata,omitempty"`
+	Events          []livestatus.Event         `json:"events,omitempty"`
+}
+
+func (r record) marshal() string {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return ""
+	}
+	return string(line) + "\n"
+}
+
+// RenderRecord renders perfdata and events as JSON lines, Printables which were already rendered for the datatype
+// are passed through and everything else is skipped by an empty string.
+func RenderRecord(p collector.Printable, datatype data.Datatype) string {
+	switch printable := p.(type) {
+	case *spoolfile.PerformanceData:
+		return record{PerformanceData: printable}.marshal()
+	case EventSource:
+		if events := printable.Events(); len(events) > 0 {
+			return record{Events: events}.marshal()
+		}
+	case collector.Rendered:
+		if text, ok := printable.RenderedText(datatype); ok {
+			return text
+		}
+	}
+	return ""
+}
+
+// Returns the records of the Printable, rendered Printables are decoded. Lines which can't be decoded are returned as error.
+func recordsOf(p collector.Printable, datatype data.Datatype) ([]record, error) {
+	switch printable := p.(type) {
+	case *spoolfile.PerformanceData:
+		return []record{{PerformanceData: printable}}, nil
+	case EventSource:
+		return []record{{Events: printable.Events()}}, nil
+	case collector.Rendered:
+		text, ok := printable.RenderedText(datatype)
+		if !ok {
+			return nil, nil
+		}
+		var records []record
+		var errs []error
+		for line := range strings.Lines(text) {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			var r record
+			if err := json.Unmarshal([]byte(line), &r); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if r.PerformanceData != nil {
+				r.PerformanceData.Filterable = collector.AllFilterable
+			}
+			records = append(records, r)
+		}
+		return records, errors.Join(errs...)
+	}
+	return nil, nil
+}
@@ -0,0 +1,275 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
+	"github.com/kdar/factorlog"
+)
+
+// Worker reads data from the queue and exports them as OTLP requests.
+type Worker struct {
+	workerID     int
+	quit         chan bool
+	quitInternal chan bool
+	flush        chan bool
+	jobs         chan collector.Printable
+	log          *factorlog.FactorLog
+	connector    *Connector
+	httpClient   http.Client
+	IsRunning    bool
+	promServer   statistics.PrometheusServer
+	target       data.Target
+}
+
+const (
+	dataTimeout = time.Duration(5) * time.Second
+	// amount of data points and log records which are sent at once
+	maxItemsPerRequest = 2000
+	metricsPath        = "/v1/metrics"
+	logsPath           = "/v1/logs"
+)
+
+var (
+	errorInterrupted  = errors.New("got interrupted")
+	errorHTTPClient   = errors.New("http Client got an error")
+	errorRejected     = errors.New("request was rejected")
+	errorFailedToSend = errors.New("could not send data")
+)
+
+// WorkerGenerator generates a new Worker and starts it.
+func WorkerGenerator(jobs chan collector.Printable, connector *Connector, target data.Target) func(workerId int) *Worker {
+	return func(workerId int) *Worker {
+		worker := &Worker{
+			workerID: workerId, quit: make(chan bool),
+			quitInternal: make(chan bool, 1), flush: make(chan bool, 1), jobs: jobs,
+			log:       logging.GetLogger(),
+			connector: connector, httpClient: connector.httpClient, IsRunning: true,
+			promServer: statistics.GetPrometheusServer(), target: target,
+		}
+		go worker.run()
+		return worker
+	}
+}
+
+// Stop stops the worker
+func (worker *Worker) Stop() {
+	worker.quitInternal <- true
+	worker.quit <- true
+	<-worker.quit
+	worker.IsRunning = false
+	worker.log.Debug("OTLPWorker(" + worker.target.Name + ") stopped")
+}
+
+// Flush makes the worker send its buffered data, it does not wait for it.
+func (worker *Worker) Flush() {
+	select {
+	case worker.flush <- true:
+	default:
+	}
+}
+
+// An entry of a batch, to acknowledge or keep it after the request.
+type item struct {
+	// the query of the write-ahead log, nil for data without acknowledgement
+	query collector.Printable
+	// the record which is dumped if the data without acknowledgement couldn't be sent
+	line string
+}
+
+// Tries to send data all the time.
+func (worker *Worker) run() {
+	batch := NewBatch(worker.connector.metricPrefix, worker.connector.hostcheckAlias)
+	// the entries of the metrics and the logs request
+	var metricItems, logItems []item
+	send := func() {
+		worker.sendBatch(batch, metricItems, logItems)
+		metricItems, logItems = metricItems[:0], logItems[:0]
+	}
+	for {
+		select {
+		case <-worker.quit:
+			worker.log.Debug("OTLPWorker(" + worker.target.Name + ") quitting...")
+			send()
+			worker.quit <- true
+			return
+		case query := <-worker.jobs:
+			if !query.TestTargetFilter(worker.target.Name) {
+				continue
+			}
+			records, err := recordsOf(query, worker.target.Datatype)
+			if err != nil {
+				worker.log.Warnf("OTLP(%s) skipping records which can't be decoded: %s", worker.target.Name, err)
+			}
+			_, acknowledged := query.(collector.Acknowledger)
+			hasMetrics, hasLogs := false, false
+			for _, r := range records {
+				if r.PerformanceData != nil {
+					batch.AddPerformanceData(r.PerformanceData)
+					hasMetrics = true
+					if !acknowledged {
+						metricItems = append(metricItems, item{line: r.marshal()})
+					}
+				} else if len(r.Events) > 0 {
+					batch.AddEvents(r.Events)
+					hasLogs = true
+					if !acknowledged {
+						logItems = append(logItems, item{line: r.marshal()})
+					}
+				}
+			}
+			if acknowledged {
+				if hasLogs {
+					logItems = append(logItems, item{query: query})
+				}
+				// a record without data is acknowledged with the metrics
+				if hasMetrics || !hasLogs {
+					metricItems = append(metricItems, item{query: query})
+				}
+			}
+			if batch.Len() >= maxItemsPerRequest {
+				send()
+			}
+		case <-worker.flush:
+			send()
+		case <-time.After(dataTimeout):
+			send()
+		}
+	}
+}
+
+// Sends the metrics and logs of the batch and resets it. The entries of a request which couldn't be sent
+// are handed back to the write-ahead log or dumped, the others are acknowledged.
+func (worker *Worker) sendBatch(batch *Batch, metricItems, logItems []item) {
+	var failed []item
+	if metrics, ok := batch.Metrics(); ok && !worker.sendRequest(metricsPath, metrics, metrics.MarshalProto) {
+		failed = append(failed, metricItems...)
+	}
+	if logs, ok := batch.Logs(); ok && !worker.sendRequest(logsPath, logs, logs.MarshalProto) {
+		failed = append(failed, logItems...)
+	}
+	batch.Reset()
+
+	kept := map[collector.Printable]bool{}
+	var dumpLines []string
+	for _, entry := range failed {
+		switch {
+		case entry.query == nil:
+			dumpLines = append(dumpLines, entry.line)
+		case !kept[entry.query]:
+			kept[entry.query] = true
+			entry.query.(collector.Acknowledger).Nack()
+		}
+	}
+	acked := map[collector.Printable]bool{}
+	for _, entry := range append(metricItems, logItems...) {
+		if entry.query != nil && !kept[entry.query] && !acked[entry.query] {
+			acked[entry.query] = true
+			entry.query.(collector.Acknowledger).Ack()
+		}
+	}
+	if len(dumpLines) > 0 {
+		worker.log.Infof("Dumping data which couldn't be sent to: %s", worker.connector.dumpFile)
+		if err := nagflux.AppendDumpfile(worker.connector.dumpFile, dumpLines); err != nil {
+			worker.log.Critical(err)
+		}
+	}
+}
+
+// Encodes and sends the request, retries by the retry policy if the endpoint is not reachable.
+// Returns false if the request should be sent again later, rejected requests are dropped.
+func (worker *Worker) sendRequest(path string, request any, marshalProto func() []byte) bool {
+	var dataToSend []byte
+	if worker.connector.encoding == EncodingJSON {
+		var err error
+		if dataToSend, err = json.Marshal(request); err != nil {
+			worker.log.Warnf("OTLP(%s) could not encode request: %s", worker.target.Name, err)
+			return true
+		}
+	} else {
+		dataToSend = marshalProto()
+	}
+
+	startTime := time.Now()
+	sendErr := worker.connector.retryPolicy.Do(worker.connector.breaker, worker.waitForQuitOrGoOn, func() error {
+		if err := worker.sendData(path, dataToSend); err != errorRejected {
+			return err
+		}
+		return retry.Permanent(errorRejected)
+	})
+	worker.promServer.SendLatency.WithLabelValues(worker.target.String()).Observe(time.Since(startTime).Seconds())
+	worker.promServer.BytesSend.WithLabelValues("OTLP").Add(float64(len(dataToSend)))
+	timeDiff := float64(time.Since(startTime).Seconds() * 1000)
+	if timeDiff >= 0 {
+		worker.promServer.SendDuration.WithLabelValues("OTLP").Add(timeDiff)
+	}
+	switch {
+	case sendErr == nil:
+		return true
+	case errors.Is(sendErr, errorRejected):
+		// sending it again would be rejected as well
+		worker.log.Warnf("OTLP(%s) dropping request to %s which was rejected", worker.target.Name, path)
+		return true
+	}
+	worker.log.Warnf("OTLP(%s) keeping request to %s which couldn't be sent: %s", worker.target.Name, path, sendErr)
+	return false
+}
+
+// Sends the encoded request and returns an err if given.
+func (worker *Worker) sendData(path string, rawData []byte) error {
+	req, err := http.NewRequest(http.MethodPost, worker.connector.endpoint+path, bytes.NewBuffer(rawData))
+	if err != nil {
+		worker.log.Warn(err)
+		return errorHTTPClient
+	}
+	req.Header.Set("User-Agent", "Nagflux")
+	if worker.connector.encoding == EncodingJSON {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	}
+	for name, value := range worker.connector.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := worker.httpClient.Do(req)
+	if err != nil {
+		worker.log.Warn(err)
+		return errorHTTPClient
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	worker.log.Warnf("OTLP(%s) status: %s - %s", worker.target.Name, resp.Status, string(body))
+	// the spec only allows to retry on these status codes
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return errorFailedToSend
+	}
+	return errorRejected
+}
+
+// Waits on an internal quit signal or the given backoff.
+func (worker *Worker) waitForQuitOrGoOn(backoff time.Duration) error {
+	select {
+	// Got stop signal
+	case <-worker.quitInternal:
+		worker.log.Debug("Received quit")
+		worker.quitInternal <- true
+		return errorInterrupted
+	// Timeout and retry
+	case <-time.After(backoff):
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package otlp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/livestatus"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitTestLogger()
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+type testEvents struct {
+	collector.SimplePrintable
+	events []livestatus.Event
+}
+
+func (e *testEvents) Events() []livestatus.Event { return e.events }
+
+// a Printable of a write-ahead log
+type testRecord struct {
+	*spoolfile.PerformanceData
+	acked, nacked atomic.Int32
+}
+
+func (r *testRecord) Ack()  { r.acked.Add(1) }
+func (r *testRecord) Nack() { r.nacked.Add(1) }
+
+func TestWorkerKeepsUnsentRequests(t *testing.T) {
+	var logsStatus atomic.Int32
+	logsStatus.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == logsPath {
+			w.WriteHeader(int(logsStatus.Load()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	target := data.Target{Name: "otlp", Datatype: data.OTLP}
+	jobs := make(chan collector.Printable)
+	connector := &Connector{
+		endpoint: server.URL, encoding: EncodingJSON, metricPrefix: DefaultMetricPrefix,
+		dumpFile: nagflux.GenDumpfileName(filepath.Join(t.TempDir(), "nagflux.dump"), target),
+		jobs:     jobs, log: logging.GetLogger(), target: target, retryPolicy: retry.Policy{MaxAttempts: 1},
+	}
+	worker := WorkerGenerator(jobs, connector, target)(0)
+	defer worker.Stop()
+
+	walRecord := &testRecord{PerformanceData: &spoolfile.PerformanceData{
+		Filterable: collector.AllFilterable, Hostname: "host", Service: "load", Command: "check_load",
+		PerformanceLabel: "load1", Time: "1441791000000", Fields: map[string]string{"value": "1.0"},
+	}}
+	events := &testEvents{
+		SimplePrintable: collector.SimplePrintable{Filterable: collector.AllFilterable},
+		events:          []livestatus.Event{{Host: "host", Type: "host_notification", Level: "DOWN", Message: "down", Time: "10"}},
+	}
+	jobs <- walRecord
+	jobs <- events
+	worker.Flush()
+	// the metrics were sent, only the logs are kept
+	assert.Eventually(t, func() bool { return walRecord.acked.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, walRecord.nacked.Load())
+	var content []byte
+	assert.Eventually(t, func() bool {
+		content, _ = os.ReadFile(connector.dumpFile)
+		return len(content) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, RenderRecord(events, data.OTLP), string(content))
+
+	records, err := recordsOf(&collector.SimplePrintable{Text: string(content), Datatype: data.OTLP}, data.OTLP)
+	require.NoError(t, err)
+	assert.Equal(t, []record{{Events: events.events}}, records)
+}
@@ -0,0 +1,218 @@
+package prometheus
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
+	"github.com/kdar/factorlog"
+	"github.com/klauspost/compress/s2"
+)
+
+// Worker reads data from the queue and sends them as remote_write requests.
+type Worker struct {
+	workerID     int
+	quit         chan bool
+	quitInternal chan bool
+	flush        chan bool
+	jobs         chan collector.Printable
+	connection   string
+	log          *factorlog.FactorLog
+	connector    *Connector
+	httpClient   http.Client
+	IsRunning    bool
+	promServer   statistics.PrometheusServer
+	target       data.Target
+}
+
+const (
+	dataTimeout = time.Duration(5) * time.Second
+	// amount of series which are sent within one request
+	maxSeriesPerRequest = 2000
+)
+
+var (
+	errorInterrupted  = errors.New("got interrupted")
+	errorHTTPClient   = errors.New("http Client got an error")
+	errorRejected     = errors.New("request was rejected")
+	errorFailedToSend = errors.New("could not send data")
+)
+
+// WorkerGenerator generates a new Worker and starts it.
+func WorkerGenerator(jobs chan collector.Printable, connection string, connector *Connector, target data.Target) func(workerId int) *Worker {
+	return func(workerId int) *Worker {
+		worker := &Worker{
+			workerID: workerId, quit: make(chan bool),
+			quitInternal: make(chan bool, 1), flush: make(chan bool, 1), jobs: jobs,
+			connection: connection, log: logging.GetLogger(),
+			connector: connector, httpClient: connector.httpClient, IsRunning: true,
+			promServer: statistics.GetPrometheusServer(), target: target,
+		}
+		go worker.run()
+		return worker
+	}
+}
+
+// Stop stops the worker
+func (worker *Worker) Stop() {
+	worker.quitInternal <- true
+	worker.quit <- true
+	<-worker.quit
+	worker.IsRunning = false
+	worker.log.Debug("PrometheusWorker(" + worker.target.Name + ") stopped")
+}
+
+// Flush makes the worker send its buffered data, it does not wait for it.
+func (worker *Worker) Flush() {
+	select {
+	case worker.flush <- true:
+	default:
+	}
+}
+
+// Tries to send data all the time.
+func (worker *Worker) run() {
+	// the queries the series were created of, to acknowledge or keep them
+	var queries []collector.Printable
+	var series []TimeSeries
+	send := func() {
+		worker.sendBuffer(queries, series)
+		queries, series = queries[:0], series[:0]
+	}
+	for {
+		select {
+		case <-worker.quit:
+			worker.log.Debug("PrometheusWorker(" + worker.target.Name + ") quitting...")
+			send()
+			worker.quit <- true
+			return
+		case query := <-worker.jobs:
+			if !query.TestTargetFilter(worker.target.Name) {
+				continue
+			}
+			// only perfdata can be converted to series
+			perfs, err := spoolfile.PerformanceDataFor(query, worker.target.Datatype)
+			if err != nil {
+				worker.log.Warnf("Prometheus(%s) skipping records which can't be decoded: %s", worker.target.Name, err)
+			}
+			if _, ok := query.(collector.Acknowledger); !ok && len(perfs) == 0 {
+				continue
+			}
+			queries = append(queries, query)
+			for _, perf := range perfs {
+				series = append(series, PerformanceDataToTimeSeries(perf, worker.connector.metricPrefix, worker.connector.hostcheckAlias)...)
+			}
+			if len(series) >= maxSeriesPerRequest {
+				send()
+			}
+		case <-worker.flush:
+			send()
+		case <-time.After(dataTimeout):
+			send()
+		}
+	}
+}
+
+// Sends the series of the given queries, retries by the retry policy if the endpoint is not reachable.
+// If they can't be sent, the queries are handed back to the write-ahead log or dumped.
+func (worker *Worker) sendBuffer(queries []collector.Printable, series []TimeSeries) {
+	if len(series) == 0 {
+		collector.AckAll(queries)
+		return
+	}
+	dataToSend := s2.EncodeSnappy(nil, EncodeWriteRequest(series))
+
+	startTime := time.Now()
+	sendErr := worker.connector.retryPolicy.Do(worker.connector.breaker, worker.waitForQuitOrGoOn, func() error {
+		if err := worker.sendData(dataToSend); err != errorRejected {
+			return err
+		}
+		return retry.Permanent(errorRejected)
+	})
+	switch {
+	case sendErr == nil:
+		collector.AckAll(queries)
+	case errors.Is(sendErr, errorRejected):
+		// sending them again would be rejected as well
+		worker.log.Warnf("Prometheus(%s) dropping %d series which were rejected", worker.target.Name, len(series))
+		collector.AckAll(queries)
+	default:
+		worker.log.Warnf("Prometheus(%s) keeping %d series which couldn't be sent: %s", worker.target.Name, len(series), sendErr)
+		worker.keep(queries)
+	}
+	worker.promServer.SendLatency.WithLabelValues(worker.target.String()).Observe(time.Since(startTime).Seconds())
+	worker.promServer.BytesSend.WithLabelValues("Prometheus").Add(float64(len(dataToSend)))
+	timeDiff := float64(time.Since(startTime).Seconds() * 1000)
+	if timeDiff >= 0 {
+		worker.promServer.SendDuration.WithLabelValues("Prometheus").Add(timeDiff)
+	}
+}
+
+// Hands the queries back to the write-ahead log, the others are written to the dumpfile.
+func (worker *Worker) keep(queries []collector.Printable) {
+	lines := make([]string, len(queries))
+	for i, query := range queries {
+		lines[i] = spoolfile.RenderRecord(query, worker.target.Datatype)
+	}
+	if dumpQueries := collector.NackAll(queries, lines); len(dumpQueries) > 0 {
+		worker.log.Infof("Dumping series which couldn't be sent to: %s", worker.connector.dumpFile)
+		if err := nagflux.AppendDumpfile(worker.connector.dumpFile, dumpQueries); err != nil {
+			worker.log.Critical(err)
+		}
+	}
+}
+
+// Sends the compressed request and returns an err if given.
+func (worker *Worker) sendData(rawData []byte) error {
+	req, err := http.NewRequest(http.MethodPost, worker.connection, bytes.NewBuffer(rawData))
+	if err != nil {
+		worker.log.Warn(err)
+		return errorHTTPClient
+	}
+	req.Header.Set("User-Agent", "Nagflux")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if worker.connector.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+worker.connector.authToken)
+	}
+	resp, err := worker.httpClient.Do(req)
+	if err != nil {
+		worker.log.Warn(err)
+		return errorHTTPClient
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	worker.log.Warnf("Prometheus(%s) status: %s - %s", worker.target.Name, resp.Status, string(body))
+	// the spec demands to not retry client errors, except of rate limiting
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return errorRejected
+	}
+	return errorFailedToSend
+}
+
+// Waits on an internal quit signal or the given backoff.
+func (worker *Worker) waitForQuitOrGoOn(backoff time.Duration) error {
+	select {
+	// Got stop signal
+	case <-worker.quitInternal:
+		worker.log.Debug("Received quit")
+		worker.quitInternal <- true
+		return errorInterrupted
+	// Timeout and retry
+	case <-time.After(backoff):
+		return nil
+	}
+}
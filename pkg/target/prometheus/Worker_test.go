@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/nagflux"
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/ConSol-Monitoring/nagflux/pkg/target/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitTestLogger()
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+// a Printable of a write-ahead log
+type testRecord struct {
+	*spoolfile.PerformanceData
+	acked, nacked atomic.Int32
+}
+
+func (r *testRecord) Ack()  { r.acked.Add(1) }
+func (r *testRecord) Nack() { r.nacked.Add(1) }
+
+func TestWorkerKeepsUnsentData(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	target := data.Target{Name: "prom", Datatype: data.Prometheus}
+	jobs := make(chan collector.Printable)
+	connector := &Connector{
+		metricPrefix: DefaultMetricPrefix, dumpFile: nagflux.GenDumpfileName(filepath.Join(t.TempDir(), "nagflux.dump"), target),
+		jobs: jobs, log: logging.GetLogger(), target: target, retryPolicy: retry.Policy{MaxAttempts: 1},
+	}
+	worker := WorkerGenerator(jobs, server.URL, connector, target)(0)
+	defer worker.Stop()
+
+	perf := &spoolfile.PerformanceData{
+		Filterable: collector.AllFilterable, Hostname: "host", Service: "load", Command: "check_load",
+		PerformanceLabel: "load1", Time: "1441791000000", Fields: map[string]string{"value": "1.0"},
+	}
+	record := &testRecord{PerformanceData: perf}
+	jobs <- perf
+	jobs <- record
+	worker.Flush()
+	assert.Eventually(t, func() bool { return record.nacked.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, record.acked.Load())
+	content, err := os.ReadFile(connector.dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, perf.MarshalRecord(), string(content), "only the data without write-ahead log is dumped")
+
+	// the dumpfile is replayed on the next start
+	status.Store(http.StatusNoContent)
+	requests.Store(0)
+	jobs <- &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: string(content), Datatype: data.Prometheus}
+	jobs <- record
+	worker.Flush()
+	assert.Eventually(t, func() bool { return record.acked.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), requests.Load())
+}
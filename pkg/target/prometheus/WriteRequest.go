@@ -0,0 +1,112 @@
+package prometheus
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Label is a name value pair of a series.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// TimeSeries is a single sample with its labels, as it is sent by remote_write.
+type TimeSeries struct {
+	Labels    []Label
+	Value     float64
+	Timestamp int64
+}
+
+// PerformanceDataToTimeSeries creates one series per numeric field of the perfdata, like value, warn, crit, min and max.
+// The metric name is the prefix followed by the field, host, service, command and performanceLabel are added as labels as well as the tags.
+func PerformanceDataToTimeSeries(p *spoolfile.PerformanceData, prefix, hostcheckAlias string) []TimeSeries {
+	timestamp, err := strconv.ParseInt(p.Time, 10, 64)
+	if err != nil {
+		return nil
+	}
+	labels := map[string]string{}
+	for k, v := range p.Tags {
+		labels[SanitizeLabelName(k)] = v
+	}
+	if p.Unit != "" {
+		labels["unit"] = p.Unit
+	}
+	labels["host"] = p.Hostname
+	labels["service"] = p.Service
+	if p.Service == "" {
+		labels["service"] = hostcheckAlias
+	}
+	labels["command"] = p.Command
+	labels["performanceLabel"] = p.PerformanceLabel
+
+	fields := make([]string, 0, len(p.Fields))
+	for field := range p.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	result := []TimeSeries{}
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(p.Fields[field], 64)
+		if err != nil {
+			continue
+		}
+		series := TimeSeries{Value: value, Timestamp: timestamp}
+		series.Labels = append(series.Labels, Label{Name: "__name__", Value: SanitizeLabelName(prefix + "_" + field)})
+		for k, v := range labels {
+			series.Labels = append(series.Labels, Label{Name: k, Value: v})
+		}
+		// remote_write requires sorted labels
+		sort.Slice(series.Labels, func(i, j int) bool { return series.Labels[i].Name < series.Labels[j].Name })
+		result = append(result, series)
+	}
+	return result
+}
+
+// SanitizeLabelName replaces every char which is not allowed in a Prometheus label or metric name by an underscore.
+func SanitizeLabelName(name string) string {
+	result := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	if result == "" || (result[0] >= '0' && result[0] <= '9') {
+		result = "_" + result
+	}
+	return result
+}
+
+// EncodeWriteRequest encodes the series as protobuf prometheus.WriteRequest.
+func EncodeWriteRequest(series []TimeSeries) []byte {
+	var request []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.Labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l.Name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l.Value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.Value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.Timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, ts)
+	}
+	return request
+}
@@ -0,0 +1,77 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector/spoolfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestPerformanceDataToTimeSeries(t *testing.T) {
+	perf := &spoolfile.PerformanceData{
+		Hostname:         "host1",
+		Service:          "",
+		Command:          "check_ping",
+		PerformanceLabel: "rta",
+		Unit:             "ms",
+		Time:             "1441791000000",
+		Tags:             map[string]string{"warn-fill": "none"},
+		Fields:           map[string]string{"value": "0.024", "warn": "3000.0", "unknown": "true"},
+	}
+	series := PerformanceDataToTimeSeries(perf, "nagflux", "hostcheck")
+	require.Len(t, series, 2)
+
+	assert.Equal(t, []Label{
+		{Name: "__name__", Value: "nagflux_value"},
+		{Name: "command", Value: "check_ping"},
+		{Name: "host", Value: "host1"},
+		{Name: "performanceLabel", Value: "rta"},
+		{Name: "service", Value: "hostcheck"},
+		{Name: "unit", Value: "ms"},
+		{Name: "warn_fill", Value: "none"},
+	}, series[0].Labels)
+	assert.InDelta(t, 0.024, series[0].Value, 0.0001)
+	assert.Equal(t, int64(1441791000000), series[0].Timestamp)
+	assert.Equal(t, "nagflux_warn", series[1].Labels[0].Value)
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	assert.Equal(t, "warn_min", SanitizeLabelName("warn-min"))
+	assert.Equal(t, "_1st", SanitizeLabelName("1st"))
+	assert.Equal(t, "_", SanitizeLabelName(""))
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	encoded := EncodeWriteRequest([]TimeSeries{{Labels: []Label{{Name: "__name__", Value: "up"}}, Value: 1.5, Timestamp: 42}})
+
+	num, typ, n := protowire.ConsumeTag(encoded)
+	require.Positive(t, n)
+	assert.Equal(t, protowire.Number(1), num)
+	assert.Equal(t, protowire.BytesType, typ)
+	series, n := protowire.ConsumeBytes(encoded[n:])
+	require.Positive(t, n)
+
+	// label
+	_, _, n = protowire.ConsumeTag(series)
+	label, m := protowire.ConsumeBytes(series[n:])
+	require.Positive(t, m)
+	series = series[n+m:]
+	_, _, n = protowire.ConsumeTag(label)
+	name, _ := protowire.ConsumeString(label[n:])
+	assert.Equal(t, "__name__", name)
+
+	// sample
+	num, _, n = protowire.ConsumeTag(series)
+	assert.Equal(t, protowire.Number(2), num)
+	sample, _ := protowire.ConsumeBytes(series[n:])
+	_, _, n = protowire.ConsumeTag(sample)
+	value, m := protowire.ConsumeFixed64(sample[n:])
+	assert.InDelta(t, 1.5, math.Float64frombits(value), 0.0001)
+	sample = sample[n+m:]
+	_, _, n = protowire.ConsumeTag(sample)
+	timestamp, _ := protowire.ConsumeVarint(sample[n:])
+	assert.Equal(t, uint64(42), timestamp)
+}
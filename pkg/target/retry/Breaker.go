@@ -0,0 +1,143 @@
+package retry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/kdar/factorlog"
+)
+
+// State of the circuit breaker, the value is exported as metric.
+type State int
+
+const (
+	// Closed lets every request pass
+	Closed State = iota
+	// HalfOpen lets a single request pass, after the probe succeeded
+	HalfOpen
+	// Open rejects every request until the cooldown is over
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "open"
+}
+
+// Breaker is a circuit breaker shared by all workers of a target.
+type Breaker struct {
+	mutex     sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	threshold int
+	cooldown  time.Duration
+	probe     func() bool
+	target    data.Target
+	log       *factorlog.FactorLog
+	now       func() time.Time
+}
+
+// NewBreaker creates a closed breaker. The probe is called before the breaker half-opens, usually the TestIfIsAlive of the connector.
+// The probe can be nil.
+func NewBreaker(target data.Target, policy Policy, probe func() bool) *Breaker {
+	b := &Breaker{
+		threshold: policy.BreakerThreshold,
+		cooldown:  policy.BreakerCooldown,
+		probe:     probe,
+		target:    target,
+		log:       logging.GetLogger(),
+		now:       time.Now,
+	}
+	b.setState(Closed)
+	return b
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// Allow returns true if a request may be sent. After the cooldown the probe is called, if it succeeds a single request is allowed.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mutex.Lock()
+	switch {
+	case b.state == Closed:
+		b.mutex.Unlock()
+		return true
+	case b.state == HalfOpen || b.probing || b.now().Sub(b.openedAt) < b.cooldown:
+		// there is already a request on its way
+		b.mutex.Unlock()
+		return false
+	}
+	b.probing = true
+	b.mutex.Unlock()
+
+	alive := b.probe == nil || b.probe()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+	if !alive {
+		b.open()
+		return false
+	}
+	b.setState(HalfOpen)
+	return true
+}
+
+// Success closes the breaker.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures = 0
+	if b.state != Closed {
+		b.log.Infof("Circuit breaker of %s closed", b.target)
+		b.setState(Closed)
+	}
+}
+
+// Failure opens the breaker if the threshold is reached or the request of the half-open breaker failed.
+func (b *Breaker) Failure() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures++
+	if b.state == HalfOpen || (b.threshold > 0 && b.state == Closed && b.failures >= b.threshold) {
+		b.log.Warnf("Circuit breaker of %s opened after %d failures", b.target, b.failures)
+		b.open()
+	}
+}
+
+func (b *Breaker) open() {
+	b.failures = 0
+	b.openedAt = b.now()
+	b.setState(Open)
+}
+
+func (b *Breaker) setState(state State) {
+	b.state = state
+	statistics.GetPrometheusServer().CircuitBreakerState.WithLabelValues(b.target.String()).Set(float64(state))
+}
@@ -0,0 +1,132 @@
+package retry
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/config"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+)
+
+// Policy describes how often and how long the workers of a target retry to send data.
+type Policy struct {
+	// MaxAttempts is the amount of tries including the first one
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction of the backoff which is randomized, 0.2 means +-20%
+	Jitter float64
+	// BreakerThreshold is the amount of consecutive failures which opens the circuit breaker, 0 disables it
+	BreakerThreshold int
+	// BreakerCooldown is the time the breaker stays open before it probes the target
+	BreakerCooldown time.Duration
+}
+
+// ErrCircuitOpen is returned if the breaker does not allow to send.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// Permanent marks an error which must not be retried, like a rejected request.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// DefaultPolicy is used for every value which is not configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:      3,
+		InitialBackoff:   time.Duration(1) * time.Second,
+		MaxBackoff:       time.Duration(1) * time.Minute,
+		Multiplier:       2,
+		Jitter:           0.2,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Duration(30) * time.Second,
+	}
+}
+
+// PolicyFromConfig returns the policy of the Retry section.
+func PolicyFromConfig() Policy {
+	cfg := config.GetConfig().Retry
+	policy := DefaultPolicy()
+	if cfg.MaxAttempts > 0 {
+		policy.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.InitialBackoff > 0 {
+		policy.InitialBackoff = time.Duration(cfg.InitialBackoff * float64(time.Second))
+	}
+	if cfg.MaxBackoff > 0 {
+		policy.MaxBackoff = time.Duration(cfg.MaxBackoff * float64(time.Second))
+	}
+	if cfg.Multiplier >= 1 {
+		policy.Multiplier = cfg.Multiplier
+	}
+	if cfg.Jitter != nil {
+		policy.Jitter = min(max(*cfg.Jitter, 0), 1)
+	}
+	if cfg.BreakerThreshold != nil {
+		policy.BreakerThreshold = *cfg.BreakerThreshold
+	}
+	if cfg.BreakerCooldown > 0 {
+		policy.BreakerCooldown = time.Duration(cfg.BreakerCooldown * float64(time.Second))
+	}
+	return policy
+}
+
+// Backoff returns the time to wait before the given retry, the first retry is 0.
+func (p Policy) Backoff(retry int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry))
+	backoff = min(backoff, float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
+
+// Do calls send until it succeeds. It gives up if the error is permanent, the attempts are exhausted, the breaker is open or wait returns an error.
+// wait has to sleep the given duration and should return an error if the worker has to quit, this error is returned.
+// The breaker can be nil.
+func (p Policy) Do(breaker *Breaker, wait func(time.Duration) error, send func() error) error {
+	var err error
+	for attempt := range max(p.MaxAttempts, 1) {
+		if attempt > 0 {
+			if breaker != nil {
+				statistics.GetPrometheusServer().Retries.WithLabelValues(breaker.target.String()).Inc()
+			}
+			if waitErr := wait(p.Backoff(attempt - 1)); waitErr != nil {
+				return waitErr
+			}
+		}
+		if !breaker.Allow() {
+			return ErrCircuitOpen
+		}
+		err = send()
+		var permanent *permanentError
+		if err == nil {
+			breaker.Success()
+			return nil
+		} else if errors.As(err, &permanent) {
+			// the target answered, so it's alive
+			breaker.Success()
+			return permanent.err
+		}
+		breaker.Failure()
+	}
+	return err
+}
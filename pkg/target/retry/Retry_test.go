@@ -0,0 +1,130 @@
+package retry
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/stretchr/testify/assert"
+)
+
+var retryTarget = data.Target{Name: "test", Datatype: data.InfluxDB}
+
+var errSend = errors.New("send failed")
+
+func TestMain(m *testing.M) {
+	statistics.NewPrometheusServer("")
+	os.Exit(m.Run())
+}
+
+func noWait(time.Duration) error {
+	return nil
+}
+
+func testPolicy() Policy {
+	return Policy{
+		MaxAttempts:      3,
+		InitialBackoff:   time.Second,
+		MaxBackoff:       5 * time.Second,
+		Multiplier:       2,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	policy := testPolicy()
+	assert.Equal(t, time.Second, policy.Backoff(0))
+	assert.Equal(t, 2*time.Second, policy.Backoff(1))
+	assert.Equal(t, 4*time.Second, policy.Backoff(2))
+	assert.Equal(t, 5*time.Second, policy.Backoff(3), "the backoff should be capped")
+
+	policy.Jitter = 0.5
+	for range 100 {
+		backoff := policy.Backoff(1)
+		assert.GreaterOrEqual(t, backoff, time.Second)
+		assert.LessOrEqual(t, backoff, 3*time.Second)
+	}
+}
+
+func TestDo(t *testing.T) {
+	logging.InitTestLogger()
+	policy := testPolicy()
+	policy.BreakerThreshold = 0
+
+	calls := 0
+	err := policy.Do(nil, noWait, func() error {
+		calls++
+		if calls < 3 {
+			return errSend
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = policy.Do(nil, noWait, func() error {
+		calls++
+		return errSend
+	})
+	assert.ErrorIs(t, err, errSend)
+	assert.Equal(t, 3, calls, "should give up after MaxAttempts")
+
+	calls = 0
+	err = policy.Do(nil, noWait, func() error {
+		calls++
+		return Permanent(errSend)
+	})
+	assert.Equal(t, errSend, err, "a permanent error should be unwrapped")
+	assert.Equal(t, 1, calls, "a permanent error should not be retried")
+
+	errQuit := errors.New("quit")
+	calls = 0
+	err = policy.Do(nil, func(time.Duration) error { return errQuit }, func() error {
+		calls++
+		return errSend
+	})
+	assert.Equal(t, errQuit, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestBreaker(t *testing.T) {
+	logging.InitTestLogger()
+	now := time.Now()
+	alive := false
+	probes := 0
+	breaker := NewBreaker(retryTarget, testPolicy(), func() bool {
+		probes++
+		return alive
+	})
+	breaker.now = func() time.Time { return now }
+
+	err := testPolicy().Do(breaker, noWait, func() error { return errSend })
+	assert.ErrorIs(t, err, ErrCircuitOpen, "the breaker should open after two failures")
+	assert.Equal(t, Open, breaker.State())
+	assert.False(t, breaker.Allow())
+	assert.Equal(t, 0, probes, "the target should not be probed during the cooldown")
+
+	now = now.Add(time.Minute)
+	assert.False(t, breaker.Allow())
+	assert.Equal(t, 1, probes)
+	assert.Equal(t, Open, breaker.State(), "a failed probe should keep the breaker open")
+
+	now = now.Add(time.Minute)
+	alive = true
+	assert.True(t, breaker.Allow())
+	assert.Equal(t, HalfOpen, breaker.State())
+	assert.False(t, breaker.Allow(), "only one request is allowed while half-open")
+	breaker.Failure()
+	assert.Equal(t, Open, breaker.State(), "a failed trial should open the breaker again")
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, testPolicy().Do(breaker, noWait, func() error { return nil }))
+	assert.Equal(t, Closed, breaker.State())
+}
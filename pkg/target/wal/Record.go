@@ -0,0 +1,23 @@
+package wal
+
+import (
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+)
+
+// Record is a Printable read from the write-ahead log, the target has to acknowledge it after the delivery.
+type Record struct {
+	collector.SimplePrintable
+
+	seq uint64
+	log *Log
+}
+
+// Ack marks the record as delivered, so the log can forget it.
+func (r *Record) Ack() {
+	r.log.ack(r.seq)
+}
+
+// Nack hands the record back to the log, which will redeliver it later.
+func (r *Record) Nack() {
+	r.log.nack(r)
+}
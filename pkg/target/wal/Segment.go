@@ -0,0 +1,130 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	segmentSuffix = ".wal"
+	// every record starts with the length of the payload and its checksum
+	headerSize = 8
+	// records bigger than this are considered as corrupt
+	maxRecordSize = 64 * 1024 * 1024
+)
+
+var (
+	crcTable         = crc32.MakeTable(crc32.Castagnoli)
+	errCorruptRecord = errors.New("corrupt record")
+)
+
+// segment is one file of the log, it contains the records starting with the sequence number base.
+type segment struct {
+	base uint64
+	path string
+	size int64
+}
+
+// Generates the filename of a segment, the zero padding keeps them sortable.
+func segmentPath(dir string, base uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", base, segmentSuffix))
+}
+
+// Lists all segments of the folder ordered by their base.
+func listSegments(dir string) ([]*segment, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := []*segment{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), segmentSuffix) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, &segment{base: base, path: filepath.Join(dir, file.Name()), size: info.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].base < segments[j].base })
+	return segments, nil
+}
+
+// Appends a single record to the writer.
+func writeRecord(w io.Writer, payload []byte) (int, error) {
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.Checksum(payload, crcTable))
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return 0, err
+	}
+	return headerSize + len(payload), nil
+}
+
+// Reads a single record, returns io.EOF if the end of the segment is reached cleanly.
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errCorruptRecord
+		}
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxRecordSize {
+		return nil, errCorruptRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errCorruptRecord
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errCorruptRecord
+	}
+	return payload, nil
+}
+
+// Counts the valid records of a segment and cuts off a torn write at the end, which happens if the process crashes while appending.
+func recoverSegment(seg *segment) (uint64, error) {
+	file, err := os.OpenFile(seg.path, os.O_RDWR, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var count uint64
+	var validSize int64
+	for {
+		payload, err := readRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if truncErr := file.Truncate(validSize); truncErr != nil {
+				return 0, truncErr
+			}
+			break
+		}
+		count++
+		validSize += int64(headerSize + len(payload))
+	}
+	seg.size = validSize
+	return count, nil
+}
@@ -0,0 +1,566 @@
+package wal
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/ConSol-Monitoring/nagflux/pkg/logging"
+	"github.com/ConSol-Monitoring/nagflux/pkg/statistics"
+	"github.com/kdar/factorlog"
+)
+
+const (
+	// DefaultSegmentSize is used if no segment size is configured, in bytes.
+	DefaultSegmentSize = 16 * 1024 * 1024
+	// DefaultMaxSize is used if no size cap is configured, in bytes.
+	DefaultMaxSize = 1024 * 1024 * 1024
+
+	ackFile = "ack"
+	// amount of Printables which are written with a single fsync
+	maxBatchSize = 1000
+	// interval to persist the acknowledged offset and to clean up old segments
+	janitorInterval = time.Duration(5) * time.Second
+)
+
+// time to wait until a not acknowledged record is delivered again
+var retryDelay = time.Duration(10) * time.Second
+
+// RenderFunc converts a Printable into the payload for the target, an empty string skips the Printable.
+type RenderFunc func(collector.Printable) string
+
+// Log is a segmented write-ahead log for a single target. Every Printable of the input queue is rendered for the target,
+// written to disk and synced before it is handed to the workers. A record is kept until the target acknowledged it,
+// so neither a crash nor an outage of the target loses data and no restart is needed to replay it.
+type Log struct {
+	dir         string
+	target      data.Target
+	render      RenderFunc
+	in          chan collector.Printable
+	out         chan collector.Printable
+	segmentSize int64
+	maxSize     int64
+	maxAge      time.Duration
+	log         *factorlog.FactorLog
+	promServer  statistics.PrometheusServer
+	IsRunning   bool
+
+	mutex    *sync.Mutex
+	segments []*segment
+	// sequence number of the next record, everything below is durable
+	nextSeq uint64
+	// sequence number of the next record the reader will deliver
+	readSeq uint64
+	// every record below this sequence number is acknowledged
+	acked uint64
+	// acknowledge state of the records between acked and readSeq
+	window   []bool
+	retries  []*retry
+	savedAck uint64
+	notify   chan bool
+
+	// only used by the writer
+	file   *os.File
+	writer *bufio.Writer
+
+	// only used by the reader
+	readSegment *segment
+	readFile    *os.File
+	reader      *bufio.Reader
+	// sequence number of the record the reader is positioned at
+	readNext uint64
+
+	quitWriter  chan bool
+	quitReader  chan bool
+	quitJanitor chan bool
+}
+
+type retry struct {
+	record   *Record
+	nackedAt time.Time
+}
+
+// NewLog opens the write-ahead log of the target within the given folder and starts it.
+// The log reads from the given queue, the workers of the target have to read from Output.
+// Sizes are in bytes, the maxAge in seconds, zero values fall back to the defaults respectively disable the age cap.
+func NewLog(in chan collector.Printable, target data.Target, folder string, segmentSize, maxSize, maxAge, bufferSize int, render RenderFunc) (*Log, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	l := &Log{
+		dir:         filepath.Join(folder, target.String()),
+		target:      target,
+		render:      render,
+		in:          in,
+		out:         make(chan collector.Printable, bufferSize),
+		segmentSize: int64(segmentSize),
+		maxSize:     int64(maxSize),
+		maxAge:      time.Duration(maxAge) * time.Second,
+		log:         logging.GetLogger(),
+		promServer:  statistics.GetPrometheusServer(),
+		IsRunning:   true,
+		mutex:       &sync.Mutex{},
+		notify:      make(chan bool, 1),
+		quitWriter:  make(chan bool),
+		quitReader:  make(chan bool),
+		quitJanitor: make(chan bool),
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	l.log.Infof("WAL(%s) opened at %s, %d records to replay", target.Name, l.dir, l.Pending())
+
+	go l.runWriter()
+	go l.runReader()
+	go l.runJanitor()
+	return l, nil
+}
+
+// Output returns the queue the workers of the target have to read from.
+func (l *Log) Output() chan collector.Printable {
+	return l.out
+}
+
+// Pending returns the amount of records which are not acknowledged yet.
+func (l *Log) Pending() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.nextSeq - l.acked
+}
+
+// Stop stops the log, every Printable left in the input queue is written to disk before.
+func (l *Log) Stop() {
+	if !l.IsRunning {
+		return
+	}
+	l.quitReader <- true
+	<-l.quitReader
+	l.quitWriter <- true
+	<-l.quitWriter
+	l.quitJanitor <- true
+	<-l.quitJanitor
+	l.saveAck()
+	if l.readFile != nil {
+		l.readFile.Close()
+	}
+	l.file.Close()
+	l.IsRunning = false
+	l.log.Debugf("WAL(%s) stopped", l.target.Name)
+}
+
+// Loads the segments from disk and prepares the last one for appending.
+func (l *Log) open() error {
+	if err := os.MkdirAll(l.dir, 0o750); err != nil {
+		return err
+	}
+	segments, err := listSegments(l.dir)
+	if err != nil {
+		return err
+	}
+	acked, found := l.loadAck()
+	if len(segments) == 0 {
+		if !found {
+			acked = 0
+		}
+		seg := &segment{base: acked, path: segmentPath(l.dir, acked)}
+		if err := os.WriteFile(seg.path, []byte{}, 0o600); err != nil {
+			return err
+		}
+		segments = append(segments, seg)
+	}
+	last := segments[len(segments)-1]
+	count, err := recoverSegment(last)
+	if err != nil {
+		return err
+	}
+	l.segments = segments
+	l.nextSeq = last.base + count
+	if acked < segments[0].base {
+		acked = segments[0].base
+	}
+	if acked > l.nextSeq {
+		acked = l.nextSeq
+	}
+	l.acked = acked
+	l.readSeq = acked
+	l.savedAck = acked
+
+	l.file, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	l.writer = bufio.NewWriter(l.file)
+	return nil
+}
+
+// Reads the acknowledged offset of the last run.
+func (l *Log) loadAck() (uint64, bool) {
+	content, err := os.ReadFile(filepath.Join(l.dir, ackFile))
+	if err != nil {
+		return 0, false
+	}
+	acked, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		l.log.Warnf("WAL(%s) ack file is broken, replaying everything: %s", l.target.Name, err)
+		return 0, false
+	}
+	return acked, true
+}
+
+// Persists the acknowledged offset, the rename makes sure the file is never half written.
+func (l *Log) saveAck() {
+	l.mutex.Lock()
+	acked := l.acked
+	l.mutex.Unlock()
+	if acked == l.savedAck {
+		return
+	}
+	tmp := filepath.Join(l.dir, ackFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(acked, 10)), 0o600); err != nil {
+		l.log.Warn(err)
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(l.dir, ackFile)); err != nil {
+		l.log.Warn(err)
+		return
+	}
+	l.savedAck = acked
+}
+
+// Waits for Printables and appends them to the log.
+func (l *Log) runWriter() {
+	for {
+		select {
+		case <-l.quitWriter:
+			var rest []collector.Printable
+			for len(l.in) > 0 {
+				rest = append(rest, <-l.in)
+			}
+			l.append(rest)
+			l.quitWriter <- true
+			return
+		case p := <-l.in:
+			batch := []collector.Printable{p}
+		Batch:
+			for len(batch) < maxBatchSize {
+				select {
+				case p = <-l.in:
+					batch = append(batch, p)
+				default:
+					break Batch
+				}
+			}
+			l.append(batch)
+		}
+	}
+}
+
+// Writes the batch to the current segment and syncs it, after that the records are visible to the reader.
+func (l *Log) append(batch []collector.Printable) {
+	active := l.segments[len(l.segments)-1]
+	var written uint64
+	for _, p := range batch {
+		payload := l.render(p)
+		if payload == "" {
+			continue
+		}
+		if active.size >= l.segmentSize {
+			l.publish(written)
+			written = 0
+			if err := l.roll(); err != nil {
+				l.log.Criticalf("WAL(%s) could not create a new segment: %s", l.target.Name, err)
+			} else {
+				active = l.segments[len(l.segments)-1]
+			}
+		}
+		n, err := writeRecord(l.writer, []byte(payload))
+		if err == nil {
+			err = l.writer.Flush()
+		}
+		if err != nil {
+			// cut off the torn record and hand the Printable directly to the workers, they will dump it if needed
+			l.log.Criticalf("WAL(%s) could not write record: %s", l.target.Name, err)
+			l.writer.Reset(l.file)
+			if truncErr := l.file.Truncate(active.size); truncErr != nil {
+				l.log.Critical(truncErr)
+			}
+			l.out <- p
+			continue
+		}
+		l.mutex.Lock()
+		active.size += int64(n)
+		l.mutex.Unlock()
+		written++
+	}
+	l.publish(written)
+}
+
+// Syncs the segment and makes the written records visible to the reader.
+func (l *Log) publish(written uint64) {
+	if written == 0 {
+		return
+	}
+	if err := l.file.Sync(); err != nil {
+		l.log.Criticalf("WAL(%s) could not sync segment: %s", l.target.Name, err)
+	}
+	l.mutex.Lock()
+	l.nextSeq += written
+	l.mutex.Unlock()
+	select {
+	case l.notify <- true:
+	default:
+	}
+}
+
+// Closes the current segment and starts a new one.
+func (l *Log) roll() error {
+	l.mutex.Lock()
+	seg := &segment{base: l.nextSeq, path: segmentPath(l.dir, l.nextSeq)}
+	l.mutex.Unlock()
+	file, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	l.file.Close()
+	l.file = file
+	l.writer.Reset(file)
+	l.mutex.Lock()
+	l.segments = append(l.segments, seg)
+	l.mutex.Unlock()
+	l.log.Debugf("WAL(%s) started segment %s", l.target.Name, seg.path)
+	return nil
+}
+
+// Delivers the records to the workers, as long as the log is running.
+func (l *Log) runReader() {
+	for {
+		record := l.nextRecord()
+		if record == nil {
+			select {
+			case <-l.quitReader:
+				l.quitReader <- true
+				return
+			case <-l.notify:
+			case <-time.After(time.Duration(1) * time.Second):
+			}
+			continue
+		}
+		select {
+		case <-l.quitReader:
+			l.quitReader <- true
+			return
+		case l.out <- record:
+		}
+	}
+}
+
+// Returns the next record to deliver, records which were handed back have precedence. Returns nil if there is nothing to do.
+func (l *Log) nextRecord() *Record {
+	l.mutex.Lock()
+	if len(l.retries) > 0 && time.Since(l.retries[0].nackedAt) >= retryDelay {
+		record := l.retries[0].record
+		l.retries = l.retries[1:]
+		l.mutex.Unlock()
+		return record
+	}
+	if l.readSeq >= l.nextSeq {
+		l.mutex.Unlock()
+		return nil
+	}
+	seq := l.readSeq
+	var seg *segment
+	end := l.nextSeq
+	for i, s := range l.segments {
+		if s.base <= seq {
+			seg = s
+			if i+1 < len(l.segments) {
+				end = l.segments[i+1].base
+			}
+		}
+	}
+	l.mutex.Unlock()
+
+	payload, err := l.readAt(seg, seq)
+	if err != nil {
+		// the rest of the segment is unreadable, skip to the next one
+		l.log.Errorf("WAL(%s) could not read record %d, skipping %d records: %s", l.target.Name, seq, end-seq, err)
+		l.mutex.Lock()
+		for l.readSeq == seq && seq < end {
+			l.window = append(l.window, true)
+			l.readSeq++
+			seq++
+		}
+		l.advance()
+		l.mutex.Unlock()
+		l.readSegment = nil
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.readSeq != seq {
+		// the janitor dropped the segment in the meantime
+		return nil
+	}
+	l.readSeq++
+	l.window = append(l.window, false)
+	return &Record{
+		SimplePrintable: collector.SimplePrintable{
+			Filterable: collector.AllFilterable,
+			Text:       string(payload),
+			Datatype:   l.target.Datatype,
+		},
+		seq: seq,
+		log: l,
+	}
+}
+
+// Reads the record with the given sequence number, the current file is reused as long as the records are read in order.
+func (l *Log) readAt(seg *segment, seq uint64) ([]byte, error) {
+	if l.readSegment != seg || l.readNext != seq {
+		if l.readFile != nil {
+			l.readFile.Close()
+			l.readFile = nil
+		}
+		file, err := os.Open(seg.path)
+		if err != nil {
+			return nil, err
+		}
+		l.readFile = file
+		l.readSegment = seg
+		l.reader = bufio.NewReader(file)
+		for range seq - seg.base {
+			if _, err := readRecord(l.reader); err != nil {
+				return nil, err
+			}
+		}
+	}
+	payload, err := readRecord(l.reader)
+	if err == io.EOF {
+		return nil, errCorruptRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.readNext = seq + 1
+	return payload, nil
+}
+
+// Marks the record as delivered and moves the acknowledged offset as far as possible.
+func (l *Log) ack(seq uint64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if seq < l.acked || seq-l.acked >= uint64(len(l.window)) {
+		return
+	}
+	l.window[seq-l.acked] = true
+	l.advance()
+}
+
+// Moves the acknowledged offset over every leading acknowledged record, the caller has to hold the mutex.
+func (l *Log) advance() {
+	done := 0
+	for done < len(l.window) && l.window[done] {
+		done++
+	}
+	l.window = l.window[done:]
+	l.acked += uint64(done)
+}
+
+// Remembers the record for a later redelivery.
+func (l *Log) nack(record *Record) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if record.seq < l.acked {
+		return
+	}
+	l.retries = append(l.retries, &retry{record: record, nackedAt: time.Now()})
+}
+
+// Persists the acknowledged offset and removes segments which are not needed anymore.
+func (l *Log) runJanitor() {
+	for {
+		select {
+		case <-l.quitJanitor:
+			l.quitJanitor <- true
+			return
+		case <-time.After(janitorInterval):
+			l.saveAck()
+			l.cleanUp()
+			l.promServer.WALPendingRecords.WithLabelValues(l.target.String()).Set(float64(l.Pending()))
+		}
+	}
+}
+
+// Removes acknowledged segments and enforces the size and age cap, even if this drops records which were not delivered.
+func (l *Log) cleanUp() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var totalSize int64
+	for _, seg := range l.segments {
+		totalSize += seg.size
+	}
+	// the last segment is used by the writer and will never be removed
+	for len(l.segments) > 1 {
+		oldest := l.segments[0]
+		end := l.segments[1].base
+		switch {
+		case end <= l.acked:
+			l.log.Debugf("WAL(%s) removing acknowledged segment %s", l.target.Name, oldest.path)
+		case totalSize > l.maxSize:
+			l.log.Warnf("WAL(%s) exceeds the size cap of %d bytes, dropping %d records", l.target.Name, l.maxSize, end-max(oldest.base, l.acked))
+		case l.maxAge > 0 && l.isOlderThanMaxAge(oldest):
+			l.log.Warnf("WAL(%s) segment is older than %s, dropping %d records", l.target.Name, l.maxAge, end-max(oldest.base, l.acked))
+		default:
+			return
+		}
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			l.log.Warn(err)
+			return
+		}
+		totalSize -= oldest.size
+		l.segments = l.segments[1:]
+		l.dropBefore(end)
+	}
+}
+
+func (l *Log) isOlderThanMaxAge(seg *segment) bool {
+	info, err := os.Stat(seg.path)
+	if err != nil {
+		return false
+	}
+	return time.Since(info.ModTime()) > l.maxAge
+}
+
+// Forgets every record below the given sequence number.
+func (l *Log) dropBefore(seq uint64) {
+	if l.acked >= seq {
+		return
+	}
+	if diff := seq - l.acked; diff < uint64(len(l.window)) {
+		l.window = l.window[diff:]
+	} else {
+		l.window = l.window[:0]
+	}
+	l.acked = seq
+	if l.readSeq < seq {
+		l.readSeq = seq
+	}
+	retries := l.retries[:0]
+	for _, r := range l.retries {
+		if r.record.seq >= seq {
+			retries = append(retries, r)
+		}
+	}
+	l.retries = retries
+}
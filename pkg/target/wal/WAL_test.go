@@ -0,0 +1,120 @@
+package wal
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ConSol-Monitoring/nagflux/pkg/collector"
+	"github.com/ConSol-Monitoring/nagflux/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var walTarget = data.Target{Name: "test", Datatype: data.InfluxDB}
+
+func renderInflux(p collector.Printable) string {
+	return p.PrintForInfluxDB("1.0")
+}
+
+func newTestLog(t *testing.T, folder string, segmentSize int) (*Log, chan collector.Printable) {
+	t.Helper()
+	in := make(chan collector.Printable, 10)
+	l, err := NewLog(in, walTarget, folder, segmentSize, 0, 0, 10, renderInflux)
+	require.NoError(t, err)
+	return l, in
+}
+
+func receive(t *testing.T, l *Log) *Record {
+	t.Helper()
+	select {
+	case p := <-l.Output():
+		record, ok := p.(*Record)
+		require.True(t, ok, "expected a WAL record")
+		return record
+	case <-time.After(3 * time.Second):
+		t.Fatal("no record was delivered")
+	}
+	return nil
+}
+
+func TestLogDeliversAndReplaysUnacknowledged(t *testing.T) {
+	folder := t.TempDir()
+	l, in := newTestLog(t, folder, 0)
+	for _, text := range []string{"a", "b", "c"} {
+		in <- &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: text, Datatype: data.InfluxDB}
+	}
+	first := receive(t, l)
+	second := receive(t, l)
+	third := receive(t, l)
+	assert.Equal(t, "a", first.Text)
+	assert.Equal(t, "b", second.Text)
+	assert.Equal(t, "c", third.Text)
+
+	first.Ack()
+	third.Ack()
+	assert.Equal(t, uint64(2), l.Pending())
+	l.Stop()
+
+	// b was not acknowledged so it has to be delivered again, c too as the offset can't skip b
+	l, _ = newTestLog(t, folder, 0)
+	defer l.Stop()
+	assert.Equal(t, uint64(2), l.Pending())
+	assert.Equal(t, "b", receive(t, l).Text)
+	assert.Equal(t, "c", receive(t, l).Text)
+}
+
+func TestLogRedeliversNacked(t *testing.T) {
+	oldDelay := retryDelay
+	retryDelay = time.Duration(10) * time.Millisecond
+	defer func() { retryDelay = oldDelay }()
+
+	l, in := newTestLog(t, t.TempDir(), 0)
+	defer l.Stop()
+	in <- &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: "a", Datatype: data.InfluxDB}
+	record := receive(t, l)
+	record.Nack()
+	again := receive(t, l)
+	assert.Equal(t, "a", again.Text)
+	again.Ack()
+	assert.Equal(t, uint64(0), l.Pending())
+}
+
+func TestLogRollsAndRemovesAcknowledgedSegments(t *testing.T) {
+	l, in := newTestLog(t, t.TempDir(), 1)
+	defer l.Stop()
+	for _, text := range []string{"a", "b", "c"} {
+		in <- &collector.SimplePrintable{Filterable: collector.AllFilterable, Text: text, Datatype: data.InfluxDB}
+	}
+	for _, text := range []string{"a", "b", "c"} {
+		record := receive(t, l)
+		assert.Equal(t, text, record.Text)
+		record.Ack()
+	}
+	l.mutex.Lock()
+	assert.Len(t, l.segments, 3)
+	l.mutex.Unlock()
+	l.cleanUp()
+	segments, err := listSegments(l.dir)
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+}
+
+func TestRecoverSegmentCutsTornRecord(t *testing.T) {
+	folder := t.TempDir()
+	seg := &segment{base: 5, path: segmentPath(folder, 5)}
+	file, err := os.Create(seg.path)
+	require.NoError(t, err)
+	_, err = writeRecord(file, []byte("complete"))
+	require.NoError(t, err)
+	_, err = file.WriteString("\x00\x00\x00\x10torn")
+	require.NoError(t, err)
+	file.Close()
+
+	count, err := recoverSegment(seg)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+	info, err := os.Stat(seg.path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(headerSize+len("complete")), info.Size())
+}